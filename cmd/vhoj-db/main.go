@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/datasource"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/migration"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage: vhoj-db [-config path] migrate <command>
+
+commands:
+  up        apply all pending migrations
+  down N    roll back the last N applied migrations
+  status    list migrations and whether they are applied
+  plan      print the sql pending migrations would run without executing it
+`
+
+func main() {
+	configPath := flag.String("config", "config/mysql.yaml", "config file, overridden by VHOJ_DB_* env")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 || args[0] != "migrate" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := runMigrate(os.Stdout, *configPath, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "vhoj-db: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runMigrate(out io.Writer, configPath string, args []string) error {
+	conf, err := datasource.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	conf.DisableAutoMigrate = true
+	store, err := datasource.Open(conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	migrator := migration.NewMigrator(store.DB, migration.Migrations)
+	switch args[0] {
+	case "up":
+		return migrator.Up()
+	case "down":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate down need N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid N: %v", args[1])
+		}
+		return migrator.Down(n)
+	case "status":
+		return printStatus(out, migrator)
+	case "plan":
+		return printPlan(out, migrator)
+	default:
+		return fmt.Errorf("unknown migrate command: %v", args[0])
+	}
+}
+
+func printStatus(out io.Writer, migrator *migration.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
+
+func printPlan(out io.Writer, migrator *migration.Migrator) error {
+	plans, err := migrator.Plan()
+	if err != nil {
+		return err
+	}
+	if len(plans) == 0 {
+		fmt.Fprintln(out, "-- nothing to migrate")
+		return nil
+	}
+	for _, plan := range plans {
+		fmt.Fprintf(out, "-- %v_%v\n", plan.Version, plan.Name)
+		for _, statement := range plan.Statements {
+			fmt.Fprintf(out, "%v;\n", statement)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/datasource"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/migration"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "vhoj_db_cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	dbPath := filepath.Join(dir, "vhoj.db")
+	configPath := filepath.Join(dir, "sqlite.yaml")
+	err = ioutil.WriteFile(configPath, []byte(fmt.Sprintf("driver: sqlite3\npath: %v\n", dbPath)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configPath, dbPath
+}
+
+func tableNames(t *testing.T, dbPath string) []string {
+	store, err := datasource.Open(&datasource.MysqlConf{Driver: datasource.DriverSqlite, Path: dbPath, DisableAutoMigrate: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	var names []string
+	if err = store.DB.Raw("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name").Pluck("name", &names).Error; err != nil {
+		t.Fatalf("tables: %v", err)
+	}
+	return names
+}
+
+func TestRunMigrate(t *testing.T) {
+	configPath, dbPath := writeConfig(t)
+	var out bytes.Buffer
+	if err := runMigrate(&out, configPath, []string{"plan"}); err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "-- 1_create_initial_tables\n") || !strings.Contains(out.String(), `CREATE TABLE "problems"`) {
+		t.Fatalf("unexpected plan output: %v", out.String())
+	}
+	if tables := tableNames(t, dbPath); len(tables) != 0 {
+		t.Fatalf("plan created tables: %v", tables)
+	}
+	if err := runMigrate(&out, configPath, []string{"up"}); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	out.Reset()
+	if err := runMigrate(&out, configPath, []string{"plan"}); err != nil || out.String() != "-- nothing to migrate\n" {
+		t.Fatalf("expected nothing to migrate, got %q err=%v", out.String(), err)
+	}
+	if err := runMigrate(&out, configPath, []string{"down", "1"}); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	out.Reset()
+	if err := runMigrate(&out, configPath, []string{"status"}); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(migration.Migrations)+1 || !strings.Contains(lines[1], "applied") || !strings.Contains(lines[len(lines)-1], "pending") {
+		t.Fatalf("unexpected status output: %v", out.String())
+	}
+	for _, args := range [][]string{{"down"}, {"down", "0"}, {"sideways"}} {
+		if err := runMigrate(&out, configPath, args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
@@ -4,7 +4,9 @@ go 1.14
 
 require (
 	github.com/ecnuvj/vhoj_common v0.0.0-00010101000000-000000000000
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jinzhu/gorm v1.9.16
+	github.com/lib/pq v1.1.1
 	gopkg.in/yaml.v2 v2.4.0
 )
 
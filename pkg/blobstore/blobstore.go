@@ -0,0 +1,32 @@
+//blobstore 保存题面引用的图片、PDF等附件 使其不再依赖远程oj的站点
+package blobstore
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"io"
+	"path"
+	"strings"
+)
+
+//key为以/分隔的相对路径 如 raw_problems/12/0f3a....png
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	//key不存在时返回errors.ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	//key不存在时不报错
+	Delete(ctx context.Context, key string) error
+	//题面中引用该附件使用的地址
+	URL(key string) string
+}
+
+//拒绝空key、绝对路径与跳出根目录的key
+func CheckKey(op string, key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return errors.InvalidArgument(op, "invalid blob key %q", key)
+	}
+	if cleaned := path.Clean(key); cleaned != key || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return errors.InvalidArgument(op, "invalid blob key %q", key)
+	}
+	return nil
+}
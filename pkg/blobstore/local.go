@@ -0,0 +1,89 @@
+package blobstore
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//把附件保存在本地目录 由静态文件服务以BaseURL对外提供
+type LocalStore struct {
+	Root    string
+	BaseURL string
+}
+
+func NewLocalStore(root string, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, errors.Wrap("blobstore.NewLocalStore", err)
+	}
+	return &LocalStore{Root: root, BaseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+//先写入同目录下的临时文件再改名 读者不会看到写了一半的文件
+func (s *LocalStore) Put(ctx context.Context, key string, content io.Reader) error {
+	if err := CheckKey("blobstore.Put", key); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap("blobstore.Put", err)
+	}
+	name := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return errors.Wrap("blobstore.Put", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".blob-*")
+	if err != nil {
+		return errors.Wrap("blobstore.Put", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return errors.Wrap("blobstore.Put", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap("blobstore.Put", err)
+	}
+	return errors.Wrap("blobstore.Put", os.Rename(tmp.Name(), name))
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := CheckKey("blobstore.Open", key); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap("blobstore.Open", err)
+	}
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, errors.NotFound("blobstore.Open", "blob %q not found", key)
+	}
+	if err != nil {
+		return nil, errors.Wrap("blobstore.Open", err)
+	}
+	return f, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := CheckKey("blobstore.Delete", key); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap("blobstore.Delete", err)
+	}
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap("blobstore.Delete", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(key))
+}
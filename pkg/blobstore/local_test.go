@@ -0,0 +1,43 @@
+package blobstore
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "https://vhoj.example.com/assets/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err = store.Put(ctx, "raw_problems/1/a.png", strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Open(ctx, "raw_problems/1/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(content) != "png" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if url := store.URL("raw_problems/1/a.png"); url != "https://vhoj.example.com/assets/raw_problems/1/a.png" {
+		t.Fatalf("unexpected url %q", url)
+	}
+	if err = store.Delete(ctx, "raw_problems/1/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Open(ctx, "raw_problems/1/a.png"); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	for _, key := range []string{"", "/etc/passwd", "../a.png", "a/../../b", "a//b"} {
+		if err = store.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, errors.ErrInvalidArgument) {
+			t.Fatalf("key %q: expected ErrInvalidArgument, got %v", key, err)
+		}
+	}
+}
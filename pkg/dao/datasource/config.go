@@ -0,0 +1,167 @@
+package datasource
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "VHOJ_DB_"
+
+type MysqlConf struct {
+	Driver       string `yaml:"driver"`
+	Host         string `yaml:"host"`
+	Port         string `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	DBName       string `yaml:"db_name"`
+	Local        string `yaml:"loc"`
+	Charset      string `yaml:"charset"`
+	ParseTime    string `yaml:"parse_time"`
+	SSLMode      string `yaml:"ssl_mode"`
+	Path         string `yaml:"path"`
+	//连接池与启动重试 为0时使用database/sql默认值
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	ConnectRetries  int           `yaml:"connect_retries"`
+	//为true时ConnectDB/Open不执行迁移 需另行执行迁移
+	DisableAutoMigrate bool `yaml:"disable_auto_migrate"`
+}
+
+//配置按 yaml文件 -> 环境变量(VHOJ_DB_*) -> 密码文件 的顺序逐层覆盖 path为空时跳过yaml
+func LoadConfig(path string) (*MysqlConf, error) {
+	conf := &MysqlConf{}
+	if path != "" {
+		configFile, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(configFile, conf); err != nil {
+			return nil, err
+		}
+	}
+	if err := conf.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := conf.applyPasswordFile(); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (c *MysqlConf) envFields() map[string]*string {
+	return map[string]*string{
+		"DRIVER":        &c.Driver,
+		"HOST":          &c.Host,
+		"PORT":          &c.Port,
+		"USER":          &c.User,
+		"PASSWORD":      &c.Password,
+		"PASSWORD_FILE": &c.PasswordFile,
+		"NAME":          &c.DBName,
+		"LOC":           &c.Local,
+		"CHARSET":       &c.Charset,
+		"PARSE_TIME":    &c.ParseTime,
+		"SSL_MODE":      &c.SSLMode,
+		"PATH":          &c.Path,
+	}
+}
+
+func (c *MysqlConf) applyEnv() error {
+	for name, field := range c.envFields() {
+		if value, ok := os.LookupEnv(envPrefix + name); ok {
+			*field = value
+		}
+	}
+	intFields := map[string]*int{
+		"MAX_OPEN_CONNS":  &c.MaxOpenConns,
+		"MAX_IDLE_CONNS":  &c.MaxIdleConns,
+		"CONNECT_RETRIES": &c.ConnectRetries,
+	}
+	for name, field := range intFields {
+		if value, ok := os.LookupEnv(envPrefix + name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid config: %v%v: %v", envPrefix, name, err)
+			}
+			*field = n
+		}
+	}
+	durationFields := map[string]*time.Duration{
+		"CONN_MAX_LIFETIME": &c.ConnMaxLifetime,
+		"CONNECT_TIMEOUT":   &c.ConnectTimeout,
+	}
+	for name, field := range durationFields {
+		if value, ok := os.LookupEnv(envPrefix + name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid config: %v%v: %v", envPrefix, name, err)
+			}
+			*field = d
+		}
+	}
+	if value, ok := os.LookupEnv(envPrefix + "DISABLE_AUTO_MIGRATE"); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid config: %vDISABLE_AUTO_MIGRATE: %v", envPrefix, err)
+		}
+		c.DisableAutoMigrate = b
+	}
+	return nil
+}
+
+func (c *MysqlConf) applyPasswordFile() error {
+	if c.PasswordFile == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(c.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("read password_file: %v", err)
+	}
+	c.Password = strings.TrimRight(string(content), "\r\n")
+	return nil
+}
+
+func (c *MysqlConf) Validate() error {
+	required := map[string]string{}
+	switch strings.ToLower(c.Driver) {
+	case "", DriverMysql, DriverPostgres, "postgresql":
+		required = map[string]string{
+			"host":    c.Host,
+			"port":    c.Port,
+			"user":    c.User,
+			"db_name": c.DBName,
+		}
+	case DriverSqlite, "sqlite":
+		required = map[string]string{
+			"path": c.Path,
+		}
+	default:
+		return fmt.Errorf("invalid config: unsupported driver %v", c.Driver)
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnectRetries < 0 {
+		return fmt.Errorf("invalid config: max_open_conns, max_idle_conns and connect_retries must not be negative")
+	}
+	for _, field := range []string{"host", "port", "user", "db_name", "path"} {
+		if value, ok := required[field]; ok && value == "" {
+			return fmt.Errorf("invalid config: missing %v (yaml %v or env %v)", field, field, envName(field))
+		}
+	}
+	return nil
+}
+
+func envName(field string) string {
+	if field == "db_name" {
+		return envPrefix + "NAME"
+	}
+	return envPrefix + strings.ToUpper(field)
+}
@@ -0,0 +1,65 @@
+package datasource
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "vhoj_db_conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func setEnv(t *testing.T, key string, value string) {
+	os.Setenv(key, value)
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	path := writeTempFile(t, "host: yaml-host\nport: 3306\nuser: root\npassword: yaml-pass\ndb_name: vhoj\n")
+	setEnv(t, "VHOJ_DB_HOST", "env-host")
+	setEnv(t, "VHOJ_DB_PASSWORD", "env-pass")
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if conf.Host != "env-host" || conf.Password != "env-pass" || conf.User != "root" {
+		t.Fatalf("unexpected conf: %+v", conf)
+	}
+	passwordFile := writeTempFile(t, "file-pass\n")
+	setEnv(t, "VHOJ_DB_PASSWORD_FILE", passwordFile)
+	conf, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if conf.Password != "file-pass" {
+		t.Fatalf("password file not applied: %q", conf.Password)
+	}
+}
+
+func TestLoadConfigValidate(t *testing.T) {
+	path := writeTempFile(t, "host: localhost\nport: 3306\nuser: root\n")
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "db_name") {
+		t.Fatalf("expected missing db_name error, got %v", err)
+	}
+	setEnv(t, "VHOJ_DB_DRIVER", "sqlite3")
+	_, err = LoadConfig("")
+	if err == nil || !strings.Contains(err.Error(), "path") {
+		t.Fatalf("expected missing path error, got %v", err)
+	}
+	setEnv(t, "VHOJ_DB_PATH", "vhoj.db")
+	if _, err = LoadConfig(""); err != nil {
+		t.Fatalf("sqlite config: %v", err)
+	}
+}
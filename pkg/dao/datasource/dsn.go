@@ -0,0 +1,46 @@
+package datasource
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	DriverMysql    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite3"
+)
+
+//根据driver生成gorm.Open所需的dialect与dsn 未配置driver时沿用mysql
+func buildDSN(conf *MysqlConf) (dialect string, dsn string, err error) {
+	switch strings.ToLower(conf.Driver) {
+	case "", DriverMysql:
+		dsn = fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?charset=%v&loc=%v&parseTime=%v",
+			conf.User, conf.Password, conf.Host, conf.Port, conf.DBName, conf.Charset, conf.Local, conf.ParseTime)
+		if conf.ConnectTimeout > 0 {
+			dsn += fmt.Sprintf("&timeout=%v", conf.ConnectTimeout)
+		}
+		return DriverMysql, dsn, nil
+	case DriverPostgres, "postgresql":
+		sslMode := conf.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn = fmt.Sprintf("host=%v port=%v user=%v password=%v dbname=%v sslmode=%v",
+			conf.Host, conf.Port, conf.User, conf.Password, conf.DBName, sslMode)
+		if conf.ConnectTimeout > 0 {
+			//postgres的connect_timeout单位为秒 且至少为1
+			seconds := int64(math.Ceil(conf.ConnectTimeout.Seconds()))
+			dsn += fmt.Sprintf(" connect_timeout=%v", seconds)
+		}
+		return DriverPostgres, dsn, nil
+	case DriverSqlite, "sqlite":
+		if conf.Path == "" {
+			return "", "", fmt.Errorf("sqlite3 driver need path")
+		}
+		return DriverSqlite, conf.Path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported driver: %v", conf.Driver)
+	}
+}
@@ -0,0 +1,34 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type HealthStatus struct {
+	Latency time.Duration
+	Stats   sql.DBStats
+}
+
+//ping数据库并返回耗时与连接池状态 可用于服务的readiness探针
+func (s *Store) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	if s.sqlDB == nil {
+		return nil, fmt.Errorf("store has no database connection")
+	}
+	start := time.Now()
+	err := s.sqlDB.PingContext(ctx)
+	status := &HealthStatus{
+		Latency: time.Since(start),
+		Stats:   s.sqlDB.Stats(),
+	}
+	return status, err
+}
+
+func HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	if defaultStore == nil {
+		return nil, fmt.Errorf("database is not connected")
+	}
+	return defaultStore.HealthCheck(ctx)
+}
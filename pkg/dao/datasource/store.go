@@ -0,0 +1,128 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/contest_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/submission_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/user_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/migration"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+const maxConnectBackoff = 30 * time.Second
+
+//Store 持有一个数据库连接以及绑定在该连接上的mapper 不同Store之间互不影响
+type Store struct {
+	DB               *gorm.DB
+	sqlDB            *sql.DB
+	problemMapper    problem_mapper.IProblemMapper
+	userMapper       user_mapper.IUserMapper
+	contestMapper    contest_mapper.IContestMapper
+	submissionMapper submission_mapper.ISubmissionMapper
+}
+
+func NewStore(db *gorm.DB) *Store {
+	//事务或绑定ctx的db没有*sql.DB 由调用方补上
+	sqlDB, _ := db.CommonDB().(*sql.DB)
+	return &Store{
+		DB:               db,
+		sqlDB:            sqlDB,
+		problemMapper:    problem_mapper.NewMapper(db),
+		userMapper:       user_mapper.NewMapper(db),
+		contestMapper:    contest_mapper.NewMapper(db),
+		submissionMapper: submission_mapper.NewMapper(db),
+	}
+}
+
+func Open(conf *MysqlConf) (*Store, error) {
+	dialect, dsn, err := buildDSN(conf)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openWithRetry(dialect, dsn, conf.ConnectRetries)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB := db.DB()
+	if conf.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+	store := NewStore(db)
+	if !conf.DisableAutoMigrate {
+		if err = store.Migrate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+//gorm.Open会ping数据库 失败时按1s 2s 4s...(最长30s)退避重试retries次
+func openWithRetry(dialect string, dsn string, retries int) (*gorm.DB, error) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		db, err := gorm.Open(dialect, dsn)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= retries {
+			return nil, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+//返回绑定ctx的Store 其四个mapper的查询都会随ctx取消或超时而中断
+func (s *Store) WithContext(ctx context.Context) *Store {
+	store := NewStore(util.WithContext(s.DB, ctx))
+	store.sqlDB = s.sqlDB
+	return store
+}
+
+//在一个事务中执行fn tx上的四个mapper共享该事务 fn返回错误时整体回滚
+//在tx上再次调用WithTransaction会使用savepoint嵌套
+func (s *Store) WithTransaction(fn func(tx *Store) error) error {
+	return util.Transaction(s.DB, func(tx *gorm.DB) error {
+		store := NewStore(tx)
+		store.sqlDB = s.sqlDB
+		return fn(store)
+	})
+}
+
+func (s *Store) ProblemMapper() problem_mapper.IProblemMapper {
+	return s.problemMapper
+}
+
+func (s *Store) UserMapper() user_mapper.IUserMapper {
+	return s.userMapper
+}
+
+func (s *Store) ContestMapper() contest_mapper.IContestMapper {
+	return s.contestMapper
+}
+
+func (s *Store) SubmissionMapper() submission_mapper.ISubmissionMapper {
+	return s.submissionMapper
+}
+
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+func (s *Store) Migrate() error {
+	return migration.NewMigrator(s.DB, migration.Migrations).Up()
+}
@@ -0,0 +1,91 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenSqliteHealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vhoj_db_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := Open(&MysqlConf{
+		Driver:          DriverSqlite,
+		Path:            filepath.Join(dir, "vhoj.db"),
+		MaxOpenConns:    4,
+		ConnMaxLifetime: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	status, err := store.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if status.Stats.MaxOpenConnections != 4 {
+		t.Fatalf("max open conns not applied: %+v", status.Stats)
+	}
+}
+
+func TestStoreWithTransaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vhoj_db_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := Open(&MysqlConf{Driver: DriverSqlite, Path: filepath.Join(dir, "vhoj.db")})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	problem, err := store.ProblemMapper().AddOrModifyProblem(&model.Problem{GroupId: 1, RawProblemId: 1})
+	if err != nil {
+		t.Fatalf("add problem: %v", err)
+	}
+	user, err := store.UserMapper().AddUser(&model.User{Nickname: "tx", UserAuth: &model.UserAuth{Password: "tx"}})
+	if err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+	err = store.WithTransaction(func(tx *Store) error {
+		if _, err := tx.SubmissionMapper().AddOrModifySubmission(&model.Submission{ProblemId: problem.ID, UserId: user.ID}); err != nil {
+			return err
+		}
+		if err := tx.ProblemMapper().AddProblemSubmittedCountById(problem.ID); err != nil {
+			return err
+		}
+		if err := tx.UserMapper().AddUserSubmitCountById(user.ID); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("expected abort error")
+	}
+	problems, _ := store.ProblemMapper().FindProblemsByIds([]uint{problem.ID})
+	_, count, _ := store.SubmissionMapper().FindSubmissions(1, 10, nil)
+	if len(problems) != 1 || problems[0].Submitted != 0 || count != 0 {
+		t.Fatalf("transaction not rolled back: problems=%v submissions=%v", problems, count)
+	}
+	err = store.WithTransaction(func(tx *Store) error {
+		if err := tx.ProblemMapper().AddProblemSubmittedCountById(problem.ID); err != nil {
+			return err
+		}
+		return tx.UserMapper().AddUserSubmitCountById(user.ID)
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	user, _ = store.UserMapper().FindUserById(user.ID)
+	if user.Submitted != 1 {
+		t.Fatalf("expected committed counter, got %v", user.Submitted)
+	}
+}
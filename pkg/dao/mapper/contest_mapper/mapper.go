@@ -2,12 +2,15 @@ package contest_mapper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/ecnuvj/vhoj_common/pkg/common/constants/contest_status"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/user_mapper"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
 	"github.com/ecnuvj/vhoj_db/pkg/util"
 	"github.com/jinzhu/gorm"
+	"strings"
 	"time"
 )
 
@@ -18,6 +21,7 @@ type SearchContestCondition struct {
 }
 
 type IContestMapper interface {
+	WithContext(ctx context.Context) IContestMapper
 	CreateContest(*model.Contest, []*model.ContestProblem) (*model.Contest, error)
 	FindAllContests(int32, int32) ([]*model.Contest, int32, error)
 	FindContestById(uint) (*model.Contest, error)
@@ -41,51 +45,58 @@ type ContestMapperImpl struct {
 	DB *gorm.DB
 }
 
-func InitMapper(db *gorm.DB) {
-	ContestMapper = &ContestMapperImpl{
+func NewMapper(db *gorm.DB) IContestMapper {
+	return &ContestMapperImpl{
 		DB: db,
 	}
 }
 
+func InitMapper(db *gorm.DB) {
+	ContestMapper = NewMapper(db)
+}
+
+//返回绑定ctx的mapper 其所有查询都会随ctx取消或超时而中断
+func (c *ContestMapperImpl) WithContext(ctx context.Context) IContestMapper {
+	return NewMapper(util.WithContext(c.DB, ctx))
+}
+
 func (c *ContestMapperImpl) BatchSave(contestId uint, tableName string, column string, Ids []uint) error {
+	if len(Ids) == 0 {
+		return nil
+	}
 	var buffer bytes.Buffer
-	sql := fmt.Sprintf("insert into `%v` (`contest_id`,`%v`) values", tableName, column)
+	dialect := c.DB.Dialect()
+	sql := fmt.Sprintf("insert into %v (%v,%v) values ", dialect.Quote(tableName), dialect.Quote("contest_id"), dialect.Quote(column))
 	buffer.WriteString(sql)
+	values := make([]interface{}, 0, len(Ids)*2)
 	for i, id := range Ids {
-		buffer.WriteString(fmt.Sprintf("(%v,%v)", contestId, id))
-		if i == len(Ids)-1 {
-			buffer.WriteString(";")
-		} else {
+		buffer.WriteString("(?,?)")
+		if i != len(Ids)-1 {
 			buffer.WriteString(",")
 		}
+		values = append(values, contestId, id)
 	}
-	return c.DB.Exec(buffer.String()).Error
+	return errors.Wrap("contest_mapper.BatchSave", c.DB.Exec(buffer.String(), values...).Error)
 }
 
 func (c *ContestMapperImpl) CreateContest(contest *model.Contest, problems []*model.ContestProblem) (*model.Contest, error) {
-	tx := c.DB.Begin()
 	//避免更新user
 	user := contest.User
 	contest.User = nil
-	if err := tx.Create(contest).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	for _, p := range problems {
-		p.ContestId = contest.ID
-		if err := tx.Create(p).Error; err != nil {
-			tx.Rollback()
-			return nil, err
+	err := util.Transaction(c.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(contest).Error; err != nil {
+			return err
 		}
-	}
-	/*未commit之前find不到
-	contest, _ = c.FindContestById(contestId)
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
-	}
-	*/
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+		for _, p := range problems {
+			p.ContestId = contest.ID
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("contest_mapper.CreateContest", err)
 	}
 	contest.User = user
 	return contest, nil
@@ -103,12 +114,14 @@ func (c *ContestMapperImpl) FindAllContests(pageNo int32, PageSize int32) ([]*mo
 		Offset(offset).
 		Find(&contests)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("contest_mapper.FindAllContests", result.Error)
 	}
 	for i, con := range contests {
 		var contestProblems []*model.ContestProblem
-		c.DB.Table("contest_problems").Select("problem_id").Where("contest_id = ?", con.ID).Find(&contestProblems)
-		//fmt.Println(contestProblems)
+		err := c.DB.Table("contest_problems").Select("problem_id").Where("contest_id = ?", con.ID).Find(&contestProblems).Error
+		if err != nil {
+			return nil, 0, errors.Wrap("contest_mapper.FindContestsByCondition", err)
+		}
 		problemIds := make([]uint, len(contestProblems))
 		for ii, cp := range contestProblems {
 			problemIds[ii] = cp.ProblemId
@@ -135,14 +148,17 @@ func (c *ContestMapperImpl) FindContestsByCondition(condition *SearchContestCond
 		result = result.Where("end_time < ?", now)
 	}
 	if condition.Title != "" {
-		result = result.Where("title like ?", fmt.Sprintf("%%%v%%", condition.Title))
+		result = result.Where("LOWER(title) like ?", fmt.Sprintf("%%%v%%", strings.ToLower(condition.Title)))
 	}
 	if condition.CreatorName != "" {
-		user, err := user_mapper.UserMapper.FindUserByUsername(condition.CreatorName)
+		user, err := user_mapper.NewMapper(c.DB).FindUserByUsername(condition.CreatorName)
 		//没有此用户 直接返回
-		if err != nil || user == nil {
+		if errors.Is(err, errors.ErrNotFound) || (err == nil && user == nil) {
 			return contests, 0, nil
 		}
+		if err != nil {
+			return nil, 0, errors.Wrap("contest_mapper.FindContestsByCondition", err)
+		}
 		result = result.Where("user_id = ?", user.ID)
 	}
 	result = result.
@@ -152,12 +168,14 @@ func (c *ContestMapperImpl) FindContestsByCondition(condition *SearchContestCond
 		Offset(offset).
 		Find(&contests)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("contest_mapper.FindContestsByCondition", result.Error)
 	}
 	for i, con := range contests {
 		var contestProblems []*model.ContestProblem
-		c.DB.Table("contest_problems").Select("problem_id").Where("contest_id = ?", con.ID).Find(&contestProblems)
-		//fmt.Println(contestProblems)
+		err := c.DB.Table("contest_problems").Select("problem_id").Where("contest_id = ?", con.ID).Find(&contestProblems).Error
+		if err != nil {
+			return nil, 0, errors.Wrap("contest_mapper.FindContestsByCondition", err)
+		}
 		problemIds := make([]uint, len(contestProblems))
 		for ii, cp := range contestProblems {
 			problemIds[ii] = cp.ProblemId
@@ -175,7 +193,7 @@ func (c *ContestMapperImpl) FindContestById(contestId uint) (*model.Contest, err
 	}
 	result := c.DB.Model(contest).Preload("User").First(contest)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("contest_mapper.FindContestById", result.Error)
 	}
 	var contestProblems []*model.ContestProblem
 	c.DB.
@@ -194,7 +212,7 @@ func (c *ContestMapperImpl) FindContestById(contestId uint) (*model.Contest, err
 func (c *ContestMapperImpl) AddContestParticipants(contestId uint, userIds []uint) error {
 	err := c.BatchSave(contestId, "contest_participants", "user_id", userIds)
 	if err != nil {
-		return err
+		return errors.Wrap("contest_mapper.AddContestParticipants", err)
 	}
 	return nil
 }
@@ -202,7 +220,7 @@ func (c *ContestMapperImpl) AddContestParticipants(contestId uint, userIds []uin
 func (c *ContestMapperImpl) AddContestAdmins(contestId uint, userIds []uint) error {
 	err := c.BatchSave(contestId, "contest_admins", "user_id", userIds)
 	if err != nil {
-		return err
+		return errors.Wrap("contest_mapper.AddContestAdmins", err)
 	}
 	return nil
 }
@@ -214,7 +232,7 @@ func (c *ContestMapperImpl) FindContestAdmins(contestId uint) ([]uint, error) {
 		Where("contest_id = ?", contestId).
 		Find(&contestAdmins)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("contest_mapper.FindContestAdmins", result.Error)
 	}
 	userIds := make([]uint, len(contestAdmins))
 	for i, u := range contestAdmins {
@@ -230,7 +248,7 @@ func (c *ContestMapperImpl) FindContestParticipants(contestId uint) ([]uint, err
 		Where("contest_id = ?", contestId).
 		Find(&contestParticipants)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("contest_mapper.FindContestParticipants", result.Error)
 	}
 	userIds := make([]uint, len(contestParticipants))
 	for i, u := range contestParticipants {
@@ -246,7 +264,7 @@ func (c *ContestMapperImpl) AddContestProblem(contestId uint, problemId uint) er
 	}
 	result := c.DB.Create(contestProblem)
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("contest_mapper.AddContestProblem", result.Error)
 	}
 	return nil
 }
@@ -256,7 +274,7 @@ func (c *ContestMapperImpl) DeleteContestProblem(contestId uint, problemId uint)
 		Where("contest_id = ? and problem_id = ?", contestId, problemId).
 		Delete(&model.ContestProblem{})
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("contest_mapper.DeleteContestProblem", result.Error)
 	}
 	return nil
 }
@@ -266,40 +284,40 @@ func (c *ContestMapperImpl) DeleteContestAdmin(contestId uint, userId uint) erro
 		Where("contest_id = ? and user_id = ?", contestId, userId).
 		Delete(&model.ContestAdmin{})
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("contest_mapper.DeleteContestAdmin", result.Error)
 	}
 	return nil
 }
 
 func (c *ContestMapperImpl) UpdateContest(contest *model.Contest) (*model.Contest, error) {
 	if contest.ID == 0 {
-		return nil, fmt.Errorf("update contest need contest id")
+		return nil, errors.InvalidArgument("contest_mapper.UpdateContest", "update contest need contest id")
 	}
 	user := contest.User
 	contest.User = nil
 	result := c.DB.Model(&model.Contest{}).Update(contest)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("contest_mapper.UpdateContest", result.Error)
 	}
 	contest.User = user
 	return contest, nil
 }
 
 func (c *ContestMapperImpl) UpdateContestProblems(contestId uint, problems []*model.ContestProblem) ([]*model.ContestProblem, error) {
-	tx := c.DB.Begin()
-	if err := tx.Where("contest_id = ?", contestId).Delete(&model.ContestProblem{}).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	for _, p := range problems {
-		p.ContestId = contestId
-		if err := tx.Create(p).Error; err != nil {
-			tx.Rollback()
-			return nil, err
+	err := util.Transaction(c.DB, func(tx *gorm.DB) error {
+		if err := tx.Where("contest_id = ?", contestId).Delete(&model.ContestProblem{}).Error; err != nil {
+			return err
 		}
-	}
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+		for _, p := range problems {
+			p.ContestId = contestId
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("contest_mapper.UpdateContestProblems", err)
 	}
 	return problems, nil
 }
@@ -311,7 +329,7 @@ func (c *ContestMapperImpl) FindContestProblems(contestId uint) ([]*model.Contes
 		Where("contest_id = ?", contestId).
 		Find(&contestProblems)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("contest_mapper.FindContestProblems", result.Error)
 	}
 	return contestProblems, nil
 }
@@ -323,7 +341,7 @@ func (c *ContestMapperImpl) FindUserContests(userId uint, pageNo int32, pageSize
 		Where("user_id = ?", userId).
 		Find(&contestAdmins)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("contest_mapper.FindUserContests", result.Error)
 	}
 	contestIds := make([]uint, len(contestAdmins))
 	for i, c := range contestAdmins {
@@ -342,7 +360,7 @@ func (c *ContestMapperImpl) FindUserContests(userId uint, pageNo int32, pageSize
 		Offset(offset).
 		Find(&contests)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("contest_mapper.FindUserContests", result.Error)
 	}
 	return contests, count, nil
 }
@@ -0,0 +1,193 @@
+package mapper
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/contest_status"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/contest_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"testing"
+	"time"
+)
+
+func TestContestMapperImpl_CreateContest(t *testing.T) {
+	store, f := setup(t)
+	creator := f.Users[0]
+	contest, err := store.ContestMapper().CreateContest(&model.Contest{
+		Title:     "weekly",
+		UserId:    creator.ID,
+		User:      creator,
+		StartTime: time.Now().Add(time.Hour),
+		EndTime:   time.Now().Add(2 * time.Hour),
+	}, []*model.ContestProblem{
+		{ProblemId: f.Problems[2].ID, ProblemOrder: "A"},
+	})
+	assertNoError(t, err)
+	if contest.ID == 0 || contest.User != creator {
+		t.Fatalf("unexpected contest: %+v", contest)
+	}
+	found, err := store.ContestMapper().FindContestById(contest.ID)
+	assertNoError(t, err)
+	if len(found.ProblemIds) != 1 || found.ProblemIds[0] != f.Problems[2].ID || found.User.Nickname != "alice" {
+		t.Fatalf("unexpected stored contest: %+v", found)
+	}
+	//题号重复时整个比赛回滚
+	_, err = store.ContestMapper().CreateContest(&model.Contest{Title: "broken", UserId: creator.ID}, []*model.ContestProblem{
+		{ProblemId: f.Problems[0].ID, ProblemOrder: "A"},
+		{ProblemId: f.Problems[1].ID, ProblemOrder: "A"},
+	})
+	if !errors.Is(err, errors.ErrDuplicate) {
+		t.Fatalf("expected duplicate problem order, got %v", err)
+	}
+	_, count, err := store.ContestMapper().FindAllContests(1, 10)
+	assertNoError(t, err)
+	if count != 2 {
+		t.Fatalf("failed contest should be rolled back, got %v contests", count)
+	}
+}
+
+func TestContestMapperImpl_FindAllContests(t *testing.T) {
+	store, f := setup(t)
+	contests, count, err := store.ContestMapper().FindAllContests(1, 10)
+	assertNoError(t, err)
+	if count != 1 || len(contests[0].ProblemIds) != 2 || contests[0].User.ID != f.Users[0].ID {
+		t.Fatalf("unexpected contests: count=%v %+v", count, contests)
+	}
+}
+
+func TestContestMapperImpl_FindContestById(t *testing.T) {
+	store, f := setup(t)
+	contest, err := store.ContestMapper().FindContestById(f.Contests[0].ID)
+	assertNoError(t, err)
+	if contest.Title != "fixture contest" || len(contest.ProblemIds) != 2 {
+		t.Fatalf("unexpected contest: %+v", contest)
+	}
+	if _, err = store.ContestMapper().FindContestById(9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestContestMapperImpl_FindContestsByCondition(t *testing.T) {
+	store, f := setup(t)
+	cases := []struct {
+		condition *contest_mapper.SearchContestCondition
+		count     int32
+	}{
+		{nil, 1},
+		{&contest_mapper.SearchContestCondition{Status: contest_status.RUNNING}, 1},
+		{&contest_mapper.SearchContestCondition{Status: contest_status.ENDED}, 0},
+		{&contest_mapper.SearchContestCondition{Status: contest_status.SCHEDULED}, 0},
+		{&contest_mapper.SearchContestCondition{Title: "FIXTURE"}, 1},
+		{&contest_mapper.SearchContestCondition{CreatorName: "alice"}, 1},
+		{&contest_mapper.SearchContestCondition{CreatorName: "bob"}, 0},
+		{&contest_mapper.SearchContestCondition{CreatorName: "nobody"}, 0},
+	}
+	for _, c := range cases {
+		contests, count, err := store.ContestMapper().FindContestsByCondition(c.condition, 1, 10)
+		assertNoError(t, err)
+		if count != c.count {
+			t.Fatalf("condition %+v: expected %v, got %v", c.condition, c.count, count)
+		}
+		if count > 0 && contests[0].ID != f.Contests[0].ID {
+			t.Fatalf("condition %+v: unexpected contest %+v", c.condition, contests[0])
+		}
+	}
+	//查找创建者失败时不能当作没有此用户
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	condition := &contest_mapper.SearchContestCondition{CreatorName: "alice"}
+	if _, _, err := store.ContestMapper().WithContext(ctx).FindContestsByCondition(condition, 1, 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+}
+
+func TestContestMapperImpl_AdminsAndParticipants(t *testing.T) {
+	store, f := setup(t)
+	contestId := f.Contests[0].ID
+	assertNoError(t, store.ContestMapper().AddContestAdmins(contestId, []uint{f.Users[2].ID}))
+	assertNoError(t, store.ContestMapper().AddContestParticipants(contestId, []uint{f.Users[1].ID}))
+	assertNoError(t, store.ContestMapper().AddContestParticipants(contestId, nil))
+	admins, err := store.ContestMapper().FindContestAdmins(contestId)
+	assertNoError(t, err)
+	if len(admins) != 2 {
+		t.Fatalf("expected 2 admins, got %v", admins)
+	}
+	participants, err := store.ContestMapper().FindContestParticipants(contestId)
+	assertNoError(t, err)
+	if len(participants) != 2 {
+		t.Fatalf("expected 2 participants, got %v", participants)
+	}
+	assertNoError(t, store.ContestMapper().DeleteContestAdmin(contestId, f.Users[1].ID))
+	admins, err = store.ContestMapper().FindContestAdmins(contestId)
+	assertNoError(t, err)
+	if len(admins) != 1 || admins[0] != f.Users[2].ID {
+		t.Fatalf("admin not deleted: %v", admins)
+	}
+}
+
+func TestContestMapperImpl_ContestProblems(t *testing.T) {
+	store, f := setup(t)
+	contestId := f.Contests[0].ID
+	assertNoError(t, store.ContestMapper().AddContestProblem(contestId, f.Problems[2].ID))
+	problems, err := store.ContestMapper().FindContestProblems(contestId)
+	assertNoError(t, err)
+	if len(problems) != 3 {
+		t.Fatalf("expected 3 contest problems, got %+v", problems)
+	}
+	assertNoError(t, store.ContestMapper().DeleteContestProblem(contestId, f.Problems[0].ID))
+	problems, err = store.ContestMapper().FindContestProblems(contestId)
+	assertNoError(t, err)
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 contest problems, got %+v", problems)
+	}
+	updated, err := store.ContestMapper().UpdateContestProblems(contestId, []*model.ContestProblem{
+		{ProblemId: f.Problems[2].ID, ProblemOrder: "A"},
+	})
+	assertNoError(t, err)
+	if updated[0].ContestId != contestId {
+		t.Fatalf("contest id not set: %+v", updated[0])
+	}
+	problems, err = store.ContestMapper().FindContestProblems(contestId)
+	assertNoError(t, err)
+	if len(problems) != 1 || problems[0].ProblemId != f.Problems[2].ID {
+		t.Fatalf("contest problems not replaced: %+v", problems)
+	}
+}
+
+func TestContestMapperImpl_UpdateContest(t *testing.T) {
+	store, f := setup(t)
+	contest, err := store.ContestMapper().FindContestById(f.Contests[0].ID)
+	assertNoError(t, err)
+	contest.Title = contest.Title + "-test"
+	_, err = store.ContestMapper().UpdateContest(contest)
+	assertNoError(t, err)
+	if contest.User == nil {
+		t.Fatal("user should be restored after update")
+	}
+	found, err := store.ContestMapper().FindContestById(contest.ID)
+	assertNoError(t, err)
+	if found.Title != "fixture contest-test" {
+		t.Fatalf("title not updated: %v", found.Title)
+	}
+	if _, err = store.ContestMapper().UpdateContest(&model.Contest{Title: "no id"}); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestContestMapperImpl_FindUserContests(t *testing.T) {
+	store, f := setup(t)
+	for _, c := range []struct {
+		user  *model.User
+		count int32
+	}{
+		{f.Users[0], 1}, //创建者
+		{f.Users[1], 1}, //管理员
+		{f.Users[2], 0}, //参与者
+	} {
+		_, count, err := store.ContestMapper().FindUserContests(c.user.ID, 1, 10)
+		assertNoError(t, err)
+		if count != c.count {
+			t.Fatalf("user %v: expected %v contests, got %v", c.user.Nickname, c.count, count)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+)
+
+func (d *Data) problemAssets(rawProblemId uint) []*model.ProblemAsset {
+	assets := make([]*model.ProblemAsset, 0)
+	for _, asset := range d.assets {
+		if asset.RawProblemId == rawProblemId {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
+
+func (p *ProblemMapper) RegisterProblemAsset(asset *model.ProblemAsset) (*model.ProblemAsset, error) {
+	if asset.RawProblemId <= 0 || asset.SourceUrl == "" || asset.LocalUrl == "" {
+		return nil, errors.InvalidArgument("problem_mapper.RegisterProblemAsset", "raw problem id, source url and local url are required")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.RegisterProblemAsset"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if rawProblem := d.rawProblems[asset.RawProblemId]; rawProblem == nil || rawProblem.DeletedAt != nil {
+		return nil, notFound("problem_mapper.RegisterProblemAsset")
+	}
+	for _, stored := range d.assets {
+		if stored.RawProblemId == asset.RawProblemId && stored.SourceUrl == asset.SourceUrl {
+			asset.ID, asset.CreatedAt, asset.UpdatedAt = stored.ID, stored.CreatedAt, now()
+			*stored = *asset
+			return asset, nil
+		}
+	}
+	asset.ID = d.id("problem_assets")
+	asset.CreatedAt, asset.UpdatedAt = now(), now()
+	stored := *asset
+	d.assets = append(d.assets, &stored)
+	return asset, nil
+}
+
+func (p *ProblemMapper) FindProblemAssets(rawProblemId uint) ([]*model.ProblemAsset, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemAssets"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	assets := make([]*model.ProblemAsset, 0)
+	for _, a := range p.data.problemAssets(rawProblemId) {
+		asset := *a
+		assets = append(assets, &asset)
+	}
+	return assets, nil
+}
+
+func (p *ProblemMapper) RewriteAssetUrls(rawProblemId uint) (*model.RawProblem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.RewriteAssetUrls"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	stored := p.data.rawProblems[rawProblemId]
+	if stored == nil || stored.DeletedAt != nil {
+		p.data.mu.Unlock()
+		return nil, notFound("problem_mapper.RewriteAssetUrls")
+	}
+	rawProblem := *stored
+	p.data.mu.Unlock()
+	return p.AddOrModifyRawProblem(&rawProblem)
+}
@@ -0,0 +1,365 @@
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/contest_status"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/contest_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"strings"
+)
+
+type ContestMapper struct {
+	data *Data
+	ctx  context.Context
+}
+
+func NewContestMapper(data *Data) contest_mapper.IContestMapper {
+	return &ContestMapper{data: data}
+}
+
+func (c *ContestMapper) WithContext(ctx context.Context) contest_mapper.IContestMapper {
+	return &ContestMapper{data: c.data, ctx: ctx}
+}
+
+func (c *ContestMapper) CreateContest(contest *model.Contest, problems []*model.ContestProblem) (*model.Contest, error) {
+	if err := checkContext(c.ctx, "contest_mapper.CreateContest"); err != nil {
+		return nil, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	//新比赛的id尚未分配 只需检查题目之间的序号冲突
+	if !uniqueOrders(problems) {
+		return nil, duplicate("contest_mapper.CreateContest", "uni_idx_pod")
+	}
+	contest.ID = d.id("contests")
+	contest.CreatedAt, contest.UpdatedAt = now(), now()
+	stored := *contest
+	stored.User, stored.ProblemIds = nil, nil
+	d.contests[stored.ID] = &stored
+	for _, p := range problems {
+		p.ContestId = contest.ID
+		cp := *p
+		d.contestProblems = append(d.contestProblems, &cp)
+	}
+	return contest, nil
+}
+
+func (c *ContestMapper) FindAllContests(pageNo int32, pageSize int32) ([]*model.Contest, int32, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindAllContests"); err != nil {
+		return nil, 0, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pageContests(d.allContests(), pageNo, pageSize, true)
+}
+
+func (c *ContestMapper) FindContestsByCondition(condition *contest_mapper.SearchContestCondition, pageNo int32, pageSize int32) ([]*model.Contest, int32, error) {
+	if condition == nil || (condition.Title == "" && condition.Status == 0 && condition.CreatorName == "") {
+		return c.FindAllContests(pageNo, pageSize)
+	}
+	if err := checkContext(c.ctx, "contest_mapper.FindContestsByCondition"); err != nil {
+		return nil, 0, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var creatorId uint
+	if condition.CreatorName != "" {
+		creator := d.userByNickname(condition.CreatorName)
+		//没有此用户 直接返回
+		if creator == nil || d.userAuth(creator.ID) == nil {
+			return nil, 0, nil
+		}
+		creatorId = creator.ID
+	}
+	title := strings.ToLower(condition.Title)
+	current := now()
+	contests := make([]*model.Contest, 0)
+	for _, contest := range d.allContests() {
+		switch condition.Status {
+		case contest_status.SCHEDULED:
+			if !contest.StartTime.After(current) {
+				continue
+			}
+		case contest_status.RUNNING:
+			if !(contest.StartTime.Before(current) && current.Before(contest.EndTime)) {
+				continue
+			}
+		case contest_status.ENDED:
+			if !contest.EndTime.Before(current) {
+				continue
+			}
+		}
+		if !strings.Contains(strings.ToLower(contest.Title), title) {
+			continue
+		}
+		if creatorId != 0 && contest.UserId != creatorId {
+			continue
+		}
+		contests = append(contests, contest)
+	}
+	return d.pageContests(contests, pageNo, pageSize, true)
+}
+
+func (c *ContestMapper) FindContestById(contestId uint) (*model.Contest, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindContestById"); err != nil {
+		return nil, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	contest := d.contest(contestId)
+	if contest == nil {
+		return nil, notFound("contest_mapper.FindContestById")
+	}
+	return d.loadContest(contest, true), nil
+}
+
+func (c *ContestMapper) FindContestAdmins(contestId uint) ([]uint, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindContestAdmins"); err != nil {
+		return nil, err
+	}
+	c.data.mu.Lock()
+	defer c.data.mu.Unlock()
+	userIds := make([]uint, 0)
+	for _, admin := range c.data.contestAdmins {
+		if admin.ContestId == contestId {
+			userIds = append(userIds, admin.UserId)
+		}
+	}
+	return userIds, nil
+}
+
+func (c *ContestMapper) FindContestParticipants(contestId uint) ([]uint, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindContestParticipants"); err != nil {
+		return nil, err
+	}
+	c.data.mu.Lock()
+	defer c.data.mu.Unlock()
+	userIds := make([]uint, 0)
+	for _, participant := range c.data.participants {
+		if participant.ContestId == contestId {
+			userIds = append(userIds, participant.UserId)
+		}
+	}
+	return userIds, nil
+}
+
+func (c *ContestMapper) FindContestProblems(contestId uint) ([]*model.ContestProblem, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindContestProblems"); err != nil {
+		return nil, err
+	}
+	c.data.mu.Lock()
+	defer c.data.mu.Unlock()
+	problems := make([]*model.ContestProblem, 0)
+	for _, cp := range c.data.contestProblems {
+		if cp.ContestId == contestId {
+			p := *cp
+			problems = append(problems, &p)
+		}
+	}
+	return problems, nil
+}
+
+func (c *ContestMapper) FindUserContests(userId uint, pageNo int32, pageSize int32) ([]*model.Contest, int32, error) {
+	if err := checkContext(c.ctx, "contest_mapper.FindUserContests"); err != nil {
+		return nil, 0, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	adminOf := make(map[uint]bool)
+	for _, admin := range d.contestAdmins {
+		if admin.UserId == userId {
+			adminOf[admin.ContestId] = true
+		}
+	}
+	contests := make([]*model.Contest, 0)
+	for _, contest := range d.allContests() {
+		if contest.UserId == userId || adminOf[contest.ID] {
+			contests = append(contests, contest)
+		}
+	}
+	return d.pageContests(contests, pageNo, pageSize, false)
+}
+
+func (c *ContestMapper) AddContestParticipants(contestId uint, userIds []uint) error {
+	if err := checkContext(c.ctx, "contest_mapper.AddContestParticipants"); err != nil {
+		return err
+	}
+	c.data.mu.Lock()
+	defer c.data.mu.Unlock()
+	for _, userId := range userIds {
+		c.data.participants = append(c.data.participants, &model.ContestParticipant{ContestId: contestId, UserId: userId})
+	}
+	return nil
+}
+
+func (c *ContestMapper) AddContestAdmins(contestId uint, userIds []uint) error {
+	if err := checkContext(c.ctx, "contest_mapper.AddContestAdmins"); err != nil {
+		return err
+	}
+	c.data.mu.Lock()
+	defer c.data.mu.Unlock()
+	for _, userId := range userIds {
+		c.data.contestAdmins = append(c.data.contestAdmins, &model.ContestAdmin{ContestId: contestId, UserId: userId})
+	}
+	return nil
+}
+
+func (c *ContestMapper) AddContestProblem(contestId uint, problemId uint) error {
+	if err := checkContext(c.ctx, "contest_mapper.AddContestProblem"); err != nil {
+		return err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, cp := range d.contestProblems {
+		if cp.ContestId == contestId && cp.ProblemOrder == "" {
+			return duplicate("contest_mapper.AddContestProblem", "uni_idx_pod")
+		}
+	}
+	d.contestProblems = append(d.contestProblems, &model.ContestProblem{ContestId: contestId, ProblemId: problemId})
+	return nil
+}
+
+func (c *ContestMapper) DeleteContestProblem(contestId uint, problemId uint) error {
+	if err := checkContext(c.ctx, "contest_mapper.DeleteContestProblem"); err != nil {
+		return err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.contestProblems[:0]
+	for _, cp := range d.contestProblems {
+		if !(cp.ContestId == contestId && cp.ProblemId == problemId) {
+			kept = append(kept, cp)
+		}
+	}
+	d.contestProblems = kept
+	return nil
+}
+
+func (c *ContestMapper) DeleteContestAdmin(contestId uint, userId uint) error {
+	if err := checkContext(c.ctx, "contest_mapper.DeleteContestAdmin"); err != nil {
+		return err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.contestAdmins[:0]
+	for _, admin := range d.contestAdmins {
+		if !(admin.ContestId == contestId && admin.UserId == userId) {
+			kept = append(kept, admin)
+		}
+	}
+	d.contestAdmins = kept
+	return nil
+}
+
+func (c *ContestMapper) UpdateContest(contest *model.Contest) (*model.Contest, error) {
+	if contest.ID == 0 {
+		return nil, errors.InvalidArgument("contest_mapper.UpdateContest", "update contest need contest id")
+	}
+	if err := checkContext(c.ctx, "contest_mapper.UpdateContest"); err != nil {
+		return nil, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stored := d.contest(contest.ID); stored != nil {
+		mergeNonZero(stored, contest)
+		stored.UpdatedAt = now()
+	}
+	return contest, nil
+}
+
+func (c *ContestMapper) UpdateContestProblems(contestId uint, problems []*model.ContestProblem) ([]*model.ContestProblem, error) {
+	if err := checkContext(c.ctx, "contest_mapper.UpdateContestProblems"); err != nil {
+		return nil, err
+	}
+	d := c.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !uniqueOrders(problems) {
+		return nil, duplicate("contest_mapper.UpdateContestProblems", "uni_idx_pod")
+	}
+	kept := d.contestProblems[:0]
+	for _, cp := range d.contestProblems {
+		if cp.ContestId != contestId {
+			kept = append(kept, cp)
+		}
+	}
+	d.contestProblems = kept
+	for _, p := range problems {
+		p.ContestId = contestId
+		cp := *p
+		d.contestProblems = append(d.contestProblems, &cp)
+	}
+	return problems, nil
+}
+
+func uniqueOrders(problems []*model.ContestProblem) bool {
+	orders := make(map[string]bool, len(problems))
+	for _, p := range problems {
+		if orders[p.ProblemOrder] {
+			return false
+		}
+		orders[p.ProblemOrder] = true
+	}
+	return true
+}
+
+func (d *Data) allContests() []*model.Contest {
+	ids := make([]uint, 0, len(d.contests))
+	for id, contest := range d.contests {
+		if contest.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	contests := make([]*model.Contest, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		contests = append(contests, d.contests[id])
+	}
+	return contests
+}
+
+func (d *Data) contest(contestId uint) *model.Contest {
+	contest, ok := d.contests[contestId]
+	if !ok || contest.DeletedAt != nil {
+		return nil
+	}
+	return contest
+}
+
+func (d *Data) pageContests(contests []*model.Contest, pageNo int32, pageSize int32, withProblems bool) ([]*model.Contest, int32, error) {
+	left, right := pageRange(pageNo, pageSize, len(contests))
+	ret := make([]*model.Contest, 0, right-left)
+	for _, contest := range contests[left:right] {
+		ret = append(ret, d.loadContest(contest, withProblems))
+	}
+	return ret, int32(len(contests)), nil
+}
+
+//返回比赛副本 带上创建者 withProblems时带上ProblemIds
+func (d *Data) loadContest(contest *model.Contest, withProblems bool) *model.Contest {
+	ret := *contest
+	ret.User, ret.ProblemIds = nil, nil
+	if user := d.user(contest.UserId); user != nil {
+		u := *user
+		u.UserAuth, u.Roles = nil, nil
+		ret.User = &u
+	}
+	if withProblems {
+		ret.ProblemIds = make([]uint, 0)
+		for _, cp := range d.contestProblems {
+			if cp.ContestId == contest.ID {
+				ret.ProblemIds = append(ret.ProblemIds, cp.ProblemId)
+			}
+		}
+	}
+	return &ret
+}
@@ -0,0 +1,184 @@
+//mapperfake 提供四个mapper接口的内存实现 供依赖mapper的服务做单元测试
+//行为与数据库实现保持一致: 分页使用util.CalLimitOffset 唯一索引冲突返回errors.ErrDuplicate
+//gorm.Model的记录软删除 计数器原地累加
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/contest_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/submission_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/user_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Data 是四个fake mapper共享的内存数据 相当于一个数据库
+type Data struct {
+	mu     sync.Mutex
+	nextId map[string]uint
+
+	users           map[uint]*model.User
+	userAuths       map[uint]*model.UserAuth
+	roles           map[uint]*model.Role
+	userRoles       []*model.UserRole
+	rawProblems     map[uint]*model.RawProblem
+	revisions       []*model.RawProblemRevision
+	samples         []*model.ProblemSample
+	assets          []*model.ProblemAsset
+	translations    map[uint]*model.RawProblemTranslation
+	fingerprints    map[uint]*model.ProblemFingerprint
+	suggestions     map[uint]*model.GroupSuggestion
+	problemGroups   map[uint]*model.ProblemGroup
+	problems        map[uint]*model.Problem
+	tags            map[uint]*model.Tag
+	problemTags     []*model.ProblemTag
+	problemSolvers  []*model.ProblemSolver
+	contests        map[uint]*model.Contest
+	contestProblems []*model.ContestProblem
+	contestAdmins   []*model.ContestAdmin
+	participants    []*model.ContestParticipant
+	submissions     map[uint]*model.Submission
+	submissionCodes map[uint]*model.SubmissionCode
+	compileInfos    map[uint]*model.CompileInfo
+}
+
+func NewData() *Data {
+	return &Data{
+		nextId:          map[string]uint{},
+		users:           map[uint]*model.User{},
+		userAuths:       map[uint]*model.UserAuth{},
+		roles:           map[uint]*model.Role{},
+		rawProblems:     map[uint]*model.RawProblem{},
+		translations:    map[uint]*model.RawProblemTranslation{},
+		fingerprints:    map[uint]*model.ProblemFingerprint{},
+		suggestions:     map[uint]*model.GroupSuggestion{},
+		problemGroups:   map[uint]*model.ProblemGroup{},
+		problems:        map[uint]*model.Problem{},
+		tags:            map[uint]*model.Tag{},
+		contests:        map[uint]*model.Contest{},
+		submissions:     map[uint]*model.Submission{},
+		submissionCodes: map[uint]*model.SubmissionCode{},
+		compileInfos:    map[uint]*model.CompileInfo{},
+	}
+}
+
+//新建一份Data 并把四个mapper全局变量替换为基于它的fake实现
+func InitMapper() *Data {
+	data := NewData()
+	problem_mapper.ProblemMapper = NewProblemMapper(data)
+	user_mapper.UserMapper = NewUserMapper(data)
+	contest_mapper.ContestMapper = NewContestMapper(data)
+	submission_mapper.SubmissionMapper = NewSubmissionMapper(data)
+	return data
+}
+
+//返回提交的编译信息 便于断言UpdateSubmissionCEInfoById
+func (d *Data) CompileInfo(submissionId uint) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	info, ok := d.compileInfos[submissionId]
+	if !ok {
+		return "", false
+	}
+	return info.Info, true
+}
+
+func (d *Data) id(table string) uint {
+	d.nextId[table]++
+	return d.nextId[table]
+}
+
+func checkContext(ctx context.Context, op string) error {
+	if ctx != nil && ctx.Err() != nil {
+		return errors.Wrap(op, ctx.Err())
+	}
+	return nil
+}
+
+func duplicate(op string, index string) error {
+	return &errors.Error{Kind: errors.ErrDuplicate, Op: op, Index: index}
+}
+
+func notFound(op string) error {
+	return &errors.Error{Kind: errors.ErrNotFound, Op: op}
+}
+
+func now() time.Time {
+	return time.Now()
+}
+
+func sortedIds(ids []uint) []uint {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+//按util.CalLimitOffset计算切片范围
+func pageRange(pageNo int32, pageSize int32, total int) (int, int) {
+	limit, offset := util.CalLimitOffset(pageNo, pageSize)
+	left, right := util.CalSliceLeftRight(limit, offset, int32(total))
+	return int(left), int(right)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//与gorm的Update(struct)一致 只复制非零值的普通字段 跳过内嵌的gorm.Model、关联与gorm:"-"字段
+func mergeNonZero(dst interface{}, src interface{}) {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.Anonymous || field.PkgPath != "" || field.Tag.Get("gorm") == "-" {
+			continue
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Ptr || kind == reflect.Slice || kind == reflect.Map ||
+			(kind == reflect.Struct && field.Type != timeType) {
+			continue
+		}
+		value := sv.Field(i)
+		if value.IsZero() {
+			continue
+		}
+		dv.Field(i).Set(value)
+	}
+}
+
+//模拟util.KeysetQuery 返回按游标方向排好序的下标 最多pageSize+1个
+func keysetSelect(n int, key func(i int) (time.Time, uint), cursor *util.Cursor, pageSize int32) []int {
+	less := func(i, j int) bool {
+		ti, idi := key(i)
+		tj, idj := key(j)
+		return ti.Before(tj) || (ti.Equal(tj) && idi < idj)
+	}
+	indexes := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if cursor == nil {
+			indexes = append(indexes, i)
+			continue
+		}
+		at, id := key(i)
+		after := at.After(cursor.UpdatedAt) || (at.Equal(cursor.UpdatedAt) && id > cursor.Id)
+		before := at.Before(cursor.UpdatedAt) || (at.Equal(cursor.UpdatedAt) && id < cursor.Id)
+		if (cursor.Prev && after) || (!cursor.Prev && before) {
+			indexes = append(indexes, i)
+		}
+	}
+	backward := cursor != nil && cursor.Prev
+	sort.Slice(indexes, func(a, b int) bool {
+		if backward {
+			return less(indexes[a], indexes[b])
+		}
+		return less(indexes[b], indexes[a])
+	})
+	if limit := int(util.CursorPageSize(pageSize)) + 1; len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	return indexes
+}
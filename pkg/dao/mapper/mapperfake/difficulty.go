@@ -0,0 +1,66 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/difficulty"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+)
+
+func (p *ProblemMapper) AddProblemSolver(problemId uint, userId uint) (bool, error) {
+	if problemId <= 0 || userId <= 0 {
+		return false, errors.InvalidArgument("problem_mapper.AddProblemSolver", "problem id or user id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.AddProblemSolver"); err != nil {
+		return false, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, solver := range d.problemSolvers {
+		if solver.ProblemId == problemId && solver.UserId == userId {
+			return false, nil
+		}
+	}
+	user := d.user(userId)
+	if user == nil {
+		return false, notFound("problem_mapper.AddProblemSolver")
+	}
+	d.problemSolvers = append(d.problemSolvers, &model.ProblemSolver{ProblemId: problemId, UserId: userId, CreatedAt: now()})
+	if problem := d.problem(problemId); problem != nil {
+		problem.Solvers++
+		problem.SolverStrength += difficulty.Strength(user.Accepted)
+		updateDifficulty(problem)
+	}
+	return true, nil
+}
+
+func (p *ProblemMapper) RecomputeDifficulty(problemId uint) (int32, error) {
+	if err := checkContext(p.ctx, "problem_mapper.RecomputeDifficulty"); err != nil {
+		return 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problem := d.problem(problemId)
+	if problem == nil {
+		return 0, notFound("problem_mapper.RecomputeDifficulty")
+	}
+	problem.Solvers, problem.SolverStrength = 0, 0
+	for _, solver := range d.problemSolvers {
+		if user := d.user(solver.UserId); user != nil && solver.ProblemId == problemId {
+			problem.Solvers++
+			problem.SolverStrength += difficulty.Strength(user.Accepted)
+		}
+	}
+	updateDifficulty(problem)
+	return problem.Difficulty, nil
+}
+
+func updateDifficulty(problem *model.Problem) {
+	problem.Difficulty = difficulty.Rate(difficulty.Stats{
+		Submitted:      problem.Submitted,
+		Accepted:       problem.Accepted,
+		Solvers:        problem.Solvers,
+		SolverStrength: problem.SolverStrength,
+	})
+}
@@ -0,0 +1,262 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/difficulty"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"sort"
+)
+
+func (p *ProblemMapper) MergeProblemGroups(targetGroupId uint, sourceGroupId uint) (*model.Problem, error) {
+	if targetGroupId <= 0 || sourceGroupId <= 0 || targetGroupId == sourceGroupId {
+		return nil, errors.InvalidArgument("problem_mapper.MergeProblemGroups", "two different group ids are required")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.MergeProblemGroups"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	problemId, err := d.mergeProblemGroups(targetGroupId, sourceGroupId)
+	d.mu.Unlock()
+	if err != nil || problemId == 0 {
+		return nil, err
+	}
+	return p.FindProblemById(problemId)
+}
+
+//调用方需持有d.mu
+func (d *Data) mergeProblemGroups(targetGroupId uint, sourceGroupId uint) (uint, error) {
+	for _, groupId := range []uint{targetGroupId, sourceGroupId} {
+		if len(d.groupMembers(groupId)) == 0 {
+			return 0, errors.NotFound("problem_mapper.MergeProblemGroups", "group %v has no raw problems", groupId)
+		}
+	}
+	for _, group := range d.groupMembers(sourceGroupId) {
+		group.GroupId, group.MainProblem = targetGroupId, false
+		group.UpdatedAt = now()
+	}
+	target, source := d.problemByGroupId(targetGroupId), d.problemByGroupId(sourceGroupId)
+	var problem *model.Problem
+	switch {
+	case target == nil && source == nil:
+		return 0, nil
+	case target == nil:
+		problem = source
+		problem.GroupId = targetGroupId
+		problem.UpdatedAt = now()
+	case source == nil:
+		problem = target
+	default:
+		problem = target
+		d.mergeProblems(target, source)
+	}
+	d.markMainProblem(targetGroupId, problem.RawProblemId)
+	return problem.ID, nil
+}
+
+func (d *Data) mergeProblems(target *model.Problem, source *model.Problem) {
+	for _, submission := range d.submissions {
+		if submission.ProblemId == source.ID {
+			submission.ProblemId = target.ID
+		}
+	}
+	for _, contestProblem := range d.contestProblems {
+		if contestProblem.ProblemId == source.ID {
+			contestProblem.ProblemId = target.ID
+		}
+	}
+	problemTags := d.problemTags[:0]
+	for _, problemTag := range d.problemTags {
+		if problemTag.ProblemId == source.ID {
+			if d.hasProblemTag(target.ID, problemTag.TagId) {
+				continue
+			}
+			problemTag.ProblemId = target.ID
+		}
+		problemTags = append(problemTags, problemTag)
+	}
+	d.problemTags = problemTags
+	solvers := d.problemSolvers[:0]
+	for _, solver := range d.problemSolvers {
+		if solver.ProblemId == source.ID {
+			if d.hasProblemSolver(target.ID, solver.UserId) {
+				continue
+			}
+			solver.ProblemId = target.ID
+		}
+		solvers = append(solvers, solver)
+	}
+	d.problemSolvers = solvers
+	target.Submitted += source.Submitted
+	target.Accepted += source.Accepted
+	target.Solvers, target.SolverStrength = 0, 0
+	for _, solver := range d.problemSolvers {
+		if user := d.user(solver.UserId); user != nil && solver.ProblemId == target.ID {
+			target.Solvers++
+			target.SolverStrength += difficulty.Strength(user.Accepted)
+		}
+	}
+	updateDifficulty(target)
+	target.UpdatedAt = now()
+	deletedAt := now()
+	source.DeletedAt = &deletedAt
+}
+
+func (d *Data) hasProblemTag(problemId uint, tagId uint) bool {
+	for _, problemTag := range d.problemTags {
+		if problemTag.ProblemId == problemId && problemTag.TagId == tagId {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Data) hasProblemSolver(problemId uint, userId uint) bool {
+	for _, solver := range d.problemSolvers {
+		if solver.ProblemId == problemId && solver.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ProblemMapper) SplitProblemGroup(rawProblemId uint) (*model.Problem, error) {
+	if rawProblemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.SplitProblemGroup", "raw problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.SplitProblemGroup"); err != nil {
+		return nil, err
+	}
+	problemId, err := p.data.splitProblemGroup(rawProblemId)
+	if err != nil {
+		return nil, err
+	}
+	return p.FindProblemById(problemId)
+}
+
+func (d *Data) splitProblemGroup(rawProblemId uint) (uint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	member := d.groupByRawProblemId(rawProblemId)
+	if member == nil {
+		return 0, notFound("problem_mapper.SplitProblemGroup")
+	}
+	if len(d.groupMembers(member.GroupId)) == 1 {
+		return 0, errors.Conflict("problem_mapper.SplitProblemGroup", "raw problem %v is already alone in its group", rawProblemId)
+	}
+	groupId := d.freeGroupId(rawProblemId)
+	if err := d.moveToGroup(rawProblemId, groupId); err != nil {
+		return 0, err
+	}
+	return d.problemByGroupId(groupId).ID, nil
+}
+
+//与数据库实现的moveToGroup一致 调用方需持有d.mu
+func (d *Data) moveToGroup(rawProblemId uint, groupId uint) error {
+	member := d.groupByRawProblemId(rawProblemId)
+	if member == nil {
+		return notFound("problem_mapper.UpdateProblemGroup")
+	}
+	oldGroupId := member.GroupId
+	if oldGroupId != groupId {
+		member.GroupId, member.MainProblem = groupId, false
+		member.UpdatedAt = now()
+		d.repairGroup(oldGroupId, rawProblemId, groupId)
+	}
+	problem := d.problemByGroupId(groupId)
+	if problem == nil {
+		problem = &model.Problem{GroupId: groupId, RawProblemId: rawProblemId}
+		d.createProblem(problem)
+	}
+	d.markMainProblem(groupId, problem.RawProblemId)
+	return nil
+}
+
+func (d *Data) repairGroup(oldGroupId uint, rawProblemId uint, groupId uint) {
+	old := d.problemByGroupId(oldGroupId)
+	if old == nil {
+		return
+	}
+	if old.RawProblemId != rawProblemId {
+		d.markMainProblem(oldGroupId, old.RawProblemId)
+		return
+	}
+	if members := d.groupMembers(oldGroupId); len(members) > 0 {
+		old.RawProblemId = members[0].RawProblemId
+		old.UpdatedAt = now()
+		d.markMainProblem(oldGroupId, old.RawProblemId)
+		return
+	}
+	if target := d.problemByGroupId(groupId); target != nil {
+		d.mergeProblems(target, old)
+		return
+	}
+	old.GroupId = groupId
+	old.UpdatedAt = now()
+}
+
+//与数据库实现一致 已软删除的记录同样占用组号
+func (d *Data) freeGroupId(rawProblemId uint) uint {
+	var max uint
+	used := false
+	for _, group := range d.problemGroups {
+		used = used || group.GroupId == rawProblemId
+		if group.GroupId > max {
+			max = group.GroupId
+		}
+	}
+	for _, problem := range d.problems {
+		used = used || problem.GroupId == rawProblemId
+		if problem.GroupId > max {
+			max = problem.GroupId
+		}
+	}
+	if !used {
+		return rawProblemId
+	}
+	return max + 1
+}
+
+func (p *ProblemMapper) SetMainProblem(rawProblemId uint) error {
+	if rawProblemId <= 0 {
+		return errors.InvalidArgument("problem_mapper.SetMainProblem", "raw problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.SetMainProblem"); err != nil {
+		return err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	member := d.groupByRawProblemId(rawProblemId)
+	if member == nil {
+		return notFound("problem_mapper.SetMainProblem")
+	}
+	if problem := d.problemByGroupId(member.GroupId); problem != nil {
+		problem.RawProblemId = rawProblemId
+		problem.UpdatedAt = now()
+	} else {
+		d.createProblem(&model.Problem{GroupId: member.GroupId, RawProblemId: rawProblemId})
+	}
+	d.markMainProblem(member.GroupId, rawProblemId)
+	return nil
+}
+
+func (d *Data) markMainProblem(groupId uint, rawProblemId uint) {
+	for _, group := range d.groupMembers(groupId) {
+		group.MainProblem = group.RawProblemId == rawProblemId
+	}
+}
+
+//按原始题目id排序
+func (d *Data) groupMembers(groupId uint) []*model.ProblemGroup {
+	var members []*model.ProblemGroup
+	for _, group := range d.allGroups() {
+		if group.GroupId == groupId {
+			members = append(members, group)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].RawProblemId < members[j].RawProblemId
+	})
+	return members
+}
@@ -0,0 +1,434 @@
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/user_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/jinzhu/gorm"
+	"testing"
+	"time"
+)
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestInitMapper(t *testing.T) {
+	oldProblem, oldUser := problem_mapper.ProblemMapper, user_mapper.UserMapper
+	defer func() {
+		problem_mapper.ProblemMapper, user_mapper.UserMapper = oldProblem, oldUser
+	}()
+	InitMapper()
+	if _, ok := problem_mapper.ProblemMapper.(*ProblemMapper); !ok {
+		t.Fatalf("problem mapper not replaced: %T", problem_mapper.ProblemMapper)
+	}
+	if _, ok := user_mapper.UserMapper.(*UserMapper); !ok {
+		t.Fatalf("user mapper not replaced: %T", user_mapper.UserMapper)
+	}
+}
+
+func TestProblemMapper_PaginationAndSoftDelete(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	for i, title := range []string{"A + B Problem", "Sum Problem", "A + B Problem II"} {
+		raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: title, RemoteProblemId: string(rune('0' + i))})
+		assertNoError(t, err)
+		_, err = mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID})
+		assertNoError(t, err)
+	}
+	problems, count, err := mapper.FindAllProblems(2, 2, false)
+	assertNoError(t, err)
+	if count != 3 || len(problems) != 1 || problems[0].RawProblem.Title != "A + B Problem II" {
+		t.Fatalf("unexpected page: %v %+v", count, problems)
+	}
+	problems, count, err = mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Title: "a + b"}, 1, 10)
+	assertNoError(t, err)
+	if count != 2 || len(problems) != 2 {
+		t.Fatalf("unexpected search result: %v %+v", count, problems)
+	}
+	assertNoError(t, mapper.DeleteProblemById(problems[0].ID))
+	_, err = mapper.FindProblemById(problems[0].ID)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if _, count, _ = mapper.FindAllProblems(1, 10, false); count != 2 {
+		t.Fatalf("deleted problem still listed, count %v", count)
+	}
+}
+
+//与数据库一致 在题面原文上匹配 只命中标签的字段计分但没有片段
+func TestProblemMapper_FullTextSearch(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	for i, hint := range []string{`<span class="note">Mind the overflow</span>`, "Use long long for the sum."} {
+		raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "Sum Problem", Hint: hint, RemoteProblemId: string(rune('0' + i))})
+		assertNoError(t, err)
+		_, err = mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID})
+		assertNoError(t, err)
+	}
+	hits, count, err := mapper.FullTextSearch("span", 1, 10)
+	assertNoError(t, err)
+	if _, ok := hits[0].Snippets["hint"]; count != 1 || ok {
+		t.Fatalf("unexpected markup hits: count=%v hits=%+v", count, hits)
+	}
+	hits, count, err = mapper.FullTextSearch("sum", 1, 10)
+	assertNoError(t, err)
+	if count != 2 || hits[0].Score <= hits[1].Score || hits[0].Snippets["hint"] != "Use long long for the <em>sum</em>." {
+		t.Fatalf("unexpected hits: count=%v hits=%+v", count, hits)
+	}
+}
+
+func TestProblemMapper_Upsert(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "old", Source: "hdu", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	updated, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "new", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	if updated.ID != raw.ID || updated.Title != "new" || updated.Source != "hdu" {
+		t.Fatalf("upsert should keep id and untouched fields: %+v", updated)
+	}
+}
+
+func TestProblemMapper_Revisions(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "old", Source: "hdu", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	same, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "old", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	if !same.UpdatedAt.Equal(raw.UpdatedAt) {
+		t.Fatal("unchanged statement should not be written")
+	}
+	_, err = mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "new", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	revisions, err := mapper.FindRawProblemRevisions(raw.ID)
+	assertNoError(t, err)
+	if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[0].Title != "new" {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+	diffs, err := mapper.DiffRawProblemRevisions(raw.ID, 1, 2)
+	assertNoError(t, err)
+	if len(diffs) != 1 || diffs[0].Field != "title" || diffs[0].Old != "old" || diffs[0].New != "new" {
+		t.Fatalf("unexpected diff: %+v", diffs)
+	}
+}
+
+func TestProblemMapper_Samples(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		SampleInput:     "1 2\n##sample##\n3 4",
+		SampleOutput:    "3\n##sample##\n7",
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	samples, err := mapper.FindProblemSamples(raw.ID)
+	assertNoError(t, err)
+	if len(samples) != 2 || samples[1].Input != "3 4" || samples[1].Output != "7" {
+		t.Fatalf("expected the marked blob split into 2 samples, got %+v", samples)
+	}
+	_, err = mapper.ReplaceProblemSamples(raw.ID, []*model.ProblemSample{{Input: "0 0", Output: "0"}})
+	assertNoError(t, err)
+	samples, err = mapper.FindProblemSamples(raw.ID)
+	assertNoError(t, err)
+	if len(samples) != 1 || samples[0].SampleOrder != 1 || samples[0].Input != "0 0" {
+		t.Fatalf("unexpected samples after replace: %+v", samples)
+	}
+}
+
+func TestProblemMapper_Assets(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Description: "<img src=\"http://remote/a.png\">", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	_, err = mapper.RegisterProblemAsset(&model.ProblemAsset{RawProblemId: raw.ID, SourceUrl: "http://remote/a.png", LocalUrl: "/assets/a.png"})
+	assertNoError(t, err)
+	rewritten, err := mapper.RewriteAssetUrls(raw.ID)
+	assertNoError(t, err)
+	if rewritten.Description != "<img src=\"/assets/a.png\">" {
+		t.Fatalf("description not rewritten: %q", rewritten.Description)
+	}
+	recrawled, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Description: "<img src=\"http://remote/a.png\">", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	if !recrawled.UpdatedAt.Equal(rewritten.UpdatedAt) {
+		t.Fatal("re-crawled statement with registered assets should not be written")
+	}
+}
+
+func TestProblemMapper_Translations(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "Sum", RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	problem, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID})
+	assertNoError(t, err)
+	_, err = mapper.AddOrModifyTranslation(&model.RawProblemTranslation{RawProblemId: raw.ID, Locale: "zh", Title: "求和"})
+	assertNoError(t, err)
+	found, err := mapper.FindProblemById(problem.ID, "zh-CN")
+	assertNoError(t, err)
+	if found.RawProblem.Title != "求和" || found.RawProblem.Locale != "zh" {
+		t.Fatalf("expected the zh translation: %+v", found.RawProblem)
+	}
+	found, err = mapper.FindProblemById(problem.ID, "en")
+	assertNoError(t, err)
+	if found.RawProblem.Title != "Sum" {
+		t.Fatalf("expected the original: %+v", found.RawProblem)
+	}
+}
+
+func TestProblemMapper_GroupSuggestions(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "A + B", Description: "Calculate a + b.", RemoteOJ: 1, RemoteProblemId: "1000"})
+	assertNoError(t, err)
+	_, err = mapper.AddOrModifyProblemGroup(&model.ProblemGroup{RawProblemId: raw.ID, GroupId: raw.ID, MainProblem: true})
+	assertNoError(t, err)
+	mirror, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "A + B", Description: "Calculate a + b.", RemoteOJ: 2, RemoteProblemId: "1"})
+	assertNoError(t, err)
+	suggestions, err := mapper.SuggestGroupMerges(mirror.ID, 0)
+	assertNoError(t, err)
+	if len(suggestions) != 1 || suggestions[0].TargetRawProblemId != raw.ID || suggestions[0].Score != 1 {
+		t.Fatalf("unexpected suggestions: %+v", suggestions)
+	}
+	assertNoError(t, mapper.AcceptGroupSuggestion(suggestions[0].ID))
+	problem, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID})
+	assertNoError(t, err)
+	groups, err := mapper.FindGroupProblemsById(problem.ID)
+	assertNoError(t, err)
+	if len(groups) != 2 {
+		t.Fatalf("mirror should join the group: %+v", groups)
+	}
+	if err = mapper.RejectGroupSuggestion(suggestions[0].ID); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestProblemMapper_GroupOperations(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	var problems []*model.Problem
+	for i, id := range []string{"1000", "1001", "1002"} {
+		raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: id, RemoteOJ: 1, RemoteProblemId: id})
+		assertNoError(t, err)
+		_, err = mapper.AddOrModifyProblemGroup(&model.ProblemGroup{RawProblemId: raw.ID, GroupId: raw.ID, MainProblem: true})
+		assertNoError(t, err)
+		if i < 2 {
+			problem, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID})
+			assertNoError(t, err)
+			problems = append(problems, problem)
+		}
+	}
+	assertNoError(t, mapper.AddProblemSubmittedCountById(problems[0].ID))
+	assertNoError(t, mapper.AddProblemSubmittedCountById(problems[1].ID))
+	//第三题没有题目 并入后沿用第一组的题目
+	_, err := mapper.MergeProblemGroups(problems[0].GroupId, 3)
+	assertNoError(t, err)
+	merged, err := mapper.MergeProblemGroups(problems[0].GroupId, problems[1].GroupId)
+	assertNoError(t, err)
+	if merged.ID != problems[0].ID || merged.Submitted != 2 {
+		t.Fatalf("unexpected merged problem: %+v", merged)
+	}
+	groups, err := mapper.FindGroupProblemsById(merged.ID)
+	assertNoError(t, err)
+	if len(groups) != 3 {
+		t.Fatalf("expected three members: %+v", groups)
+	}
+	assertNoError(t, mapper.SetMainProblem(2))
+	found, err := mapper.FindProblemById(merged.ID)
+	assertNoError(t, err)
+	if found.RawProblemId != 2 {
+		t.Fatalf("main problem not updated: %+v", found)
+	}
+	split, err := mapper.SplitProblemGroup(2)
+	assertNoError(t, err)
+	if split.RawProblemId != 2 || split.GroupId == merged.GroupId {
+		t.Fatalf("unexpected split problem: %+v", split)
+	}
+	if found, err = mapper.FindProblemById(merged.ID); err != nil || found.RawProblemId != 1 {
+		t.Fatalf("first remaining member should become main: %+v %v", found, err)
+	}
+	if _, err = mapper.SplitProblemGroup(2); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	//移回原组 拆出的组已空 其题目并入原组的题目
+	assertNoError(t, mapper.UpdateProblemGroup(2, merged.GroupId))
+	if _, err = mapper.FindProblemById(split.ID); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("problem of the emptied group should be merged, got %v", err)
+	}
+	groups, err = mapper.FindGroupProblemsById(merged.ID)
+	assertNoError(t, err)
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == 1) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+}
+
+func TestProblemMapper_RemoteTargets(t *testing.T) {
+	data := NewData()
+	mapper := NewProblemMapper(data)
+	for _, oj := range []int32{1, 2} {
+		raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "A + B", RemoteOJ: remote_oj.RemoteOJ(oj), RemoteProblemId: "1000"})
+		assertNoError(t, err)
+		_, err = mapper.AddOrModifyProblemGroup(&model.ProblemGroup{RawProblemId: raw.ID, GroupId: 1, MainProblem: oj == 1, RemoteOJ: raw.RemoteOJ})
+		assertNoError(t, err)
+	}
+	problem, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: 1, RawProblemId: 1})
+	assertNoError(t, err)
+	submission, err := NewSubmissionMapper(data).AddOrModifySubmission(&model.Submission{ProblemId: problem.ID, RemoteOJ: 1})
+	assertNoError(t, err)
+	for i := 0; i < problem_mapper.RemoteFailureThreshold; i++ {
+		assertNoError(t, mapper.RecordRemoteResult(submission.ID, false))
+	}
+	targets, err := mapper.FindRemoteTargets(problem.ID)
+	assertNoError(t, err)
+	if len(targets) != 2 || targets[0].RemoteOJ != 2 || targets[1].ConsecutiveFailures != 3 {
+		t.Fatalf("failing remote oj should be tried last: %+v %+v", targets[0], targets[1])
+	}
+	assertNoError(t, mapper.RecordRemoteResult(submission.ID, true))
+	if targets, err = mapper.FindRemoteTargets(problem.ID); err != nil || targets[0].RemoteOJ != 1 {
+		t.Fatalf("recovered remote oj should be tried first: %+v %v", targets, err)
+	}
+}
+
+func TestProblemMapper_Status(t *testing.T) {
+	mapper := NewProblemMapper(NewData())
+	var problems []*model.Problem
+	for _, id := range []string{"1000", "1001"} {
+		raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "A + B " + id, RemoteOJ: 1, RemoteProblemId: id})
+		assertNoError(t, err)
+		problem, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: raw.ID, RawProblemId: raw.ID, Status: model.ProblemDraft})
+		assertNoError(t, err)
+		problems = append(problems, problem)
+	}
+	if _, count, err := mapper.FindAllProblems(1, 10, false); err != nil || count != 0 {
+		t.Fatalf("drafts should not be listed: count=%v err=%v", count, err)
+	}
+	if _, err := mapper.FindProblemByRandom(); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("drafts should not be picked: %v", err)
+	}
+	if _, err := mapper.FindProblemByRandom(&problem_mapper.ProblemListOption{AllStatuses: true}); err != nil {
+		t.Fatalf("admin view should pick drafts: %v", err)
+	}
+	if _, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: 99, Status: model.ProblemStatus(99)}); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+	published, err := mapper.UpdateProblemStatus(problems[0].ID, model.ProblemPublic)
+	assertNoError(t, err)
+	if published.Status != model.ProblemPublic {
+		t.Fatalf("unexpected status: %+v", published)
+	}
+	found, count, err := mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Title: "a + b"}, 1, 10)
+	assertNoError(t, err)
+	if count != 1 || found[0].ID != problems[0].ID {
+		t.Fatalf("only the published problem should be found: %+v", found)
+	}
+	if _, count, err = mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{AllStatuses: true}, 1, 10); err != nil || count != 2 {
+		t.Fatalf("admin search should find drafts: count=%v err=%v", count, err)
+	}
+	if _, err = mapper.UpdateProblemStatus(problems[0].ID, model.ProblemDraft); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	draft := model.ProblemDraft
+	if _, count, err = mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Status: &draft}, 1, 10); err != nil || count != 0 {
+		t.Fatalf("drafts need the admin view: count=%v err=%v", count, err)
+	}
+	modified, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: problems[0].GroupId, Status: model.ProblemRetired})
+	assertNoError(t, err)
+	if modified.Status != model.ProblemPublic {
+		t.Fatalf("status should not change through AddOrModifyProblem: %+v", modified)
+	}
+	_, err = mapper.AttachProblemTags(problems[1].ID, []string{"math"})
+	assertNoError(t, err)
+	if counts, err := mapper.CountTags(); err != nil || len(counts) != 0 {
+		t.Fatalf("drafts should not be counted: %+v %v", counts, err)
+	}
+}
+
+func TestUserMapper_UniqueAndCounters(t *testing.T) {
+	mapper := NewUserMapper(NewData())
+	user, err := mapper.AddUser(&model.User{
+		UserAuth: &model.UserAuth{Password: "123456"},
+		Nickname: "bqx",
+		Roles:    []*model.Role{{RoleName: "normal"}},
+	})
+	assertNoError(t, err)
+	_, err = mapper.AddUser(&model.User{Nickname: "bqx"})
+	if !errors.Is(err, errors.ErrDuplicate) {
+		t.Fatalf("expected duplicate nickname error, got %v", err)
+	}
+	assertNoError(t, mapper.AddUserSubmitCountById(user.ID))
+	assertNoError(t, mapper.AddUserSubmitCountById(user.ID))
+	assertNoError(t, mapper.AddUserAcceptCountById(user.ID))
+	found, err := mapper.FindUserById(user.ID)
+	assertNoError(t, err)
+	if found.Submitted != 2 || found.Accepted != 1 || len(found.Roles) != 1 {
+		t.Fatalf("unexpected user: %+v", found)
+	}
+	//修改返回值不影响存储的数据
+	found.Nickname = "changed"
+	if again, _ := mapper.FindUserById(user.ID); again.Nickname != "bqx" {
+		t.Fatalf("stored user was aliased: %+v", again)
+	}
+	assertNoError(t, mapper.DeleteUserById(user.ID))
+	if _, err = mapper.FindUserByUsername("bqx"); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestContestMapper_DuplicateOrder(t *testing.T) {
+	mapper := NewContestMapper(NewData())
+	_, err := mapper.CreateContest(&model.Contest{Title: "c"}, []*model.ContestProblem{
+		{ProblemId: 1, ProblemOrder: "A"},
+		{ProblemId: 2, ProblemOrder: "A"},
+	})
+	if !errors.Is(err, errors.ErrDuplicate) {
+		t.Fatalf("expected duplicate order error, got %v", err)
+	}
+	if _, count, _ := mapper.FindAllContests(1, 10); count != 0 {
+		t.Fatalf("failed create should not persist, count %v", count)
+	}
+}
+
+func TestSubmissionMapper_FindSubmissions(t *testing.T) {
+	data := NewData()
+	mapper := NewSubmissionMapper(data)
+	for i := 0; i < 3; i++ {
+		_, err := mapper.AddOrModifySubmission(&model.Submission{
+			ProblemId:      1,
+			Username:       "bob",
+			SubmissionCode: &model.SubmissionCode{SourceCode: "int main(){}"},
+		})
+		assertNoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	submissions, count, err := mapper.FindSubmissions(1, 2, nil)
+	assertNoError(t, err)
+	if count != 3 || len(submissions) != 2 || submissions[0].ID != 3 {
+		t.Fatalf("unexpected submissions: %v %+v", count, submissions)
+	}
+	submission, err := mapper.FindSubmissionById(2)
+	assertNoError(t, err)
+	if submission.SubmissionCode.SourceCode != "int main(){}" {
+		t.Fatalf("code not loaded: %+v", submission.SubmissionCode)
+	}
+	first, page, err := mapper.FindSubmissionsByCursor("", 2, nil)
+	assertNoError(t, err)
+	second, _, err := mapper.FindSubmissionsByCursor(page.Next, 2, nil)
+	assertNoError(t, err)
+	if len(first) != 2 || first[0].ID != 3 || len(second) != 1 || second[0].ID != 1 {
+		t.Fatalf("unexpected cursor pages: %+v %+v", first, second)
+	}
+	assertNoError(t, mapper.UpdateSubmissionCEInfoById(2, "compile error"))
+	if info, ok := data.CompileInfo(2); !ok || info != "compile error" {
+		t.Fatalf("unexpected compile info: %v %v", info, ok)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	mapper := NewUserMapper(NewData())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := mapper.WithContext(ctx).UpdateUser(&model.User{Model: gorm.Model{ID: 1}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+}
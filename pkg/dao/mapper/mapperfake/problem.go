@@ -0,0 +1,542 @@
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ProblemMapper struct {
+	data *Data
+	ctx  context.Context
+}
+
+func NewProblemMapper(data *Data) problem_mapper.IProblemMapper {
+	return &ProblemMapper{data: data}
+}
+
+func (p *ProblemMapper) WithContext(ctx context.Context) problem_mapper.IProblemMapper {
+	return &ProblemMapper{data: p.data, ctx: ctx}
+}
+
+func (p *ProblemMapper) AddOrModifyRawProblem(rawProblem *model.RawProblem) (*model.RawProblem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.AddOrModifyRawProblem"); err != nil {
+		return nil, err
+	}
+	problem_mapper.NormalizeLimits(rawProblem)
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range d.rawProblemIds() {
+		stored := d.rawProblems[id]
+		if stored.RemoteOJ == rawProblem.RemoteOJ && stored.RemoteProblemId == rawProblem.RemoteProblemId {
+			if stored.ContentHash == "" {
+				stored.ContentHash = problem_mapper.StatementHash(stored)
+				d.recordStatement(stored)
+			}
+			problem_mapper.ApplyAssetUrls(rawProblem, d.problemAssets(stored.ID))
+			merged := *stored
+			mergeNonZero(&merged, rawProblem)
+			merged.ContentHash = stored.ContentHash
+			if reflect.DeepEqual(&merged, stored) {
+				*rawProblem = *stored
+				return rawProblem, nil
+			}
+			merged.UpdatedAt = now()
+			if hash := problem_mapper.StatementHash(&merged); hash != merged.ContentHash {
+				merged.ContentHash = hash
+				d.recordStatement(&merged)
+			}
+			*stored = merged
+			*rawProblem = *stored
+			return rawProblem, nil
+		}
+	}
+	rawProblem.ID = d.id("raw_problems")
+	rawProblem.CreatedAt, rawProblem.UpdatedAt = now(), now()
+	rawProblem.ContentHash = problem_mapper.StatementHash(rawProblem)
+	stored := *rawProblem
+	d.rawProblems[stored.ID] = &stored
+	d.recordStatement(&stored)
+	return rawProblem, nil
+}
+
+func (p *ProblemMapper) AddProblemSubmittedCountById(problemId uint) error {
+	if problemId <= 0 {
+		return errors.InvalidArgument("problem_mapper.AddProblemSubmittedCountById", "problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.AddProblemSubmittedCountById"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	if problem := p.data.problem(problemId); problem != nil {
+		problem.Submitted++
+		problem.UpdatedAt = now()
+		updateDifficulty(problem)
+	}
+	return nil
+}
+
+func (p *ProblemMapper) AddProblemAcceptedCountById(problemId uint) error {
+	if problemId <= 0 {
+		return errors.InvalidArgument("problem_mapper.AddProblemAcceptedCountById", "problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.AddProblemAcceptedCountById"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	if problem := p.data.problem(problemId); problem != nil {
+		problem.Accepted++
+		problem.UpdatedAt = now()
+		updateDifficulty(problem)
+	}
+	return nil
+}
+
+func (p *ProblemMapper) AddContestProblemSubmittedCountById(contestId uint, problemId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.AddContestProblemSubmittedCountById"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	for _, cp := range p.data.contestProblems {
+		if cp.ContestId == contestId && cp.ProblemId == problemId {
+			cp.Submitted++
+		}
+	}
+	return nil
+}
+
+func (p *ProblemMapper) AddContestProblemAcceptedCountById(contestId uint, problemId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.AddContestProblemAcceptedCountById"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	for _, cp := range p.data.contestProblems {
+		if cp.ContestId == contestId && cp.ProblemId == problemId {
+			cp.Accepted++
+		}
+	}
+	return nil
+}
+
+func (p *ProblemMapper) AddOrModifyProblemGroup(group *model.ProblemGroup) (*model.ProblemGroup, error) {
+	if err := checkContext(p.ctx, "problem_mapper.AddOrModifyProblemGroup"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stored := d.groupByRawProblemId(group.RawProblemId); stored != nil {
+		mergeNonZero(stored, group)
+		stored.UpdatedAt = now()
+		*group = *stored
+		return group, nil
+	}
+	group.ID = d.id("problem_groups")
+	group.CreatedAt, group.UpdatedAt = now(), now()
+	stored := *group
+	d.problemGroups[stored.ID] = &stored
+	return group, nil
+}
+
+func (p *ProblemMapper) AddOrModifyProblem(problem *model.Problem) (*model.Problem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.AddOrModifyProblem"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stored := d.problemByGroupId(problem.GroupId); stored != nil {
+		//与数据库实现一致 状态只能经UpdateProblemStatus修改
+		status := stored.Status
+		mergeNonZero(stored, problem)
+		stored.Status = status
+		stored.UpdatedAt = now()
+		rawProblem := problem.RawProblem
+		*problem = *stored
+		problem.RawProblem = rawProblem
+		return problem, nil
+	}
+	if !problem.Status.Valid() {
+		return nil, errors.InvalidArgument("problem_mapper.AddOrModifyProblem", "unknown problem status %v", int32(problem.Status))
+	}
+	d.createProblem(problem)
+	return problem, nil
+}
+
+func (p *ProblemMapper) UpdateProblemGroupId(rawProblemId uint, groupId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.UpdateProblemGroupId"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	return p.data.moveToGroup(rawProblemId, groupId)
+}
+
+func (p *ProblemMapper) FindGroupProblemsById(problemId uint) ([]*model.ProblemGroup, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindGroupProblemsById"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problem := d.problem(problemId)
+	if problem == nil {
+		return nil, notFound("problem_mapper.FindGroupProblemsById")
+	}
+	return d.groupsByGroupId(problem.GroupId), nil
+}
+
+func (p *ProblemMapper) FindAllProblems(page int32, pageSize int32, desc bool, options ...*problem_mapper.ProblemListOption) ([]*model.Problem, int32, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindAllProblems"); err != nil {
+		return nil, 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problems := listFilter(d.allProblems(), options)
+	sortByDifficulty := false
+	for _, option := range options {
+		sortByDifficulty = sortByDifficulty || (option != nil && option.SortByDifficulty)
+	}
+	if sortByDifficulty {
+		sort.SliceStable(problems, func(i, j int) bool {
+			if desc {
+				return problems[i].Difficulty > problems[j].Difficulty
+			}
+			return problems[i].Difficulty < problems[j].Difficulty
+		})
+	} else if desc {
+		sort.SliceStable(problems, func(i, j int) bool {
+			return problems[i].UpdatedAt.After(problems[j].UpdatedAt)
+		})
+	}
+	left, right := pageRange(page, pageSize, len(problems))
+	ret := make([]*model.Problem, 0, right-left)
+	for _, problem := range problems[left:right] {
+		ret = append(ret, d.loadProblem(problem))
+	}
+	return ret, int32(len(problems)), nil
+}
+
+func (p *ProblemMapper) FindAllProblemsByCursor(cursor string, pageSize int32, options ...*problem_mapper.ProblemListOption) ([]*model.Problem, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("problem_mapper.FindAllProblemsByCursor", "%v", err)
+	}
+	if err := checkContext(p.ctx, "problem_mapper.FindAllProblemsByCursor"); err != nil {
+		return nil, nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	all := listFilter(d.allProblems(), options)
+	problems := make([]*model.Problem, 0)
+	for _, i := range keysetSelect(len(all), func(i int) (time.Time, uint) { return all[i].UpdatedAt, all[i].ID }, c, pageSize) {
+		problems = append(problems, d.loadProblem(all[i]))
+	}
+	n, page := util.KeysetPage(problems, c, pageSize, func(i int) (time.Time, uint) {
+		return problems[i].UpdatedAt, problems[i].ID
+	})
+	return problems[:n], page, nil
+}
+
+func (p *ProblemMapper) FindProblemById(problemId uint, locale ...string) (*model.Problem, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.FindProblemById", "problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemById"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problem := d.problem(problemId)
+	if problem == nil || d.rawProblems[problem.RawProblemId] == nil {
+		return nil, notFound("problem_mapper.FindProblemById")
+	}
+	ret := d.loadProblem(problem)
+	d.localize(ret.RawProblem, firstLocale(locale))
+	return ret, nil
+}
+
+func (p *ProblemMapper) FindProblemsByIds(problemIds []uint, locale ...string) ([]*model.Problem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemsByIds"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	wanted := make(map[uint]bool, len(problemIds))
+	for _, id := range problemIds {
+		wanted[id] = true
+	}
+	problems := make([]*model.Problem, 0, len(problemIds))
+	for _, problem := range d.allProblems() {
+		if wanted[problem.ID] {
+			ret := d.loadProblem(problem)
+			d.localize(ret.RawProblem, firstLocale(locale))
+			problems = append(problems, ret)
+		}
+	}
+	return problems, nil
+}
+
+func (p *ProblemMapper) SearchProblemByCondition(param *problem_mapper.ProblemSearchParam, pageNo int32, pageSize int32) ([]*model.Problem, int32, error) {
+	if param == nil || param.IsEmpty() {
+		return p.FindAllProblems(pageNo, pageSize, false, &problem_mapper.ProblemListOption{AllStatuses: param != nil && param.AllStatuses})
+	}
+	if err := checkContext(p.ctx, "problem_mapper.SearchProblemByCondition"); err != nil {
+		return nil, 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	title := strings.ToLower(param.Title)
+	source := strings.ToLower(param.Source)
+	tags := problem_mapper.NormalizeTags(param.Tags)
+	problems := make([]*model.Problem, 0)
+	for _, problem := range d.allProblems() {
+		rawProblem := d.rawProblems[problem.RawProblemId]
+		if rawProblem == nil || rawProblem.DeletedAt != nil {
+			continue
+		}
+		if param.ProblemId != 0 && problem.ID != param.ProblemId {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(rawProblem.Title), title) ||
+			!strings.Contains(strings.ToLower(rawProblem.Source), source) {
+			continue
+		}
+		if param.RemoteOJ != 0 && rawProblem.RemoteOJ != param.RemoteOJ {
+			continue
+		}
+		if param.Status != nil && problem.Status != *param.Status {
+			continue
+		}
+		if !param.AllStatuses && problem.Status != model.ProblemPublic {
+			continue
+		}
+		if !d.hasAllTags(problem.ID, tags) {
+			continue
+		}
+		ratio := acceptRatio(problem)
+		if (param.MinAcceptRatio > 0 && ratio < param.MinAcceptRatio) ||
+			(param.MaxAcceptRatio > 0 && ratio > param.MaxAcceptRatio) {
+			continue
+		}
+		if !inLimitRange(rawProblem.TimeLimitMs, param.MinTimeLimitMs, param.MaxTimeLimitMs) ||
+			!inLimitRange(rawProblem.MemoryLimitKb, param.MinMemoryLimitKb, param.MaxMemoryLimitKb) {
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	left, right := pageRange(pageNo, pageSize, len(problems))
+	ret := make([]*model.Problem, 0, right-left)
+	for _, problem := range problems[left:right] {
+		ret = append(ret, d.loadProblem(problem))
+	}
+	return ret, int32(len(problems)), nil
+}
+
+//min、max为0表示不限 设置了范围时未知的限制(0)不匹配
+func inLimitRange(limit int32, min int32, max int32) bool {
+	if min == 0 && max == 0 {
+		return true
+	}
+	return limit > 0 && (min == 0 || limit >= min) && (max == 0 || limit <= max)
+}
+
+func (p *ProblemMapper) FullTextSearch(query string, pageNo int32, pageSize int32) ([]*problem_mapper.ProblemSearchHit, int32, error) {
+	terms := util.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, 0, errors.InvalidArgument("problem_mapper.FullTextSearch", "search query is empty")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.FullTextSearch"); err != nil {
+		return nil, 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problems := make([]*model.Problem, 0)
+	for _, problem := range listFilter(d.allProblems(), nil) {
+		problems = append(problems, d.loadProblem(problem))
+	}
+	hits := problem_mapper.RankProblems(problems, terms)
+	left, right := pageRange(pageNo, pageSize, len(hits))
+	return hits[left:right], int32(len(hits)), nil
+}
+
+func acceptRatio(problem *model.Problem) float64 {
+	if problem.Submitted == 0 {
+		return 0
+	}
+	return float64(problem.Accepted) / float64(problem.Submitted)
+}
+
+func (p *ProblemMapper) DeleteProblemById(problemId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.DeleteProblemById"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	if problem := p.data.problem(problemId); problem != nil {
+		deletedAt := now()
+		problem.DeletedAt = &deletedAt
+	}
+	return nil
+}
+
+func (p *ProblemMapper) FindProblemByRandom(options ...*problem_mapper.ProblemListOption) (*model.Problem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemByRandom"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problems := listFilter(d.allProblems(), options)
+	if len(problems) == 0 {
+		return nil, notFound("problem_mapper.FindProblemByRandom")
+	}
+	problem := *problems[rand.Intn(len(problems))]
+	problem.RawProblem = nil
+	return &problem, nil
+}
+
+func (p *ProblemMapper) FindRawProblemsWithGroup(pageNo int32, pageSize int32) ([]*model.RawProblem, []*model.ProblemGroup, int32, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindRawProblemsWithGroup"); err != nil {
+		return nil, nil, 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := d.rawProblemIds()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	left, right := pageRange(pageNo, pageSize, len(ids))
+	rawProblems := make([]*model.RawProblem, 0, right-left)
+	problemGroups := make([]*model.ProblemGroup, 0, right-left)
+	for _, id := range ids[left:right] {
+		rawProblem := *d.rawProblems[id]
+		rawProblems = append(rawProblems, &rawProblem)
+		if group := d.groupByRawProblemId(id); group != nil {
+			g := *group
+			problemGroups = append(problemGroups, &g)
+		}
+	}
+	return rawProblems, problemGroups, int32(len(ids)), nil
+}
+
+func (p *ProblemMapper) UpdateProblemGroup(rawProblemId uint, groupId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.UpdateProblemGroup"); err != nil {
+		return err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	return p.data.moveToGroup(rawProblemId, groupId)
+}
+
+func (d *Data) createProblem(problem *model.Problem) {
+	problem.ID = d.id("problems")
+	problem.CreatedAt, problem.UpdatedAt = now(), now()
+	stored := *problem
+	stored.RawProblem = nil
+	d.problems[stored.ID] = &stored
+}
+
+func (d *Data) rawProblemIds() []uint {
+	ids := make([]uint, 0, len(d.rawProblems))
+	for id, rawProblem := range d.rawProblems {
+		if rawProblem.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	return sortedIds(ids)
+}
+
+//未删除的题目 按id升序
+func (d *Data) allProblems() []*model.Problem {
+	ids := make([]uint, 0, len(d.problems))
+	for id, problem := range d.problems {
+		if problem.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	problems := make([]*model.Problem, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		problems = append(problems, d.problems[id])
+	}
+	return problems
+}
+
+func (d *Data) problem(problemId uint) *model.Problem {
+	problem, ok := d.problems[problemId]
+	if !ok || problem.DeletedAt != nil {
+		return nil
+	}
+	return problem
+}
+
+func (d *Data) problemByGroupId(groupId uint) *model.Problem {
+	for _, problem := range d.allProblems() {
+		if problem.GroupId == groupId {
+			return problem
+		}
+	}
+	return nil
+}
+
+//返回题目的副本 并带上RawProblem
+func (d *Data) loadProblem(problem *model.Problem) *model.Problem {
+	ret := *problem
+	ret.RawProblem = nil
+	if rawProblem, ok := d.rawProblems[problem.RawProblemId]; ok && rawProblem.DeletedAt == nil {
+		raw := *rawProblem
+		ret.RawProblem = &raw
+	}
+	return &ret
+}
+
+func (d *Data) groupByRawProblemId(rawProblemId uint) *model.ProblemGroup {
+	for _, group := range d.allGroups() {
+		if group.RawProblemId == rawProblemId {
+			return group
+		}
+	}
+	return nil
+}
+
+func (d *Data) groupsByGroupId(groupId uint) []*model.ProblemGroup {
+	groups := make([]*model.ProblemGroup, 0)
+	for _, group := range d.allGroups() {
+		if group.GroupId == groupId {
+			g := *group
+			groups = append(groups, &g)
+		}
+	}
+	return groups
+}
+
+func (d *Data) allGroups() []*model.ProblemGroup {
+	ids := make([]uint, 0, len(d.problemGroups))
+	for id, group := range d.problemGroups {
+		if group.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	groups := make([]*model.ProblemGroup, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		groups = append(groups, d.problemGroups[id])
+	}
+	return groups
+}
@@ -0,0 +1,60 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"sort"
+)
+
+func (d *Data) addRevision(rawProblem *model.RawProblem) {
+	var last int32
+	for _, r := range d.revisions {
+		if r.RawProblemId == rawProblem.ID && r.Revision > last {
+			last = r.Revision
+		}
+	}
+	revision := problem_mapper.NewRawProblemRevision(rawProblem)
+	revision.ID = d.id("raw_problem_revisions")
+	revision.Revision = last + 1
+	revision.CreatedAt, revision.UpdatedAt = now(), now()
+	d.revisions = append(d.revisions, revision)
+}
+
+func (d *Data) revision(rawProblemId uint, revision int32) *model.RawProblemRevision {
+	for _, r := range d.revisions {
+		if r.RawProblemId == rawProblemId && r.Revision == revision {
+			return r
+		}
+	}
+	return nil
+}
+
+func (p *ProblemMapper) FindRawProblemRevisions(rawProblemId uint) ([]*model.RawProblemRevision, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindRawProblemRevisions"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	revisions := make([]*model.RawProblemRevision, 0)
+	for _, r := range p.data.revisions {
+		if r.RawProblemId == rawProblemId {
+			revision := *r
+			revisions = append(revisions, &revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+	return revisions, nil
+}
+
+func (p *ProblemMapper) DiffRawProblemRevisions(rawProblemId uint, from int32, to int32) ([]*problem_mapper.RevisionFieldDiff, error) {
+	if err := checkContext(p.ctx, "problem_mapper.DiffRawProblemRevisions"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	fromRevision, toRevision := p.data.revision(rawProblemId, from), p.data.revision(rawProblemId, to)
+	if fromRevision == nil || toRevision == nil {
+		return nil, notFound("problem_mapper.DiffRawProblemRevisions")
+	}
+	return problem_mapper.DiffRevisions(fromRevision, toRevision), nil
+}
@@ -0,0 +1,60 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+)
+
+func (p *ProblemMapper) RecordRemoteResult(submissionId uint, success bool) error {
+	if submissionId <= 0 {
+		return errors.InvalidArgument("problem_mapper.RecordRemoteResult", "submission id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.RecordRemoteResult"); err != nil {
+		return err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	submission := d.submission(submissionId)
+	if submission == nil {
+		return notFound("problem_mapper.RecordRemoteResult")
+	}
+	problem := d.problem(submission.ProblemId)
+	if problem == nil {
+		return notFound("problem_mapper.RecordRemoteResult")
+	}
+	recorded := false
+	for _, group := range d.groupMembers(problem.GroupId) {
+		if group.RemoteOJ != submission.RemoteOJ {
+			continue
+		}
+		recordedAt := now()
+		if success {
+			group.RemoteSuccess++
+			group.ConsecutiveFailures = 0
+			group.LastSuccessAt = &recordedAt
+		} else {
+			group.RemoteFailure++
+			group.ConsecutiveFailures++
+			group.LastFailureAt = &recordedAt
+		}
+		recorded = true
+	}
+	if !recorded {
+		return errors.NotFound("problem_mapper.RecordRemoteResult", "problem %v has no raw problem on remote oj %v", submission.ProblemId, submission.RemoteOJ)
+	}
+	return nil
+}
+
+func (p *ProblemMapper) FindRemoteTargets(problemId uint) ([]*model.ProblemGroup, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.FindRemoteTargets", "problem id is incorrect")
+	}
+	groups, err := p.FindGroupProblemsById(problemId)
+	if err != nil {
+		return nil, err
+	}
+	problem_mapper.RankRemoteTargets(groups, now())
+	return groups, nil
+}
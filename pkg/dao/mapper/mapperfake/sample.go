@@ -0,0 +1,63 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"sort"
+)
+
+func (p *ProblemMapper) ReplaceProblemSamples(rawProblemId uint, samples []*model.ProblemSample) ([]*model.ProblemSample, error) {
+	if rawProblemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.ReplaceProblemSamples", "raw problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.ReplaceProblemSamples"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if rawProblem := d.rawProblems[rawProblemId]; rawProblem == nil || rawProblem.DeletedAt != nil {
+		return nil, notFound("problem_mapper.ReplaceProblemSamples")
+	}
+	kept := make([]*model.ProblemSample, 0, len(d.samples))
+	for _, sample := range d.samples {
+		if sample.RawProblemId != rawProblemId {
+			kept = append(kept, sample)
+		}
+	}
+	for i, sample := range samples {
+		sample.ID = d.id("problem_samples")
+		sample.RawProblemId = rawProblemId
+		sample.SampleOrder = int32(i + 1)
+		stored := *sample
+		kept = append(kept, &stored)
+	}
+	d.samples = kept
+	return samples, nil
+}
+
+func (p *ProblemMapper) FindProblemSamples(rawProblemId uint) ([]*model.ProblemSample, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemSamples"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	samples := make([]*model.ProblemSample, 0)
+	for _, s := range d.samples {
+		if s.RawProblemId == rawProblemId {
+			sample := *s
+			samples = append(samples, &sample)
+		}
+	}
+	if len(samples) > 0 {
+		sort.Slice(samples, func(i, j int) bool { return samples[i].SampleOrder < samples[j].SampleOrder })
+		return samples, nil
+	}
+	rawProblem := d.rawProblems[rawProblemId]
+	if rawProblem == nil || rawProblem.DeletedAt != nil {
+		return nil, notFound("problem_mapper.FindProblemSamples")
+	}
+	return problem_mapper.BlobSamples(rawProblem), nil
+}
@@ -0,0 +1,186 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/similarity"
+	"sort"
+)
+
+func (d *Data) recordStatement(rawProblem *model.RawProblem) {
+	d.addRevision(rawProblem)
+	d.fingerprints[rawProblem.ID], _ = problem_mapper.Fingerprint(rawProblem)
+}
+
+//两个指纹至少有一个band的桶相同
+func sharesBucket(a *model.ProblemFingerprint, b *model.ProblemFingerprint) bool {
+	sa, _ := similarity.Decode(a.Signature)
+	sb, _ := similarity.Decode(b.Signature)
+	ba, bb := sa.Buckets(), sb.Buckets()
+	for i := range ba {
+		if i < len(bb) && ba[i] == bb[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Data) suggestionIds() []uint {
+	ids := make([]uint, 0, len(d.suggestions))
+	for id := range d.suggestions {
+		ids = append(ids, id)
+	}
+	return sortedIds(ids)
+}
+
+func (p *ProblemMapper) SuggestGroupMerges(rawProblemId uint, minScore float64) ([]*model.GroupSuggestion, error) {
+	if err := checkContext(p.ctx, "problem_mapper.SuggestGroupMerges"); err != nil {
+		return nil, err
+	}
+	if minScore <= 0 {
+		minScore = problem_mapper.DefaultMinScore
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subject := d.rawProblems[rawProblemId]
+	if subject == nil || subject.DeletedAt != nil {
+		return nil, notFound("problem_mapper.SuggestGroupMerges")
+	}
+	fingerprint := d.fingerprints[rawProblemId]
+	if fingerprint == nil {
+		fingerprint, _ = problem_mapper.Fingerprint(subject)
+		d.fingerprints[rawProblemId] = fingerprint
+	}
+	suggestions := make([]*model.GroupSuggestion, 0)
+	for _, id := range d.rawProblemIds() {
+		candidate, candidateFingerprint := d.rawProblems[id], d.fingerprints[id]
+		if id == rawProblemId || candidateFingerprint == nil || !sharesBucket(fingerprint, candidateFingerprint) ||
+			!problem_mapper.MergeCandidate(subject, d.groupByRawProblemId(rawProblemId), candidate, d.groupByRawProblemId(id)) {
+			continue
+		}
+		score := problem_mapper.MatchScore(fingerprint, candidateFingerprint)
+		if score < minScore {
+			continue
+		}
+		if suggestion := d.upsertSuggestion(rawProblemId, id, score); suggestion != nil {
+			ret := *suggestion
+			suggestions = append(suggestions, &ret)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Score > suggestions[j].Score })
+	return suggestions, nil
+}
+
+func (d *Data) upsertSuggestion(rawProblemId uint, targetRawProblemId uint, score float64) *model.GroupSuggestion {
+	for _, suggestion := range d.suggestions {
+		if (suggestion.RawProblemId == rawProblemId && suggestion.TargetRawProblemId == targetRawProblemId) ||
+			(suggestion.RawProblemId == targetRawProblemId && suggestion.TargetRawProblemId == rawProblemId) {
+			if suggestion.Status != model.SuggestionPending {
+				return nil
+			}
+			suggestion.Score, suggestion.UpdatedAt = score, now()
+			return suggestion
+		}
+	}
+	suggestion := &model.GroupSuggestion{
+		ID:                 d.id("group_suggestions"),
+		RawProblemId:       rawProblemId,
+		TargetRawProblemId: targetRawProblemId,
+		Score:              score,
+		Status:             model.SuggestionPending,
+		CreatedAt:          now(),
+		UpdatedAt:          now(),
+	}
+	d.suggestions[suggestion.ID] = suggestion
+	return suggestion
+}
+
+func (p *ProblemMapper) FindGroupSuggestions(status int32, pageNo int32, pageSize int32) ([]*model.GroupSuggestion, int32, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindGroupSuggestions"); err != nil {
+		return nil, 0, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	suggestions := make([]*model.GroupSuggestion, 0)
+	for _, id := range d.suggestionIds() {
+		if suggestion := d.suggestions[id]; suggestion.Status == status {
+			ret := *suggestion
+			suggestions = append(suggestions, &ret)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Score > suggestions[j].Score })
+	left, right := pageRange(pageNo, pageSize, len(suggestions))
+	return suggestions[left:right], int32(len(suggestions)), nil
+}
+
+func (p *ProblemMapper) AcceptGroupSuggestion(suggestionId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.AcceptGroupSuggestion"); err != nil {
+		return err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	suggestion, err := d.pendingSuggestion("problem_mapper.AcceptGroupSuggestion", suggestionId)
+	if err != nil {
+		return err
+	}
+	target := d.groupByRawProblemId(suggestion.TargetRawProblemId)
+	if target == nil {
+		return notFound("problem_mapper.AcceptGroupSuggestion")
+	}
+	if subject := d.groupByRawProblemId(suggestion.RawProblemId); subject != nil {
+		if subject.GroupId != target.GroupId {
+			if _, err = d.mergeProblemGroups(target.GroupId, subject.GroupId); err != nil {
+				return err
+			}
+		}
+	} else {
+		rawProblem := d.rawProblems[suggestion.RawProblemId]
+		if rawProblem == nil || rawProblem.DeletedAt != nil {
+			return notFound("problem_mapper.AcceptGroupSuggestion")
+		}
+		group := &model.ProblemGroup{
+			RawProblemId:    rawProblem.ID,
+			GroupId:         target.GroupId,
+			RemoteOJ:        rawProblem.RemoteOJ,
+			RemoteProblemId: rawProblem.RemoteProblemId,
+		}
+		group.ID = d.id("problem_groups")
+		group.CreatedAt, group.UpdatedAt = now(), now()
+		d.problemGroups[group.ID] = group
+		if err = d.moveToGroup(suggestion.RawProblemId, target.GroupId); err != nil {
+			return err
+		}
+	}
+	suggestion.Status, suggestion.UpdatedAt = model.SuggestionAccepted, now()
+	return nil
+}
+
+func (p *ProblemMapper) RejectGroupSuggestion(suggestionId uint) error {
+	if err := checkContext(p.ctx, "problem_mapper.RejectGroupSuggestion"); err != nil {
+		return err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	suggestion, err := d.pendingSuggestion("problem_mapper.RejectGroupSuggestion", suggestionId)
+	if err != nil {
+		return err
+	}
+	suggestion.Status, suggestion.UpdatedAt = model.SuggestionRejected, now()
+	return nil
+}
+
+func (d *Data) pendingSuggestion(op string, suggestionId uint) (*model.GroupSuggestion, error) {
+	suggestion := d.suggestions[suggestionId]
+	if suggestion == nil {
+		return nil, notFound(op)
+	}
+	if suggestion.Status != model.SuggestionPending {
+		return nil, errors.Conflict(op, "suggestion %v has already been handled", suggestionId)
+	}
+	return suggestion, nil
+}
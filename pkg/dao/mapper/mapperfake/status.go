@@ -0,0 +1,65 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+)
+
+func (p *ProblemMapper) UpdateProblemStatus(problemId uint, status model.ProblemStatus) (*model.Problem, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.UpdateProblemStatus", "problem id is incorrect")
+	}
+	if !status.Valid() {
+		return nil, errors.InvalidArgument("problem_mapper.UpdateProblemStatus", "unknown problem status %v", int32(status))
+	}
+	if err := checkContext(p.ctx, "problem_mapper.UpdateProblemStatus"); err != nil {
+		return nil, err
+	}
+	if err := p.data.updateProblemStatus(problemId, status); err != nil {
+		return nil, err
+	}
+	return p.FindProblemById(problemId)
+}
+
+func (d *Data) updateProblemStatus(problemId uint, status model.ProblemStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	problem := d.problem(problemId)
+	if problem == nil {
+		return notFound("problem_mapper.UpdateProblemStatus")
+	}
+	if !problem_mapper.CanTransition(problem.Status, status) {
+		return errors.Conflict("problem_mapper.UpdateProblemStatus", "problem %v cannot change from %v to %v", problemId, problem.Status, status)
+	}
+	if problem.Status != status {
+		problem.Status = status
+		problem.UpdatedAt = now()
+	}
+	return nil
+}
+
+//与数据库实现一致 默认只保留公开的题目
+func listFilter(problems []*model.Problem, options []*problem_mapper.ProblemListOption) []*model.Problem {
+	allStatuses := false
+	for _, option := range options {
+		allStatuses = allStatuses || (option != nil && option.AllStatuses)
+	}
+	filtered := make([]*model.Problem, 0, len(problems))
+	for _, problem := range problems {
+		if !allStatuses && problem.Status != model.ProblemPublic {
+			continue
+		}
+		skip := false
+		for _, option := range options {
+			if option != nil && ((option.MinDifficulty > 0 && problem.Difficulty < option.MinDifficulty) ||
+				(option.MaxDifficulty > 0 && problem.Difficulty > option.MaxDifficulty)) {
+				skip = true
+			}
+		}
+		if !skip {
+			filtered = append(filtered, problem)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,290 @@
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/submission_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"sort"
+	"time"
+)
+
+type SubmissionMapper struct {
+	data *Data
+	ctx  context.Context
+}
+
+func NewSubmissionMapper(data *Data) submission_mapper.ISubmissionMapper {
+	return &SubmissionMapper{data: data}
+}
+
+func (s *SubmissionMapper) WithContext(ctx context.Context) submission_mapper.ISubmissionMapper {
+	return &SubmissionMapper{data: s.data, ctx: ctx}
+}
+
+func (s *SubmissionMapper) AddOrModifySubmission(submission *model.Submission) (*model.Submission, error) {
+	if err := checkContext(s.ctx, "submission_mapper.AddOrModifySubmission"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stored := d.submission(submission.ID); stored != nil {
+		mergeNonZero(stored, submission)
+		stored.UpdatedAt = now()
+		d.saveSubmissionCode(submission)
+		return submission, nil
+	}
+	if submission.ID == 0 {
+		submission.ID = d.id("submissions")
+	} else if submission.ID > d.nextId["submissions"] {
+		d.nextId["submissions"] = submission.ID
+	}
+	submission.CreatedAt, submission.UpdatedAt = now(), now()
+	stored := *submission
+	stored.SubmissionCode = nil
+	d.submissions[stored.ID] = &stored
+	d.saveSubmissionCode(submission)
+	return submission, nil
+}
+
+func (s *SubmissionMapper) FindSubmissionById(submissionId uint) (*model.Submission, error) {
+	if err := checkContext(s.ctx, "submission_mapper.FindSubmissionById"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	submission := d.submission(submissionId)
+	code := d.submissionCode(submissionId)
+	if submission == nil || code == nil {
+		return nil, notFound("submission_mapper.FindSubmissionById")
+	}
+	ret := *submission
+	c := *code
+	ret.SubmissionCode = &c
+	return &ret, nil
+}
+
+func (s *SubmissionMapper) FindProblemGroupById(submissionId uint) ([]*model.ProblemGroup, error) {
+	if err := checkContext(s.ctx, "submission_mapper.FindProblemGroupById"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	submission := d.submission(submissionId)
+	d.mu.Unlock()
+	if submission == nil {
+		return nil, notFound("submission_mapper.FindProblemGroupById")
+	}
+	return (&ProblemMapper{data: d, ctx: s.ctx}).FindGroupProblemsById(submission.ProblemId)
+}
+
+func (s *SubmissionMapper) FindSubmissions(pageNo int32, pageSize int32, condition *submission_mapper.SearchSubmissionCondition) ([]*model.Submission, int32, error) {
+	if err := checkContext(s.ctx, "submission_mapper.FindSubmissions"); err != nil {
+		return nil, 0, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	matched := d.searchSubmissions(condition)
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].UpdatedAt.After(matched[j].UpdatedAt)
+	})
+	left, right := pageRange(pageNo, pageSize, len(matched))
+	submissions := make([]*model.Submission, 0, right-left)
+	for _, submission := range matched[left:right] {
+		ret := *submission
+		submissions = append(submissions, &ret)
+	}
+	return submissions, int32(len(matched)), nil
+}
+
+func (s *SubmissionMapper) FindSubmissionsByCursor(cursor string, pageSize int32, condition *submission_mapper.SearchSubmissionCondition) ([]*model.Submission, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("submission_mapper.FindSubmissionsByCursor", "%v", err)
+	}
+	if err := checkContext(s.ctx, "submission_mapper.FindSubmissionsByCursor"); err != nil {
+		return nil, nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	matched := d.searchSubmissions(condition)
+	submissions := make([]*model.Submission, 0)
+	for _, i := range keysetSelect(len(matched), func(i int) (time.Time, uint) { return matched[i].UpdatedAt, matched[i].ID }, c, pageSize) {
+		ret := *matched[i]
+		submissions = append(submissions, &ret)
+	}
+	n, page := util.KeysetPage(submissions, c, pageSize, func(i int) (time.Time, uint) {
+		return submissions[i].UpdatedAt, submissions[i].ID
+	})
+	return submissions[:n], page, nil
+}
+
+//与数据库实现一致 只有Result字段有值
+func (s *SubmissionMapper) FindSubmissionsGroupByResult(condition *submission_mapper.UserSubmissionCondition) ([]*model.Submission, error) {
+	if err := checkContext(s.ctx, "submission_mapper.FindSubmissionsGroupByResult"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seen := make(map[interface{}]bool)
+	submissions := make([]*model.Submission, 0)
+	for _, submission := range d.allSubmissions() {
+		if submission.UserId != condition.UserId || submission.ProblemId != condition.ProblemId || submission.ContestId != condition.ContestId {
+			continue
+		}
+		if seen[submission.Result] {
+			continue
+		}
+		seen[submission.Result] = true
+		submissions = append(submissions, &model.Submission{Result: submission.Result})
+	}
+	sort.Slice(submissions, func(i, j int) bool { return submissions[i].Result < submissions[j].Result })
+	return submissions, nil
+}
+
+func (s *SubmissionMapper) FindSubmissionsByContestId(contestId uint, start time.Time, end time.Time) ([]*model.Submission, error) {
+	if err := checkContext(s.ctx, "submission_mapper.FindSubmissionsByContestId"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	submissions := make([]*model.Submission, 0)
+	for _, submission := range d.allSubmissions() {
+		if submission.ContestId == contestId && submission.CreatedAt.After(start) && submission.CreatedAt.Before(end) {
+			ret := *submission
+			submissions = append(submissions, &ret)
+		}
+	}
+	return submissions, nil
+}
+
+func (s *SubmissionMapper) UpdateSubmissionById(submission *model.Submission) (*model.Submission, error) {
+	if err := checkContext(s.ctx, "submission_mapper.UpdateSubmissionById"); err != nil {
+		return nil, err
+	}
+	d := s.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored := d.submission(submission.ID)
+	if stored == nil {
+		return nil, notFound("submission_mapper.UpdateSubmissionById")
+	}
+	mergeNonZero(stored, submission)
+	stored.UpdatedAt = now()
+	code := submission.SubmissionCode
+	*submission = *stored
+	submission.SubmissionCode = code
+	return submission, nil
+}
+
+func (s *SubmissionMapper) UpdateSubmissionCEInfoById(submissionId uint, info string) error {
+	if err := checkContext(s.ctx, "submission_mapper.UpdateSubmissionCEInfoById"); err != nil {
+		return err
+	}
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	s.data.compileInfos[submissionId] = &model.CompileInfo{SubmissionId: submissionId, Info: info}
+	return nil
+}
+
+func (s *SubmissionMapper) ResetSubmissionById(submissionId uint) error {
+	if err := checkContext(s.ctx, "submission_mapper.ResetSubmissionById"); err != nil {
+		return err
+	}
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if submission := s.data.submission(submissionId); submission != nil {
+		submission.Result = 0
+		submission.TimeCost = 0
+		submission.MemoryCost = 0
+		submission.RemoteOJ = 0
+		submission.RealRunId = ""
+		submission.UpdatedAt = now()
+	}
+	return nil
+}
+
+//与gorm保存关联一致 没有id的代码新建 有id的代码更新
+func (d *Data) saveSubmissionCode(submission *model.Submission) {
+	code := submission.SubmissionCode
+	if code == nil {
+		return
+	}
+	code.SubmissionID = submission.ID
+	if stored, ok := d.submissionCodes[code.ID]; ok && code.ID != 0 {
+		mergeNonZero(stored, code)
+		stored.UpdatedAt = now()
+		return
+	}
+	if code.ID == 0 {
+		code.ID = d.id("submission_codes")
+	}
+	code.CreatedAt, code.UpdatedAt = now(), now()
+	stored := *code
+	d.submissionCodes[stored.ID] = &stored
+}
+
+func (d *Data) searchSubmissions(condition *submission_mapper.SearchSubmissionCondition) []*model.Submission {
+	if condition == nil {
+		condition = &submission_mapper.SearchSubmissionCondition{}
+	}
+	matched := make([]*model.Submission, 0)
+	for _, submission := range d.allSubmissions() {
+		if condition.Username != "" && submission.Username != condition.Username {
+			continue
+		}
+		if condition.ProblemId != 0 && submission.ProblemId != condition.ProblemId {
+			continue
+		}
+		if condition.Status != 0 && submission.Result != condition.Status {
+			continue
+		}
+		if condition.Language != 0 && submission.Language != condition.Language {
+			continue
+		}
+		matched = append(matched, submission)
+	}
+	return matched
+}
+
+func (d *Data) allSubmissions() []*model.Submission {
+	ids := make([]uint, 0, len(d.submissions))
+	for id, submission := range d.submissions {
+		if submission.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	submissions := make([]*model.Submission, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		submissions = append(submissions, d.submissions[id])
+	}
+	return submissions
+}
+
+func (d *Data) submission(submissionId uint) *model.Submission {
+	submission, ok := d.submissions[submissionId]
+	if !ok || submission.DeletedAt != nil {
+		return nil
+	}
+	return submission
+}
+
+func (d *Data) submissionCode(submissionId uint) *model.SubmissionCode {
+	ids := make([]uint, 0)
+	for id, code := range d.submissionCodes {
+		if code.SubmissionID == submissionId && code.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.submissionCodes[sortedIds(ids)[0]]
+}
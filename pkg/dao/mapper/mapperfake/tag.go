@@ -0,0 +1,149 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"sort"
+)
+
+func (p *ProblemMapper) AttachProblemTags(problemId uint, names []string) ([]*model.Tag, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.AttachProblemTags", "problem id is incorrect")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.AttachProblemTags"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range problem_mapper.NormalizeTags(names) {
+		tag := d.tagByName(name)
+		if tag == nil {
+			tag = &model.Tag{Name: name}
+			tag.ID = d.id("tags")
+			tag.CreatedAt, tag.UpdatedAt = now(), now()
+			d.tags[tag.ID] = tag
+		}
+		if !d.hasTag(problemId, tag.ID) {
+			d.problemTags = append(d.problemTags, &model.ProblemTag{ProblemId: problemId, TagId: tag.ID})
+		}
+	}
+	return d.problemTagList(problemId), nil
+}
+
+func (p *ProblemMapper) DetachProblemTags(problemId uint, names []string) error {
+	if err := checkContext(p.ctx, "problem_mapper.DetachProblemTags"); err != nil {
+		return err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	detach := make(map[uint]bool)
+	for _, name := range problem_mapper.NormalizeTags(names) {
+		if tag := d.tagByName(name); tag != nil {
+			detach[tag.ID] = true
+		}
+	}
+	kept := d.problemTags[:0]
+	for _, pt := range d.problemTags {
+		if !(pt.ProblemId == problemId && detach[pt.TagId]) {
+			kept = append(kept, pt)
+		}
+	}
+	d.problemTags = kept
+	return nil
+}
+
+func (p *ProblemMapper) FindProblemTags(problemId uint) ([]*model.Tag, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindProblemTags"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	return p.data.problemTagList(problemId), nil
+}
+
+func (p *ProblemMapper) CountTags(options ...*problem_mapper.ProblemListOption) ([]*problem_mapper.TagCount, error) {
+	if err := checkContext(p.ctx, "problem_mapper.CountTags"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	listed := make(map[uint]bool)
+	for _, problem := range listFilter(d.allProblems(), options) {
+		listed[problem.ID] = true
+	}
+	byName := make(map[string]*problem_mapper.TagCount)
+	counts := make([]*problem_mapper.TagCount, 0)
+	for _, pt := range d.problemTags {
+		tag, ok := d.tags[pt.TagId]
+		if !ok || tag.DeletedAt != nil || !listed[pt.ProblemId] {
+			continue
+		}
+		count, ok := byName[tag.Name]
+		if !ok {
+			count = &problem_mapper.TagCount{Name: tag.Name}
+			byName[tag.Name] = count
+			counts = append(counts, count)
+		}
+		count.Count++
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts, nil
+}
+
+func (p *ProblemMapper) ImportRemoteTags(problemId uint) ([]*model.Tag, error) {
+	problem, err := p.FindProblemById(problemId)
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.ImportRemoteTags", err)
+	}
+	return p.AttachProblemTags(problem.ID, problem_mapper.ParseRemoteTags(problem.RawProblem))
+}
+
+func (d *Data) tagByName(name string) *model.Tag {
+	for _, tag := range d.tags {
+		if tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+func (d *Data) hasTag(problemId uint, tagId uint) bool {
+	for _, pt := range d.problemTags {
+		if pt.ProblemId == problemId && pt.TagId == tagId {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Data) hasAllTags(problemId uint, names []string) bool {
+	for _, name := range names {
+		tag := d.tagByName(name)
+		if tag == nil || tag.DeletedAt != nil || !d.hasTag(problemId, tag.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+//按标签名排序
+func (d *Data) problemTagList(problemId uint) []*model.Tag {
+	tags := make([]*model.Tag, 0)
+	for _, pt := range d.problemTags {
+		if tag, ok := d.tags[pt.TagId]; ok && pt.ProblemId == problemId && tag.DeletedAt == nil {
+			t := *tag
+			tags = append(tags, &t)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags
+}
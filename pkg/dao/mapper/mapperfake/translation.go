@@ -0,0 +1,99 @@
+package mapperfake
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"sort"
+)
+
+func (d *Data) rawProblemTranslations(rawProblemId uint) []*model.RawProblemTranslation {
+	translations := make([]*model.RawProblemTranslation, 0)
+	for _, id := range d.translationIds() {
+		translation := d.translations[id]
+		if translation.RawProblemId == rawProblemId && translation.DeletedAt == nil {
+			translations = append(translations, translation)
+		}
+	}
+	return translations
+}
+
+func (d *Data) translationIds() []uint {
+	ids := make([]uint, 0, len(d.translations))
+	for id := range d.translations {
+		ids = append(ids, id)
+	}
+	return sortedIds(ids)
+}
+
+func (d *Data) localize(rawProblem *model.RawProblem, locale string) {
+	if rawProblem == nil || locale == "" {
+		return
+	}
+	problem_mapper.ApplyTranslation(rawProblem, problem_mapper.PickTranslation(d.rawProblemTranslations(rawProblem.ID), locale))
+}
+
+func firstLocale(locale []string) string {
+	if len(locale) == 0 {
+		return ""
+	}
+	return locale[0]
+}
+
+func (p *ProblemMapper) AddOrModifyTranslation(translation *model.RawProblemTranslation) (*model.RawProblemTranslation, error) {
+	translation.Locale = problem_mapper.NormalizeLocale(translation.Locale)
+	if translation.RawProblemId <= 0 || translation.Locale == "" {
+		return nil, errors.InvalidArgument("problem_mapper.AddOrModifyTranslation", "raw problem id and locale are required")
+	}
+	if err := checkContext(p.ctx, "problem_mapper.AddOrModifyTranslation"); err != nil {
+		return nil, err
+	}
+	d := p.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if rawProblem := d.rawProblems[translation.RawProblemId]; rawProblem == nil || rawProblem.DeletedAt != nil {
+		return nil, notFound("problem_mapper.AddOrModifyTranslation")
+	}
+	for _, stored := range d.translations {
+		if stored.RawProblemId == translation.RawProblemId && stored.Locale == translation.Locale {
+			translation.ID, translation.CreatedAt, translation.UpdatedAt, translation.DeletedAt = stored.ID, stored.CreatedAt, now(), nil
+			*stored = *translation
+			return translation, nil
+		}
+	}
+	translation.ID = d.id("raw_problem_translations")
+	translation.CreatedAt, translation.UpdatedAt = now(), now()
+	stored := *translation
+	d.translations[stored.ID] = &stored
+	return translation, nil
+}
+
+func (p *ProblemMapper) FindRawProblemTranslations(rawProblemId uint) ([]*model.RawProblemTranslation, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindRawProblemTranslations"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	translations := make([]*model.RawProblemTranslation, 0)
+	for _, t := range p.data.rawProblemTranslations(rawProblemId) {
+		translation := *t
+		translations = append(translations, &translation)
+	}
+	sort.Slice(translations, func(i, j int) bool { return translations[i].Locale < translations[j].Locale })
+	return translations, nil
+}
+
+func (p *ProblemMapper) FindLocalizedRawProblem(rawProblemId uint, locale string) (*model.RawProblem, error) {
+	if err := checkContext(p.ctx, "problem_mapper.FindLocalizedRawProblem"); err != nil {
+		return nil, err
+	}
+	p.data.mu.Lock()
+	defer p.data.mu.Unlock()
+	stored := p.data.rawProblems[rawProblemId]
+	if stored == nil || stored.DeletedAt != nil {
+		return nil, notFound("problem_mapper.FindLocalizedRawProblem")
+	}
+	rawProblem := *stored
+	p.data.localize(&rawProblem, locale)
+	return &rawProblem, nil
+}
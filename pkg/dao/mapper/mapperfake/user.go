@@ -0,0 +1,407 @@
+package mapperfake
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/user_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"time"
+)
+
+type UserMapper struct {
+	data *Data
+	ctx  context.Context
+}
+
+func NewUserMapper(data *Data) user_mapper.IUserMapper {
+	return &UserMapper{data: data}
+}
+
+func (u *UserMapper) WithContext(ctx context.Context) user_mapper.IUserMapper {
+	return &UserMapper{data: u.data, ctx: ctx}
+}
+
+func (u *UserMapper) AddUser(user *model.User) (*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.AddUser"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.nicknameTaken(user.Nickname) {
+		return nil, duplicate("user_mapper.AddUser", "uidx_name")
+	}
+	user.ID = d.id("users")
+	user.CreatedAt, user.UpdatedAt = now(), now()
+	stored := *user
+	stored.UserAuth, stored.Roles = nil, nil
+	d.users[stored.ID] = &stored
+	if user.UserAuth != nil {
+		user.UserAuth.UserID = user.ID
+		d.createUserAuth(user.UserAuth)
+	}
+	for i, r := range user.Roles {
+		user.Roles[i] = d.addUserRoleByRoleName(user.ID, r.RoleName)
+	}
+	return user, nil
+}
+
+func (u *UserMapper) AddUserRoleByRoleName(userId uint, roleName string) (*model.Role, error) {
+	if err := checkContext(u.ctx, "user_mapper.AddUserRoleByRoleName"); err != nil {
+		return nil, err
+	}
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+	return u.data.addUserRoleByRoleName(userId, roleName), nil
+}
+
+func (u *UserMapper) AddUserRoleByRoleId(userId uint, roleId uint) error {
+	if err := checkContext(u.ctx, "user_mapper.AddUserRoleByRoleId"); err != nil {
+		return err
+	}
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+	u.data.userRoles = append(u.data.userRoles, &model.UserRole{UserId: userId, RoleId: roleId})
+	return nil
+}
+
+func (u *UserMapper) AddUserSubmitCountById(userId uint) error {
+	if userId <= 0 {
+		return errors.InvalidArgument("user_mapper.AddUserSubmitCountById", "user id is incorrect")
+	}
+	if err := checkContext(u.ctx, "user_mapper.AddUserSubmitCountById"); err != nil {
+		return err
+	}
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+	if user := u.data.user(userId); user != nil {
+		user.Submitted++
+		user.UpdatedAt = now()
+	}
+	return nil
+}
+
+func (u *UserMapper) AddUserAcceptCountById(userId uint) error {
+	if userId <= 0 {
+		return errors.InvalidArgument("user_mapper.AddUserAcceptCountById", "user id is incorrect")
+	}
+	if err := checkContext(u.ctx, "user_mapper.AddUserAcceptCountById"); err != nil {
+		return err
+	}
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+	if user := u.data.user(userId); user != nil {
+		user.Accepted++
+		user.UpdatedAt = now()
+	}
+	return nil
+}
+
+//只更新用户信息 不更新角色
+func (u *UserMapper) UpdateUser(user *model.User) (*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.UpdateUser"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored := d.user(user.ID)
+	if stored == nil {
+		return nil, notFound("user_mapper.UpdateUser")
+	}
+	if user.Nickname != "" && user.Nickname != stored.Nickname && d.nicknameTaken(user.Nickname) {
+		return nil, duplicate("user_mapper.UpdateUser", "uidx_name")
+	}
+	mergeNonZero(stored, user)
+	stored.UpdatedAt = now()
+	if user.UserAuth != nil && user.UserAuth.ID != 0 {
+		if auth, ok := d.userAuths[user.UserAuth.ID]; ok {
+			mergeNonZero(auth, user.UserAuth)
+			auth.UpdatedAt = now()
+		}
+	}
+	return d.loadUser(stored, "user_mapper.UpdateUser")
+}
+
+//role id 必须给到
+func (u *UserMapper) UpdateUserRoles(userId uint, roles []*model.Role) (*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.UpdateUserRoles"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleteUserRoles(userId)
+	for _, r := range roles {
+		d.userRoles = append(d.userRoles, &model.UserRole{UserId: userId, RoleId: r.ID})
+	}
+	user := d.user(userId)
+	if user == nil {
+		return nil, notFound("user_mapper.UpdateUserRoles")
+	}
+	return d.loadUser(user, "user_mapper.UpdateUserRoles")
+}
+
+func (u *UserMapper) FindUsersByIds(userIds []uint) ([]*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindUsersByIds"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	wanted := make(map[uint]bool, len(userIds))
+	for _, id := range userIds {
+		wanted[id] = true
+	}
+	users := make([]*model.User, 0, len(userIds))
+	for _, user := range d.allUsers() {
+		if wanted[user.ID] {
+			ret := *user
+			ret.Roles = d.userRoleList(user.ID)
+			users = append(users, &ret)
+		}
+	}
+	return users, nil
+}
+
+func (u *UserMapper) FindUserById(userId uint) (*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindUserById"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user := d.user(userId)
+	if user == nil {
+		return nil, notFound("user_mapper.FindUserById")
+	}
+	return d.loadUser(user, "user_mapper.FindUserById")
+}
+
+func (u *UserMapper) FindUserByUsername(username string) (*model.User, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindUserByUsername"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user := d.userByNickname(username)
+	if user == nil {
+		return nil, notFound("user_mapper.FindUserByUsername")
+	}
+	return d.loadUser(user, "user_mapper.FindUserByUsername")
+}
+
+func (u *UserMapper) FindUserRolesById(userId uint) ([]*model.Role, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindUserRolesById"); err != nil {
+		return nil, err
+	}
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+	return u.data.userRoleList(userId), nil
+}
+
+func (u *UserMapper) FindAllUsers(pageNo int32, pageSize int32) ([]*model.User, int32, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindAllUsers"); err != nil {
+		return nil, 0, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	all := d.allUsers()
+	left, right := pageRange(pageNo, pageSize, len(all))
+	users := make([]*model.User, 0, right-left)
+	for _, user := range all[left:right] {
+		ret := *user
+		if auth := d.userAuth(user.ID); auth != nil {
+			a := *auth
+			ret.UserAuth = &a
+		}
+		ret.Roles = d.userRoleList(user.ID)
+		users = append(users, &ret)
+	}
+	return users, int32(len(all)), nil
+}
+
+func (u *UserMapper) FindAllUsersByCursor(cursor string, pageSize int32) ([]*model.User, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("user_mapper.FindAllUsersByCursor", "%v", err)
+	}
+	if err := checkContext(u.ctx, "user_mapper.FindAllUsersByCursor"); err != nil {
+		return nil, nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	all := d.allUsers()
+	users := make([]*model.User, 0)
+	for _, i := range keysetSelect(len(all), func(i int) (time.Time, uint) { return all[i].UpdatedAt, all[i].ID }, c, pageSize) {
+		user := *all[i]
+		if auth := d.userAuth(user.ID); auth != nil {
+			a := *auth
+			user.UserAuth = &a
+		}
+		user.Roles = d.userRoleList(user.ID)
+		users = append(users, &user)
+	}
+	n, page := util.KeysetPage(users, c, pageSize, func(i int) (time.Time, uint) {
+		return users[i].UpdatedAt, users[i].ID
+	})
+	return users[:n], page, nil
+}
+
+func (u *UserMapper) FindRoleList() ([]*model.Role, error) {
+	if err := checkContext(u.ctx, "user_mapper.FindRoleList"); err != nil {
+		return nil, err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rolesByIds(nil, true), nil
+}
+
+func (u *UserMapper) DeleteUserById(userId uint) error {
+	if err := checkContext(u.ctx, "user_mapper.DeleteUserById"); err != nil {
+		return err
+	}
+	d := u.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user := d.user(userId)
+	auth := d.userAuth(userId)
+	if user == nil || auth == nil {
+		return notFound("user_mapper.DeleteUserById")
+	}
+	deletedAt := now()
+	user.DeletedAt = &deletedAt
+	auth.DeletedAt = &deletedAt
+	d.deleteUserRoles(userId)
+	return nil
+}
+
+func (d *Data) createUserAuth(auth *model.UserAuth) {
+	auth.ID = d.id("user_auths")
+	auth.CreatedAt, auth.UpdatedAt = now(), now()
+	stored := *auth
+	d.userAuths[stored.ID] = &stored
+}
+
+func (d *Data) addUserRoleByRoleName(userId uint, roleName string) *model.Role {
+	var role *model.Role
+	for _, r := range d.rolesByIds(nil, true) {
+		if r.RoleName == roleName {
+			role = r
+			break
+		}
+	}
+	if role == nil {
+		role = &model.Role{RoleName: roleName}
+		role.ID = d.id("roles")
+		role.CreatedAt, role.UpdatedAt = now(), now()
+		stored := *role
+		d.roles[stored.ID] = &stored
+	}
+	d.userRoles = append(d.userRoles, &model.UserRole{UserId: userId, RoleId: role.ID})
+	return role
+}
+
+func (d *Data) deleteUserRoles(userId uint) {
+	kept := d.userRoles[:0]
+	for _, ur := range d.userRoles {
+		if ur.UserId != userId {
+			kept = append(kept, ur)
+		}
+	}
+	d.userRoles = kept
+}
+
+func (d *Data) userRoleList(userId uint) []*model.Role {
+	roleIds := make(map[uint]bool)
+	for _, ur := range d.userRoles {
+		if ur.UserId == userId {
+			roleIds[ur.RoleId] = true
+		}
+	}
+	return d.rolesByIds(roleIds, false)
+}
+
+//返回角色副本 all为true时忽略roleIds
+func (d *Data) rolesByIds(roleIds map[uint]bool, all bool) []*model.Role {
+	ids := make([]uint, 0, len(d.roles))
+	for id, role := range d.roles {
+		if role.DeletedAt == nil && (all || roleIds[id]) {
+			ids = append(ids, id)
+		}
+	}
+	roles := make([]*model.Role, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		role := *d.roles[id]
+		roles = append(roles, &role)
+	}
+	return roles
+}
+
+func (d *Data) allUsers() []*model.User {
+	ids := make([]uint, 0, len(d.users))
+	for id, user := range d.users {
+		if user.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	users := make([]*model.User, 0, len(ids))
+	for _, id := range sortedIds(ids) {
+		users = append(users, d.users[id])
+	}
+	return users
+}
+
+func (d *Data) user(userId uint) *model.User {
+	user, ok := d.users[userId]
+	if !ok || user.DeletedAt != nil {
+		return nil
+	}
+	return user
+}
+
+func (d *Data) userByNickname(nickname string) *model.User {
+	for _, user := range d.allUsers() {
+		if user.Nickname == nickname {
+			return user
+		}
+	}
+	return nil
+}
+
+//唯一索引不感知软删除 已删除用户的昵称同样占用
+func (d *Data) nicknameTaken(nickname string) bool {
+	for _, user := range d.users {
+		if user.Nickname == nickname {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Data) userAuth(userId uint) *model.UserAuth {
+	for _, auth := range d.userAuths {
+		if auth.UserID == userId && auth.DeletedAt == nil {
+			return auth
+		}
+	}
+	return nil
+}
+
+//与数据库实现一致 没有UserAuth的用户视为不存在
+func (d *Data) loadUser(user *model.User, op string) (*model.User, error) {
+	auth := d.userAuth(user.ID)
+	if auth == nil {
+		return nil, notFound(op)
+	}
+	ret := *user
+	a := *auth
+	ret.UserAuth = &a
+	ret.Roles = d.userRoleList(user.ID)
+	return &ret, nil
+}
@@ -0,0 +1,119 @@
+package problem_mapper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/blobstore"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+//把附件内容写入store 返回待RegisterProblemAsset登记的ProblemAsset
+//key由原始题目id与内容的sha256组成 内容相同的附件只保存一份
+func UploadProblemAsset(ctx context.Context, store blobstore.Store, rawProblemId uint, sourceUrl string, contentType string, content io.Reader) (*model.ProblemAsset, error) {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.UploadProblemAsset", err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	ext := path.Ext(strings.SplitN(strings.SplitN(sourceUrl, "?", 2)[0], "#", 2)[0])
+	key := fmt.Sprintf("raw_problems/%d/%s%s", rawProblemId, hash, strings.ToLower(ext))
+	if err = store.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return &model.ProblemAsset{
+		RawProblemId: rawProblemId,
+		SourceUrl:    sourceUrl,
+		StorageKey:   key,
+		LocalUrl:     store.URL(key),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Sha256:       hash,
+	}, nil
+}
+
+//把题面中附件的原地址替换为本地地址 返回是否有改动
+func ApplyAssetUrls(rawProblem *model.RawProblem, assets []*model.ProblemAsset) bool {
+	changed := false
+	for _, field := range []*string{&rawProblem.Description, &rawProblem.Input, &rawProblem.Output, &rawProblem.Hint} {
+		for _, asset := range assets {
+			if asset.SourceUrl == "" || asset.LocalUrl == "" || !strings.Contains(*field, asset.SourceUrl) {
+				continue
+			}
+			*field = strings.ReplaceAll(*field, asset.SourceUrl, asset.LocalUrl)
+			changed = true
+		}
+	}
+	return changed
+}
+
+//登记原始题目的附件 同一原地址再次登记时更新其本地地址等信息
+func (p *ProblemMapperImpl) RegisterProblemAsset(asset *model.ProblemAsset) (*model.ProblemAsset, error) {
+	if asset.RawProblemId <= 0 || asset.SourceUrl == "" || asset.LocalUrl == "" {
+		return nil, errors.InvalidArgument("problem_mapper.RegisterProblemAsset", "raw problem id, source url and local url are required")
+	}
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.RawProblem{}, asset.RawProblemId).Error; err != nil {
+			return err
+		}
+		var stored model.ProblemAsset
+		err := tx.Where("raw_problem_id = ? and source_url = ?", asset.RawProblemId, asset.SourceUrl).First(&stored).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return tx.Create(asset).Error
+		}
+		if err != nil {
+			return err
+		}
+		asset.ID, asset.CreatedAt = stored.ID, stored.CreatedAt
+		return tx.Save(asset).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.RegisterProblemAsset", err)
+	}
+	return asset, nil
+}
+
+func (p *ProblemMapperImpl) FindProblemAssets(rawProblemId uint) ([]*model.ProblemAsset, error) {
+	var assets []*model.ProblemAsset
+	result := p.DB.
+		Where("raw_problem_id = ?", rawProblemId).
+		Order("id").
+		Find(&assets)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemAssets", result.Error)
+	}
+	return assets, nil
+}
+
+//把已登记附件的原地址改写为本地地址 改写经由AddOrModifyRawProblem 会记录一个修订
+func (p *ProblemMapperImpl) RewriteAssetUrls(rawProblemId uint) (*model.RawProblem, error) {
+	rawProblem := &model.RawProblem{}
+	if err := p.DB.First(rawProblem, rawProblemId).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.RewriteAssetUrls", err)
+	}
+	rawProblem, err := p.AddOrModifyRawProblem(rawProblem)
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.RewriteAssetUrls", err)
+	}
+	return rawProblem, nil
+}
+
+//已有原始题目按登记的附件改写incoming的题面 使爬虫重新抓取到的原地址不被视为变化
+func applyStoredAssetUrls(tx *gorm.DB, rawProblemId uint, incoming *model.RawProblem) error {
+	var assets []*model.ProblemAsset
+	if err := tx.Where("raw_problem_id = ?", rawProblemId).Find(&assets).Error; err != nil {
+		return err
+	}
+	ApplyAssetUrls(incoming, assets)
+	return nil
+}
@@ -0,0 +1,143 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/difficulty"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+//FindAllProblems的可选项 零值字段不生效
+type ProblemListOption struct {
+	//按难度排序 此时desc参数作用于难度
+	SortByDifficulty bool
+	MinDifficulty    int32
+	MaxDifficulty    int32
+	//管理员视图 列出所有状态的题目
+	AllStatuses bool
+}
+
+//按options过滤题目 默认只保留公开的题目
+func listFilter(db *gorm.DB, options []*ProblemListOption) *gorm.DB {
+	allStatuses := false
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		if option.MinDifficulty > 0 {
+			db = db.Where("problems.difficulty >= ?", option.MinDifficulty)
+		}
+		if option.MaxDifficulty > 0 {
+			db = db.Where("problems.difficulty <= ?", option.MaxDifficulty)
+		}
+		allStatuses = allStatuses || option.AllStatuses
+	}
+	return visible(db, allStatuses)
+}
+
+//记录用户首次通过该题 并累加解题者强度、更新难度
+//用户已是该题的解题者时返回false
+func (p *ProblemMapperImpl) AddProblemSolver(problemId uint, userId uint) (bool, error) {
+	if problemId <= 0 || userId <= 0 {
+		return false, errors.InvalidArgument("problem_mapper.AddProblemSolver", "problem id or user id is incorrect")
+	}
+	added := false
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var count int
+		if err := tx.Model(&model.ProblemSolver{}).Where("problem_id = ? and user_id = ?", problemId, userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := tx.Create(&model.ProblemSolver{ProblemId: problemId, UserId: userId}).Error; err != nil {
+			return err
+		}
+		var user model.User
+		if err := tx.Select("accepted").Where("id = ?", userId).First(&user).Error; err != nil {
+			return err
+		}
+		err := tx.
+			Model(&model.Problem{Model: gorm.Model{ID: problemId}}).
+			UpdateColumns(map[string]interface{}{
+				"solvers":         gorm.Expr("solvers + ?", 1),
+				"solver_strength": gorm.Expr("solver_strength + ?", difficulty.Strength(user.Accepted)),
+			}).Error
+		if err != nil {
+			return err
+		}
+		added = true
+		return updateDifficulty(tx, problemId)
+	})
+	if err != nil {
+		return false, errors.Wrap("problem_mapper.AddProblemSolver", err)
+	}
+	return added, nil
+}
+
+//按当前解题者的解题数重新累计解题者强度并计算难度 用于修正或补算
+func (p *ProblemMapperImpl) RecomputeDifficulty(problemId uint) (int32, error) {
+	var rating int32
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var err error
+		rating, err = recomputeDifficulty(tx, problemId)
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap("problem_mapper.RecomputeDifficulty", err)
+	}
+	return rating, nil
+}
+
+func recomputeDifficulty(tx *gorm.DB, problemId uint) (int32, error) {
+	var solved []int64
+	err := tx.
+		Table("problem_solvers").
+		Joins("join users on users.id = problem_solvers.user_id and users.deleted_at is null").
+		Where("problem_solvers.problem_id = ?", problemId).
+		Pluck("users.accepted", &solved).Error
+	if err != nil {
+		return 0, err
+	}
+	strength := 0.0
+	for _, s := range solved {
+		strength += difficulty.Strength(s)
+	}
+	err = tx.
+		Model(&model.Problem{Model: gorm.Model{ID: problemId}}).
+		UpdateColumns(map[string]interface{}{
+			"solvers":         len(solved),
+			"solver_strength": strength,
+		}).Error
+	if err != nil {
+		return 0, err
+	}
+	if err = updateDifficulty(tx, problemId); err != nil {
+		return 0, err
+	}
+	var problem model.Problem
+	if err = tx.Select("difficulty").First(&problem, problemId).Error; err != nil {
+		return 0, err
+	}
+	return problem.Difficulty, nil
+}
+
+//根据题目当前的计数重新计算难度 题目不存在时忽略
+func updateDifficulty(db *gorm.DB, problemId uint) error {
+	var problem model.Problem
+	if err := db.Select("id, submitted, accepted, solvers, solver_strength").First(&problem, problemId).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	rating := difficulty.Rate(difficulty.Stats{
+		Submitted:      problem.Submitted,
+		Accepted:       problem.Accepted,
+		Solvers:        problem.Solvers,
+		SolverStrength: problem.SolverStrength,
+	})
+	//不更新updated_at 难度变化不应影响按更新时间的排序与游标
+	return db.Model(&problem).UpdateColumn("difficulty", rating).Error
+}
@@ -0,0 +1,192 @@
+package problem_mapper
+
+import (
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+)
+
+const (
+	HighlightPre  = "<em>"
+	HighlightPost = "</em>"
+	snippetWidth  = 80
+	//迁移在mysql上创建的FULLTEXT索引
+	FullTextIndex = "ft_raw_problems_statement"
+)
+
+type ProblemSearchHit struct {
+	Problem *model.Problem
+	Score   float64
+	//命中字段的列名到高亮片段
+	Snippets map[string]string
+}
+
+type fullTextField struct {
+	column string
+	weight float64
+	value  func(*model.RawProblem) string
+}
+
+//参与全文检索的字段 与迁移中mysql的FULLTEXT索引列一致
+var fullTextFields = []fullTextField{
+	{"title", 5, func(r *model.RawProblem) string { return r.Title }},
+	{"description", 1, func(r *model.RawProblem) string { return r.Description }},
+	{"input", 1, func(r *model.RawProblem) string { return r.Input }},
+	{"output", 1, func(r *model.RawProblem) string { return r.Output }},
+	{"hint", 1, func(r *model.RawProblem) string { return r.Hint }},
+	{"source", 2, func(r *model.RawProblem) string { return r.Source }},
+}
+
+//按字段权重累计各字段命中的不同检索词个数作为相关度 并生成各字段去掉标签后的高亮片段
+//与数据库中的LIKE检索一致 在题面原文(含HTML标签)上匹配 只命中标签的字段计分但没有片段
+//mapperfake也用它排序 与数据库结果相同
+func RankRawProblem(rawProblem *model.RawProblem, terms []string) (float64, map[string]string) {
+	score := 0.0
+	snippets := make(map[string]string)
+	for _, f := range fullTextFields {
+		text := f.value(rawProblem)
+		count := util.CountMatchedTerms(text, terms)
+		if count == 0 {
+			continue
+		}
+		score += f.weight * float64(count)
+		if snippet := util.Snippet(text, terms, snippetWidth, HighlightPre, HighlightPost); snippet != "" {
+			snippets[f.column] = snippet
+		}
+	}
+	return score, snippets
+}
+
+//在公开题目的题面各字段中检索 按相关度降序返回
+//mysql上存在FULLTEXT索引时使用MATCH 否则在数据库中用LIKE筛选并打分
+func (p *ProblemMapperImpl) FullTextSearch(query string, pageNo int32, pageSize int32) ([]*ProblemSearchHit, int32, error) {
+	terms := util.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, 0, errors.InvalidArgument("problem_mapper.FullTextSearch", "search query is empty")
+	}
+	var hits []*ProblemSearchHit
+	var count int32
+	var err error
+	if p.DB.Dialect().GetName() == "mysql" && p.DB.Dialect().HasIndex("raw_problems", FullTextIndex) {
+		hits, count, err = p.fullTextSearchMysql(query, terms, pageNo, pageSize)
+	} else {
+		hits, count, err = p.fullTextSearchLike(terms, pageNo, pageSize)
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap("problem_mapper.FullTextSearch", err)
+	}
+	return hits, count, nil
+}
+
+func (p *ProblemMapperImpl) fullTextSearchMysql(query string, terms []string, pageNo int32, pageSize int32) ([]*ProblemSearchHit, int32, error) {
+	columns := make([]string, len(fullTextFields))
+	for i, f := range fullTextFields {
+		columns[i] = "raw_problems." + f.column
+	}
+	match := fmt.Sprintf("MATCH(%v) AGAINST (? IN NATURAL LANGUAGE MODE)", strings.Join(columns, ", "))
+	base := visible(p.DB.Model(&model.Problem{}), false).
+		Joins("join raw_problems on raw_problems.id = problems.raw_problem_id and raw_problems.deleted_at is null").
+		Where(match, query)
+	return p.rankedSearch(base, match, []interface{}{query}, terms, pageNo, pageSize)
+}
+
+//LIKE模式已转义通配符 相关度为各字段命中的检索词个数乘字段权重之和
+func (p *ProblemMapperImpl) fullTextSearchLike(terms []string, pageNo int32, pageSize int32) ([]*ProblemSearchHit, int32, error) {
+	conditions := make([]string, 0, len(terms)*len(fullTextFields))
+	args := make([]interface{}, 0, cap(conditions))
+	for _, term := range terms {
+		pattern := "%" + util.EscapeLike(term) + "%"
+		for _, f := range fullTextFields {
+			conditions = append(conditions, fmt.Sprintf("LOWER(raw_problems.%v) LIKE ? ESCAPE '%v'", f.column, util.LikeEscape))
+			args = append(args, pattern)
+		}
+	}
+	scores := make([]string, len(conditions))
+	for i, condition := range conditions {
+		scores[i] = fmt.Sprintf("CASE WHEN %v THEN %v ELSE 0 END", condition, fullTextFields[i%len(fullTextFields)].weight)
+	}
+	base := visible(p.DB.Model(&model.Problem{}), false).
+		Joins("join raw_problems on raw_problems.id = problems.raw_problem_id and raw_problems.deleted_at is null").
+		Where(strings.Join(conditions, " or "), args...)
+	return p.rankedSearch(base, "("+strings.Join(scores, " + ")+")", args, terms, pageNo, pageSize)
+}
+
+//在数据库中按score排序分页 只加载当前页的题目并生成高亮片段
+func (p *ProblemMapperImpl) rankedSearch(base *gorm.DB, score string, scoreArgs []interface{}, terms []string, pageNo int32, pageSize int32) ([]*ProblemSearchHit, int32, error) {
+	limit, offset := util.CalLimitOffset(pageNo, pageSize)
+	var count int32
+	if err := base.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	rows, err := base.
+		Select("problems.id, "+score+" AS score", scoreArgs...).
+		Order("score desc, problems.id").
+		Limit(limit).
+		Offset(offset).
+		Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	ids := make([]uint, 0, limit)
+	scores := make(map[uint]float64, limit)
+	for rows.Next() {
+		var id uint
+		var score float64
+		if err = rows.Scan(&id, &score); err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+		scores[id] = score
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(ids) == 0 {
+		return []*ProblemSearchHit{}, count, nil
+	}
+	var problems []*model.Problem
+	if err = p.DB.Preload("RawProblem").Find(&problems, ids).Error; err != nil {
+		return nil, 0, err
+	}
+	byId := make(map[uint]*model.Problem, len(problems))
+	for _, problem := range problems {
+		byId[problem.ID] = problem
+	}
+	hits := make([]*ProblemSearchHit, 0, len(ids))
+	for _, id := range ids {
+		problem, ok := byId[id]
+		if !ok || problem.RawProblem == nil {
+			continue
+		}
+		_, snippets := RankRawProblem(problem.RawProblem, terms)
+		hits = append(hits, &ProblemSearchHit{Problem: problem, Score: scores[id], Snippets: snippets})
+	}
+	return hits, count, nil
+}
+
+//为已加载RawProblem的题目打分 丢弃没有命中的题目 按相关度降序、id升序排列
+func RankProblems(problems []*model.Problem, terms []string) []*ProblemSearchHit {
+	hits := make([]*ProblemSearchHit, 0, len(problems))
+	for _, problem := range problems {
+		if problem.RawProblem == nil {
+			continue
+		}
+		score, snippets := RankRawProblem(problem.RawProblem, terms)
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, &ProblemSearchHit{Problem: problem, Score: score, Snippets: snippets})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Problem.ID < hits[j].Problem.ID
+	})
+	return hits
+}
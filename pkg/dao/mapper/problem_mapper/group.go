@@ -0,0 +1,301 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+//把sourceGroupId组的原始题目全部并入targetGroupId组 组内的主题目保持不变
+//两组都有题目时 提交、比赛题目、标签与解题者改为指向target组的题目 计数相加 source组的题目被删除
+func (p *ProblemMapperImpl) MergeProblemGroups(targetGroupId uint, sourceGroupId uint) (*model.Problem, error) {
+	if targetGroupId <= 0 || sourceGroupId <= 0 || targetGroupId == sourceGroupId {
+		return nil, errors.InvalidArgument("problem_mapper.MergeProblemGroups", "two different group ids are required")
+	}
+	var problemId uint
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var err error
+		problemId, err = mergeProblemGroups(tx, targetGroupId, sourceGroupId)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.MergeProblemGroups", err)
+	}
+	if problemId == 0 {
+		return nil, nil
+	}
+	return p.FindProblemById(problemId)
+}
+
+//MergeProblemGroups的事务内实现 返回合并后组的题目id 两组都没有题目时为0
+func mergeProblemGroups(tx *gorm.DB, targetGroupId uint, sourceGroupId uint) (uint, error) {
+	for _, groupId := range []uint{targetGroupId, sourceGroupId} {
+		var count int
+		if err := tx.Model(&model.ProblemGroup{}).Where("group_id = ?", groupId).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, errors.NotFound("problem_mapper.MergeProblemGroups", "group %v has no raw problems", groupId)
+		}
+	}
+	err := tx.Model(&model.ProblemGroup{}).
+		Where("group_id = ?", sourceGroupId).
+		Updates(map[string]interface{}{"group_id": targetGroupId, "main_problem": false}).Error
+	if err != nil {
+		return 0, err
+	}
+	target, err := problemByGroupId(tx, targetGroupId)
+	if err != nil {
+		return 0, err
+	}
+	source, err := problemByGroupId(tx, sourceGroupId)
+	if err != nil {
+		return 0, err
+	}
+	var problem *model.Problem
+	switch {
+	case target == nil && source == nil:
+		return 0, nil
+	case target == nil:
+		problem = source
+		if err = tx.Model(source).Update("group_id", targetGroupId).Error; err != nil {
+			return 0, err
+		}
+	case source == nil:
+		problem = target
+	default:
+		problem = target
+		if err = mergeProblems(tx, target, source); err != nil {
+			return 0, err
+		}
+	}
+	return problem.ID, markMainProblem(tx, targetGroupId, problem.RawProblemId)
+}
+
+//把原始题目从所在的组中拆出 单独成组并新建题目 原组的提交与计数留在原组的题目上
+//被拆出的是原组的主题目时 原组中id最小的原始题目成为新的主题目
+func (p *ProblemMapperImpl) SplitProblemGroup(rawProblemId uint) (*model.Problem, error) {
+	if rawProblemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.SplitProblemGroup", "raw problem id is incorrect")
+	}
+	var problemId uint
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var member model.ProblemGroup
+		if err := tx.Where("raw_problem_id = ?", rawProblemId).First(&member).Error; err != nil {
+			return err
+		}
+		var others int
+		if err := tx.Model(&model.ProblemGroup{}).Where("group_id = ? and raw_problem_id <> ?", member.GroupId, rawProblemId).Count(&others).Error; err != nil {
+			return err
+		}
+		if others == 0 {
+			return errors.Conflict("problem_mapper.SplitProblemGroup", "raw problem %v is already alone in its group", rawProblemId)
+		}
+		groupId, err := freeGroupId(tx, rawProblemId)
+		if err != nil {
+			return err
+		}
+		if err = moveToGroup(tx, rawProblemId, groupId); err != nil {
+			return err
+		}
+		problem, err := problemByGroupId(tx, groupId)
+		if err != nil {
+			return err
+		}
+		problemId = problem.ID
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.SplitProblemGroup", err)
+	}
+	return p.FindProblemById(problemId)
+}
+
+//把原始题目设为所在组的主题目 组的题目随之指向该原始题目
+func (p *ProblemMapperImpl) SetMainProblem(rawProblemId uint) error {
+	if rawProblemId <= 0 {
+		return errors.InvalidArgument("problem_mapper.SetMainProblem", "raw problem id is incorrect")
+	}
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var member model.ProblemGroup
+		if err := tx.Where("raw_problem_id = ?", rawProblemId).First(&member).Error; err != nil {
+			return err
+		}
+		problem, err := problemByGroupId(tx, member.GroupId)
+		if err != nil {
+			return err
+		}
+		if problem == nil {
+			err = tx.Create(&model.Problem{GroupId: member.GroupId, RawProblemId: rawProblemId}).Error
+		} else {
+			err = tx.Model(problem).Update("raw_problem_id", rawProblemId).Error
+		}
+		if err != nil {
+			return err
+		}
+		return markMainProblem(tx, member.GroupId, rawProblemId)
+	})
+	return errors.Wrap("problem_mapper.SetMainProblem", err)
+}
+
+//把原始题目移入groupId组 并保持两组的题目与MainProblem一致:
+//原组的题目指向该原始题目时改为指向原组中id最小的原始题目 原组已空时该题目随之移入新组 新组已有题目时合并
+//新组没有题目时新建 新组的主题目不变
+func moveToGroup(tx *gorm.DB, rawProblemId uint, groupId uint) error {
+	var member model.ProblemGroup
+	if err := tx.Where("raw_problem_id = ?", rawProblemId).First(&member).Error; err != nil {
+		return err
+	}
+	oldGroupId := member.GroupId
+	if oldGroupId != groupId {
+		err := tx.Model(&member).Updates(map[string]interface{}{"group_id": groupId, "main_problem": false}).Error
+		if err != nil {
+			return err
+		}
+		if err = repairGroup(tx, oldGroupId, rawProblemId, groupId); err != nil {
+			return err
+		}
+	}
+	problem, err := problemByGroupId(tx, groupId)
+	if err != nil {
+		return err
+	}
+	if problem == nil {
+		problem = &model.Problem{GroupId: groupId, RawProblemId: rawProblemId}
+		if err = tx.Create(problem).Error; err != nil {
+			return err
+		}
+	}
+	return markMainProblem(tx, groupId, problem.RawProblemId)
+}
+
+//原始题目离开oldGroupId组后修正原组的题目
+func repairGroup(tx *gorm.DB, oldGroupId uint, rawProblemId uint, groupId uint) error {
+	old, err := problemByGroupId(tx, oldGroupId)
+	if err != nil || old == nil {
+		return err
+	}
+	if old.RawProblemId != rawProblemId {
+		return markMainProblem(tx, oldGroupId, old.RawProblemId)
+	}
+	var next model.ProblemGroup
+	err = tx.Where("group_id = ?", oldGroupId).Order("raw_problem_id").First(&next).Error
+	if err == nil {
+		if err = tx.Model(old).Update("raw_problem_id", next.RawProblemId).Error; err != nil {
+			return err
+		}
+		return markMainProblem(tx, oldGroupId, next.RawProblemId)
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	target, err := problemByGroupId(tx, groupId)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return tx.Model(old).Update("group_id", groupId).Error
+	}
+	return mergeProblems(tx, target, old)
+}
+
+//组没有题目时返回nil
+func problemByGroupId(tx *gorm.DB, groupId uint) (*model.Problem, error) {
+	problem := &model.Problem{}
+	err := tx.Where("group_id = ?", groupId).First(problem).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return problem, nil
+}
+
+func markMainProblem(tx *gorm.DB, groupId uint, rawProblemId uint) error {
+	err := tx.Model(&model.ProblemGroup{}).
+		Where("group_id = ? and raw_problem_id <> ?", groupId, rawProblemId).
+		Update("main_problem", false).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&model.ProblemGroup{}).
+		Where("group_id = ? and raw_problem_id = ?", groupId, rawProblemId).
+		Update("main_problem", true).Error
+}
+
+//优先沿用组号等于原始题目id的约定 已被占用时取现有最大组号加一
+func freeGroupId(tx *gorm.DB, rawProblemId uint) (uint, error) {
+	var used int
+	if err := tx.Unscoped().Model(&model.ProblemGroup{}).Where("group_id = ?", rawProblemId).Count(&used).Error; err != nil {
+		return 0, err
+	}
+	var problems int
+	if err := tx.Unscoped().Model(&model.Problem{}).Where("group_id = ?", rawProblemId).Count(&problems).Error; err != nil {
+		return 0, err
+	}
+	if used == 0 && problems == 0 {
+		return rawProblemId, nil
+	}
+	var maxGroup, maxProblem uint
+	if err := tx.Unscoped().Model(&model.ProblemGroup{}).Select("coalesce(max(group_id), 0)").Row().Scan(&maxGroup); err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Model(&model.Problem{}).Select("coalesce(max(group_id), 0)").Row().Scan(&maxProblem); err != nil {
+		return 0, err
+	}
+	if maxProblem > maxGroup {
+		maxGroup = maxProblem
+	}
+	return maxGroup + 1, nil
+}
+
+//把source题目的提交、比赛题目、标签与解题者转到target 计数相加后删除source
+func mergeProblems(tx *gorm.DB, target *model.Problem, source *model.Problem) error {
+	err := tx.Unscoped().Model(&model.Submission{}).
+		Where("problem_id = ?", source.ID).
+		UpdateColumn("problem_id", target.ID).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&model.ContestProblem{}).
+		Where("problem_id = ?", source.ID).
+		UpdateColumn("problem_id", target.ID).Error
+	if err != nil {
+		return err
+	}
+	if err = moveUnique(tx, "problem_tags", "tag_id", target.ID, source.ID); err != nil {
+		return err
+	}
+	if err = moveUnique(tx, "problem_solvers", "user_id", target.ID, source.ID); err != nil {
+		return err
+	}
+	err = tx.Model(target).UpdateColumns(map[string]interface{}{
+		"submitted": gorm.Expr("submitted + ?", source.Submitted),
+		"accepted":  gorm.Expr("accepted + ?", source.Accepted),
+	}).Error
+	if err != nil {
+		return err
+	}
+	if _, err = recomputeDifficulty(tx, target.ID); err != nil {
+		return err
+	}
+	return tx.Delete(source).Error
+}
+
+//(problem_id, column)唯一的关联表: target没有的行改为指向target 其余删除
+func moveUnique(tx *gorm.DB, table string, column string, targetId uint, sourceId uint) error {
+	var existing []uint
+	if err := tx.Table(table).Where("problem_id = ?", targetId).Pluck(column, &existing).Error; err != nil {
+		return err
+	}
+	move := tx.Table(table).Where("problem_id = ?", sourceId)
+	if len(existing) > 0 {
+		move = move.Where(column+" not in (?)", existing)
+	}
+	if err := move.UpdateColumn("problem_id", targetId).Error; err != nil {
+		return err
+	}
+	return tx.Table(table).Where("problem_id = ?", sourceId).Delete(nil).Error
+}
@@ -0,0 +1,67 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"strings"
+	"sync"
+)
+
+//把RawProblem的TimeLimit、MemoryLimit解析为毫秒与KB 无法解析的返回0
+type LimitNormalizer func(rawProblem *model.RawProblem) (timeLimitMs int32, memoryLimitKb int32)
+
+var (
+	limitNormalizersMu sync.RWMutex
+	limitNormalizers   = map[remote_oj.RemoteOJ]LimitNormalizer{
+		remote_oj.HDU: hduLimits,
+	}
+)
+
+//为远程oj注册时空限制解析器 未注册的oj使用util.ParseTimeLimit与util.ParseMemoryLimit
+//normalizer为nil时取消注册
+func RegisterLimitNormalizer(oj remote_oj.RemoteOJ, normalizer LimitNormalizer) {
+	limitNormalizersMu.Lock()
+	defer limitNormalizersMu.Unlock()
+	if normalizer == nil {
+		delete(limitNormalizers, oj)
+		return
+	}
+	limitNormalizers[oj] = normalizer
+}
+
+//按RemoteOJ解析时空限制并写入TimeLimitMs、MemoryLimitKb 解析不出的字段保持原值
+func NormalizeLimits(rawProblem *model.RawProblem) {
+	limitNormalizersMu.RLock()
+	normalizer, ok := limitNormalizers[rawProblem.RemoteOJ]
+	limitNormalizersMu.RUnlock()
+	if !ok {
+		normalizer = defaultLimits
+	}
+	timeLimitMs, memoryLimitKb := normalizer(rawProblem)
+	if timeLimitMs > 0 {
+		rawProblem.TimeLimitMs = timeLimitMs
+	}
+	if memoryLimitKb > 0 {
+		rawProblem.MemoryLimitKb = memoryLimitKb
+	}
+}
+
+func defaultLimits(rawProblem *model.RawProblem) (int32, int32) {
+	return util.ParseTimeLimit(rawProblem.TimeLimit), util.ParseMemoryLimit(rawProblem.MemoryLimit)
+}
+
+func hduLimits(rawProblem *model.RawProblem) (int32, int32) {
+	return util.ParseTimeLimit(hduOthersLimit(rawProblem.TimeLimit)), util.ParseMemoryLimit(hduOthersLimit(rawProblem.MemoryLimit))
+}
+
+//HDU的限制形如"2000/1000 MS (Java/Others)" 取斜线后其他语言的限制
+func hduOthersLimit(limit string) string {
+	if i := strings.Index(limit, "("); i >= 0 {
+		limit = limit[:i]
+	}
+	if i := strings.LastIndex(limit, "/"); i >= 0 {
+		limit = limit[i+1:]
+	}
+	return limit
+}
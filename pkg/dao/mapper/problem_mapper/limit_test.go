@@ -0,0 +1,23 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"testing"
+)
+
+func TestNormalizeLimits(t *testing.T) {
+	raw := &model.RawProblem{RemoteOJ: remote_oj.HDU, TimeLimit: "2000/1000 MS (Java/Others)", MemoryLimit: "65536/32768 K (Java/Others)"}
+	NormalizeLimits(raw)
+	if raw.TimeLimitMs != 1000 || raw.MemoryLimitKb != 32768 {
+		t.Fatalf("unexpected hdu limits: %v ms %v KB", raw.TimeLimitMs, raw.MemoryLimitKb)
+	}
+	RegisterLimitNormalizer(remote_oj.HDU, func(rawProblem *model.RawProblem) (int32, int32) {
+		return 3000, 0
+	})
+	defer RegisterLimitNormalizer(remote_oj.HDU, hduLimits)
+	NormalizeLimits(raw)
+	if raw.TimeLimitMs != 3000 || raw.MemoryLimitKb != 32768 {
+		t.Fatalf("registered normalizer not used or zero overwrote: %v ms %v KB", raw.TimeLimitMs, raw.MemoryLimitKb)
+	}
+}
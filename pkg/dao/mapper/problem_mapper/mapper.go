@@ -1,18 +1,52 @@
 package problem_mapper
 
 import (
+	"context"
 	"fmt"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
 	"github.com/ecnuvj/vhoj_db/pkg/util"
 	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
 )
 
+//零值字段不参与过滤
 type ProblemSearchParam struct {
 	Title     string
 	ProblemId uint
+	RemoteOJ  remote_oj.RemoteOJ
+	//来源 模糊匹配
+	Source string
+	//题目状态 非公开的状态只在AllStatuses为true时能匹配到题目
+	Status *model.ProblemStatus
+	//管理员视图 匹配所有状态的题目
+	AllStatuses bool
+	//通过率accepted/submitted的闭区间 没有提交的题目通过率为0
+	MinAcceptRatio float64
+	MaxAcceptRatio float64
+	//题目需同时带有全部标签
+	Tags []string
+	//时间限制(毫秒)与内存限制(KB)的闭区间 设置后限制未知的题目不会被匹配
+	MinTimeLimitMs   int32
+	MaxTimeLimitMs   int32
+	MinMemoryLimitKb int32
+	MaxMemoryLimitKb int32
 }
 
+//没有任何过滤条件
+func (param *ProblemSearchParam) IsEmpty() bool {
+	return param.Title == "" && param.ProblemId == 0 && param.RemoteOJ == 0 && param.Source == "" &&
+		param.Status == nil && param.MinAcceptRatio == 0 && param.MaxAcceptRatio == 0 && len(param.Tags) == 0 &&
+		param.MinTimeLimitMs == 0 && param.MaxTimeLimitMs == 0 && param.MinMemoryLimitKb == 0 && param.MaxMemoryLimitKb == 0
+}
+
+//没有提交时通过率为0 乘1.0避免整数除法
+const acceptRatioExpr = "CASE WHEN problems.submitted = 0 THEN 0 ELSE problems.accepted * 1.0 / problems.submitted END"
+
 type IProblemMapper interface {
+	WithContext(ctx context.Context) IProblemMapper
 	AddOrModifyRawProblem(*model.RawProblem) (*model.RawProblem, error)
 	AddProblemSubmittedCountById(uint) error
 	AddProblemAcceptedCountById(uint) error
@@ -22,14 +56,43 @@ type IProblemMapper interface {
 	AddOrModifyProblem(*model.Problem) (*model.Problem, error)
 	UpdateProblemGroupId(uint, uint) error
 	FindGroupProblemsById(uint) ([]*model.ProblemGroup, error)
-	FindAllProblems(int32, int32, bool) ([]*model.Problem, int32, error)
-	FindProblemById(uint) (*model.Problem, error)
-	FindProblemsByIds([]uint) ([]*model.Problem, error)
+	FindAllProblems(int32, int32, bool, ...*ProblemListOption) ([]*model.Problem, int32, error)
+	FindAllProblemsByCursor(cursor string, pageSize int32, options ...*ProblemListOption) ([]*model.Problem, *util.CursorPage, error)
+	FindProblemById(problemId uint, locale ...string) (*model.Problem, error)
+	FindProblemsByIds(problemIds []uint, locale ...string) ([]*model.Problem, error)
 	SearchProblemByCondition(*ProblemSearchParam, int32, int32) ([]*model.Problem, int32, error)
+	FullTextSearch(query string, pageNo int32, pageSize int32) ([]*ProblemSearchHit, int32, error)
+	AttachProblemTags(problemId uint, names []string) ([]*model.Tag, error)
+	DetachProblemTags(problemId uint, names []string) error
+	FindProblemTags(problemId uint) ([]*model.Tag, error)
+	CountTags(options ...*ProblemListOption) ([]*TagCount, error)
+	ImportRemoteTags(problemId uint) ([]*model.Tag, error)
+	AddProblemSolver(problemId uint, userId uint) (bool, error)
+	RecomputeDifficulty(problemId uint) (int32, error)
+	FindRawProblemRevisions(rawProblemId uint) ([]*model.RawProblemRevision, error)
+	DiffRawProblemRevisions(rawProblemId uint, from int32, to int32) ([]*RevisionFieldDiff, error)
+	ReplaceProblemSamples(rawProblemId uint, samples []*model.ProblemSample) ([]*model.ProblemSample, error)
+	FindProblemSamples(rawProblemId uint) ([]*model.ProblemSample, error)
+	RegisterProblemAsset(asset *model.ProblemAsset) (*model.ProblemAsset, error)
+	FindProblemAssets(rawProblemId uint) ([]*model.ProblemAsset, error)
+	RewriteAssetUrls(rawProblemId uint) (*model.RawProblem, error)
+	AddOrModifyTranslation(translation *model.RawProblemTranslation) (*model.RawProblemTranslation, error)
+	FindRawProblemTranslations(rawProblemId uint) ([]*model.RawProblemTranslation, error)
+	FindLocalizedRawProblem(rawProblemId uint, locale string) (*model.RawProblem, error)
+	SuggestGroupMerges(rawProblemId uint, minScore float64) ([]*model.GroupSuggestion, error)
+	FindGroupSuggestions(status int32, pageNo int32, pageSize int32) ([]*model.GroupSuggestion, int32, error)
+	AcceptGroupSuggestion(suggestionId uint) error
+	RejectGroupSuggestion(suggestionId uint) error
 	DeleteProblemById(uint) error
-	FindProblemByRandom() (*model.Problem, error)
+	FindProblemByRandom(options ...*ProblemListOption) (*model.Problem, error)
 	FindRawProblemsWithGroup(int32, int32) ([]*model.RawProblem, []*model.ProblemGroup, int32, error)
 	UpdateProblemGroup(uint, uint) error
+	MergeProblemGroups(targetGroupId uint, sourceGroupId uint) (*model.Problem, error)
+	SplitProblemGroup(rawProblemId uint) (*model.Problem, error)
+	SetMainProblem(rawProblemId uint) error
+	RecordRemoteResult(submissionId uint, success bool) error
+	FindRemoteTargets(problemId uint) ([]*model.ProblemGroup, error)
+	UpdateProblemStatus(problemId uint, status model.ProblemStatus) (*model.Problem, error)
 }
 
 var ProblemMapper IProblemMapper
@@ -38,30 +101,78 @@ type ProblemMapperImpl struct {
 	DB *gorm.DB
 }
 
-func InitMapper(db *gorm.DB) {
-	ProblemMapper = &ProblemMapperImpl{
+func NewMapper(db *gorm.DB) IProblemMapper {
+	return &ProblemMapperImpl{
 		DB: db,
 	}
 }
 
+func InitMapper(db *gorm.DB) {
+	ProblemMapper = NewMapper(db)
+}
+
+//返回绑定ctx的mapper 其所有查询都会随ctx取消或超时而中断
+func (p *ProblemMapperImpl) WithContext(ctx context.Context) IProblemMapper {
+	return NewMapper(util.WithContext(p.DB, ctx))
+}
+
+//按remote_oj与remote_problem_id新增或更新 只有非零字段参与更新
+//时空限制按RemoteOJ解析为数值 已登记附件的原地址改写为本地地址
+//内容没有变化时不写库 题面变化时追加一个RawProblemRevision
 func (p *ProblemMapperImpl) AddOrModifyRawProblem(rawProblem *model.RawProblem) (*model.RawProblem, error) {
-	var problem model.RawProblem
-	if err := p.DB.Where("remote_oj = ? and remote_problem_id = ?", rawProblem.RemoteOJ, rawProblem.RemoteProblemId).First(&problem).Error; err != nil {
+	NormalizeLimits(rawProblem)
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		var stored model.RawProblem
+		err := tx.Where("remote_oj = ? and remote_problem_id = ?", rawProblem.RemoteOJ, rawProblem.RemoteProblemId).First(&stored).Error
 		if gorm.IsRecordNotFoundError(err) {
-			result := p.DB.Create(rawProblem)
-			if result.Error != nil {
-				return nil, result.Error
+			rawProblem.ContentHash = StatementHash(rawProblem)
+			if err = tx.Create(rawProblem).Error; err != nil {
+				return err
 			}
+			return recordStatement(tx, rawProblem)
 		}
-	} else {
-		result := p.DB.
-			Model(rawProblem).
-			Where("remote_oj = ? and remote_problem_id = ?", rawProblem.RemoteOJ, rawProblem.RemoteProblemId).
-			Update(rawProblem).
-			First(rawProblem)
-		if result.Error != nil {
-			return nil, result.Error
+		if err != nil {
+			return err
+		}
+		if stored.ContentHash == "" {
+			//修订历史上线前写入的题目 先把当前题面记为第一个修订
+			stored.ContentHash = StatementHash(&stored)
+			if err = tx.Model(&stored).UpdateColumn("content_hash", stored.ContentHash).Error; err != nil {
+				return err
+			}
+			if err = recordStatement(tx, &stored); err != nil {
+				return err
+			}
+		}
+		if err = applyStoredAssetUrls(tx, stored.ID, rawProblem); err != nil {
+			return err
+		}
+		changes := changedColumns(tx, &stored, rawProblem)
+		if len(changes) == 0 {
+			*rawProblem = stored
+			return nil
+		}
+		if err = tx.Model(&stored).Updates(changes).Error; err != nil {
+			return err
+		}
+		var updated model.RawProblem
+		if err = tx.First(&updated, stored.ID).Error; err != nil {
+			return err
+		}
+		if hash := StatementHash(&updated); hash != updated.ContentHash {
+			updated.ContentHash = hash
+			if err = tx.Model(&updated).UpdateColumn("content_hash", hash).Error; err != nil {
+				return err
+			}
+			if err = recordStatement(tx, &updated); err != nil {
+				return err
+			}
 		}
+		*rawProblem = updated
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.AddOrModifyRawProblem", err)
 	}
 	return rawProblem, nil
 }
@@ -74,66 +185,96 @@ func (p *ProblemMapperImpl) FindGroupProblemsById(problemId uint) ([]*model.Prob
 		Where("id = ?", problemId).
 		First(&problem)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("problem_mapper.FindGroupProblemsById", result.Error)
 	}
 	result = p.DB.
 		Model(&model.ProblemGroup{}).
 		Where("group_id = ?", problem.GroupId).
 		Find(&problemGroups)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("problem_mapper.FindGroupProblemsById", result.Error)
 	}
 	return problemGroups, nil
 }
 
-func (p *ProblemMapperImpl) FindAllProblems(page int32, pageSize int32, desc bool) ([]*model.Problem, int32, error) {
+func (p *ProblemMapperImpl) FindAllProblems(page int32, pageSize int32, desc bool, options ...*ProblemListOption) ([]*model.Problem, int32, error) {
 	limit, offset := util.CalLimitOffset(page, pageSize)
 	var count int32
 	var problems []*model.Problem
-	result := p.DB.
-		Model(&model.Problem{}).
+	result := listFilter(p.DB.Model(&model.Problem{}), options)
+	sortByDifficulty := false
+	for _, option := range options {
+		sortByDifficulty = sortByDifficulty || (option != nil && option.SortByDifficulty)
+	}
+	result = result.
 		Count(&count).
 		Preload("RawProblem").
 		Limit(limit).
 		Offset(offset)
-	if desc {
+	if sortByDifficulty {
+		if desc {
+			result = result.Order("difficulty desc, id")
+		} else {
+			result = result.Order("difficulty, id")
+		}
+	} else if desc {
 		result = result.Order("updated_at desc")
 	}
-	result.Find(&problems)
+	result = result.Find(&problems)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("problem_mapper.FindAllProblems", result.Error)
 	}
 	return problems, count, nil
 }
 
+//keyset分页 按updated_at desc, id desc排序 cursor为空时返回第一页
+//options的过滤条件与FindAllProblems相同 排序固定 SortByDifficulty不生效
+func (p *ProblemMapperImpl) FindAllProblemsByCursor(cursor string, pageSize int32, options ...*ProblemListOption) ([]*model.Problem, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("problem_mapper.FindAllProblemsByCursor", "%v", err)
+	}
+	var problems []*model.Problem
+	result := util.KeysetQuery(listFilter(p.DB.Model(&model.Problem{}), options).Preload("RawProblem"), "", c, pageSize).
+		Find(&problems)
+	if result.Error != nil {
+		return nil, nil, errors.Wrap("problem_mapper.FindAllProblemsByCursor", result.Error)
+	}
+	n, page := util.KeysetPage(problems, c, pageSize, func(i int) (time.Time, uint) {
+		return problems[i].UpdatedAt, problems[i].ID
+	})
+	return problems[:n], page, nil
+}
+
 func (p *ProblemMapperImpl) AddProblemSubmittedCountById(problemId uint) error {
 	if problemId <= 0 {
-		return fmt.Errorf("problem id is incorrect")
+		return errors.InvalidArgument("problem_mapper.AddProblemSubmittedCountById", "problem id is incorrect")
 	}
 	result := p.DB.
 		Model(&model.Problem{Model: gorm.Model{ID: problemId}}).
 		Update("submitted", gorm.Expr("submitted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("problem_mapper.AddProblemSubmittedCountById", result.Error)
 	}
-	return nil
+	return errors.Wrap("problem_mapper.AddProblemSubmittedCountById", updateDifficulty(p.DB, problemId))
 }
 func (p *ProblemMapperImpl) AddProblemAcceptedCountById(problemId uint) error {
 	if problemId <= 0 {
-		return fmt.Errorf("problem id is incorrect")
+		return errors.InvalidArgument("problem_mapper.AddProblemAcceptedCountById", "problem id is incorrect")
 	}
 	result := p.DB.
 		Model(&model.Problem{Model: gorm.Model{ID: problemId}}).
 		Update("accepted", gorm.Expr("accepted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("problem_mapper.AddProblemAcceptedCountById", result.Error)
 	}
-	return nil
+	return errors.Wrap("problem_mapper.AddProblemAcceptedCountById", updateDifficulty(p.DB, problemId))
 }
 
-func (p *ProblemMapperImpl) FindProblemById(problemId uint) (*model.Problem, error) {
+//传入locale时题面使用该语言的翻译 没有翻译时为原文
+func (p *ProblemMapperImpl) FindProblemById(problemId uint, locale ...string) (*model.Problem, error) {
 	if problemId <= 0 {
-		return nil, fmt.Errorf("problem id is incorrect")
+		return nil, errors.InvalidArgument("problem_mapper.FindProblemById", "problem id is incorrect")
 	}
 	var problem = &model.Problem{
 		Model: gorm.Model{
@@ -146,50 +287,110 @@ func (p *ProblemMapperImpl) FindProblemById(problemId uint) (*model.Problem, err
 		First(problem).
 		Related(problem.RawProblem)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("problem_mapper.FindProblemById", result.Error)
+	}
+	if err := p.localize([]*model.RawProblem{problem.RawProblem}, firstLocale(locale)); err != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemById", err)
 	}
 	return problem, nil
 }
 
-func (p *ProblemMapperImpl) FindProblemsByIds(problemIds []uint) ([]*model.Problem, error) {
+//传入locale时题面使用该语言的翻译 没有翻译时为原文
+func (p *ProblemMapperImpl) FindProblemsByIds(problemIds []uint, locale ...string) ([]*model.Problem, error) {
 	var problems []*model.Problem
 	result := p.DB.
 		Model(&model.Problem{}).
 		Preload("RawProblem").
 		Find(&problems, problemIds)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("problem_mapper.FindProblemsByIds", result.Error)
+	}
+	rawProblems := make([]*model.RawProblem, len(problems))
+	for i, problem := range problems {
+		rawProblems[i] = problem.RawProblem
+	}
+	if err := p.localize(rawProblems, firstLocale(locale)); err != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemsByIds", err)
 	}
 	return problems, nil
 }
 
+//与raw_problems连表 过滤、计数与分页都在数据库中完成
 func (p *ProblemMapperImpl) SearchProblemByCondition(param *ProblemSearchParam, pageNo int32, pageSize int32) ([]*model.Problem, int32, error) {
-	if (param.ProblemId == 0 && param.Title == "") || param == nil {
-		return p.FindAllProblems(pageNo, pageSize, false)
+	if param == nil || param.IsEmpty() {
+		return p.FindAllProblems(pageNo, pageSize, false, &ProblemListOption{AllStatuses: param != nil && param.AllStatuses})
 	}
 	limit, offset := util.CalLimitOffset(pageNo, pageSize)
-	result := p.DB
+	query := p.DB.
+		Model(&model.Problem{}).
+		Joins("join raw_problems on raw_problems.id = problems.raw_problem_id and raw_problems.deleted_at is null")
+	//转义通配符 标题与来源中的%、_按字面匹配
 	if param.Title != "" {
-		result = result.Preload("RawProblem", "title LIKE ?", fmt.Sprintf("%%%v%%", param.Title))
-	} else {
-		result = result.Preload("RawProblem")
+		query = query.Where(fmt.Sprintf("LOWER(raw_problems.title) LIKE ? ESCAPE '%v'", util.LikeEscape), "%"+util.EscapeLike(strings.ToLower(param.Title))+"%")
 	}
 	if param.ProblemId != 0 {
-		result = result.Where("id = ?", param.ProblemId)
+		query = query.Where("problems.id = ?", param.ProblemId)
 	}
-	var problems []*model.Problem
-	result = result.Find(&problems)
-	if result.Error != nil {
-		return nil, 0, result.Error
+	if param.RemoteOJ != 0 {
+		query = query.Where("raw_problems.remote_oj = ?", param.RemoteOJ)
+	}
+	if param.Source != "" {
+		query = query.Where(fmt.Sprintf("LOWER(raw_problems.source) LIKE ? ESCAPE '%v'", util.LikeEscape), "%"+util.EscapeLike(strings.ToLower(param.Source))+"%")
+	}
+	if param.Status != nil {
+		query = query.Where("problems.status = ?", *param.Status)
+	}
+	query = visible(query, param.AllStatuses)
+	if param.MinAcceptRatio > 0 {
+		query = query.Where(acceptRatioExpr+" >= ?", param.MinAcceptRatio)
+	}
+	if param.MaxAcceptRatio > 0 {
+		query = query.Where(acceptRatioExpr+" <= ?", param.MaxAcceptRatio)
 	}
-	retProblems := make([]*model.Problem, 0)
-	for _, problem := range problems {
-		if problem.RawProblem != nil {
-			retProblems = append(retProblems, problem)
+	if param.MinTimeLimitMs > 0 || param.MaxTimeLimitMs > 0 {
+		query = query.Where("raw_problems.time_limit_ms > 0")
+		if param.MinTimeLimitMs > 0 {
+			query = query.Where("raw_problems.time_limit_ms >= ?", param.MinTimeLimitMs)
 		}
+		if param.MaxTimeLimitMs > 0 {
+			query = query.Where("raw_problems.time_limit_ms <= ?", param.MaxTimeLimitMs)
+		}
+	}
+	if param.MinMemoryLimitKb > 0 || param.MaxMemoryLimitKb > 0 {
+		query = query.Where("raw_problems.memory_limit_kb > 0")
+		if param.MinMemoryLimitKb > 0 {
+			query = query.Where("raw_problems.memory_limit_kb >= ?", param.MinMemoryLimitKb)
+		}
+		if param.MaxMemoryLimitKb > 0 {
+			query = query.Where("raw_problems.memory_limit_kb <= ?", param.MaxMemoryLimitKb)
+		}
+	}
+	if tags := NormalizeTags(param.Tags); len(tags) > 0 {
+		tagged := p.DB.
+			Table("problem_tags").
+			Select("problem_tags.problem_id").
+			Joins("join tags on tags.id = problem_tags.tag_id").
+			Where("tags.name in (?)", tags).
+			Group("problem_tags.problem_id").
+			Having("count(distinct tags.id) = ?", len(tags))
+		query = query.Where("problems.id in ?", tagged.SubQuery())
+	}
+	var count int32
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrap("problem_mapper.SearchProblemByCondition", err)
 	}
-	left, right := util.CalSliceLeftRight(limit, offset, int32(cap(retProblems)))
-	return retProblems[left:right], int32(len(retProblems)), nil
+	var problems []*model.Problem
+	result := query.
+		Select("problems.*").
+		Preload("RawProblem").
+		Order("problems.id").
+		Limit(limit).
+		Offset(offset).
+		Find(&problems)
+	if result.Error != nil {
+		return nil, 0, errors.Wrap("problem_mapper.SearchProblemByCondition", result.Error)
+	}
+	return problems, count, nil
 }
 
 func (p *ProblemMapperImpl) AddOrModifyProblemGroup(group *model.ProblemGroup) (*model.ProblemGroup, error) {
@@ -198,8 +399,10 @@ func (p *ProblemMapperImpl) AddOrModifyProblemGroup(group *model.ProblemGroup) (
 		if gorm.IsRecordNotFoundError(err) {
 			result := p.DB.Create(group)
 			if result.Error != nil {
-				return nil, result.Error
+				return nil, errors.Wrap("problem_mapper.AddOrModifyProblemGroup", result.Error)
 			}
+		} else {
+			return nil, errors.Wrap("problem_mapper.AddOrModifyProblemGroup", err)
 		}
 	} else {
 		result := p.DB.
@@ -208,40 +411,44 @@ func (p *ProblemMapperImpl) AddOrModifyProblemGroup(group *model.ProblemGroup) (
 			Update(group).
 			First(group)
 		if result.Error != nil {
-			return nil, result.Error
+			return nil, errors.Wrap("problem_mapper.AddOrModifyProblemGroup", result.Error)
 		}
 	}
 	return group, nil
 }
 
+//Deprecated: 与UpdateProblemGroup相同 保留旧名以兼容调用方
 func (p *ProblemMapperImpl) UpdateProblemGroupId(rawProblemId uint, groupId uint) error {
-	result := p.DB.
-		Model(&model.ProblemGroup{}).
-		Where("raw_problem_id = ?", rawProblemId).
-		Update("group_id", groupId)
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		return moveToGroup(tx, rawProblemId, groupId)
+	})
+	return errors.Wrap("problem_mapper.UpdateProblemGroupId", err)
 }
 
 func (p *ProblemMapperImpl) AddOrModifyProblem(problem *model.Problem) (*model.Problem, error) {
 	var tmpProblem model.Problem
 	if err := p.DB.Where("group_id = ?", problem.GroupId).First(&tmpProblem).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
+			if !problem.Status.Valid() {
+				return nil, errors.InvalidArgument("problem_mapper.AddOrModifyProblem", "unknown problem status %v", int32(problem.Status))
+			}
 			result := p.DB.Create(problem)
 			if result.Error != nil {
-				return nil, result.Error
+				return nil, errors.Wrap("problem_mapper.AddOrModifyProblem", result.Error)
 			}
+		} else {
+			return nil, errors.Wrap("problem_mapper.AddOrModifyProblem", err)
 		}
 	} else {
+		//状态只能经UpdateProblemStatus按合法的转换修改
 		result := p.DB.
 			Model(problem).
 			Where("group_id = ?", problem.GroupId).
+			Omit("status").
 			Update(problem).
 			First(problem)
 		if result.Error != nil {
-			return nil, result.Error
+			return nil, errors.Wrap("problem_mapper.AddOrModifyProblem", result.Error)
 		}
 	}
 	return problem, nil
@@ -253,7 +460,7 @@ func (p *ProblemMapperImpl) AddContestProblemSubmittedCountById(contestId uint,
 		Where("contest_id = ? and problem_id = ?", contestId, problemId).
 		Update("submitted", gorm.Expr("submitted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("problem_mapper.AddContestProblemSubmittedCountById", result.Error)
 	}
 	return nil
 }
@@ -264,7 +471,7 @@ func (p *ProblemMapperImpl) AddContestProblemAcceptedCountById(contestId uint, p
 		Where("contest_id = ? and problem_id = ?", contestId, problemId).
 		Update("accepted", gorm.Expr("accepted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("problem_mapper.AddContestProblemAcceptedCountById", result.Error)
 	}
 	return nil
 }
@@ -275,18 +482,18 @@ func (p *ProblemMapperImpl) DeleteProblemById(problemId uint) error {
 			ID: problemId,
 		},
 	}
-	return p.DB.Delete(problem).Error
+	return errors.Wrap("problem_mapper.DeleteProblemById", p.DB.Delete(problem).Error)
 }
 
-func (p *ProblemMapperImpl) FindProblemByRandom() (*model.Problem, error) {
+//options的过滤条件与FindAllProblems相同
+func (p *ProblemMapperImpl) FindProblemByRandom(options ...*ProblemListOption) (*model.Problem, error) {
 	var problem model.Problem
-	result := p.DB.
-		Model(&problem).
-		Order("rand()").
+	result := listFilter(p.DB.Model(&problem), options).
+		Order(util.RandomFunc(p.DB)).
 		Limit(1).
 		Find(&problem)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("problem_mapper.FindProblemByRandom", result.Error)
 	}
 	return &problem, nil
 }
@@ -304,7 +511,7 @@ func (p *ProblemMapperImpl) FindRawProblemsWithGroup(pageNo int32, pageSize int3
 		Find(&rawProblems).
 		Error
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, nil, 0, errors.Wrap("problem_mapper.FindRawProblemsWithGroup", err)
 	}
 	rawProblemIds := make([]uint, 0, len(rawProblems))
 	for _, rawProblem := range rawProblems {
@@ -316,35 +523,14 @@ func (p *ProblemMapperImpl) FindRawProblemsWithGroup(pageNo int32, pageSize int3
 		Where("raw_problem_id in (?)", rawProblemIds).
 		Find(&problemGroups).Error
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, nil, 0, errors.Wrap("problem_mapper.FindRawProblemsWithGroup", err)
 	}
 	return rawProblems, problemGroups, count, nil
 }
+//把单个原始题目移入groupId组 见moveToGroup 需要整组合并时使用MergeProblemGroups
 func (p *ProblemMapperImpl) UpdateProblemGroup(rawProblemId uint, groupId uint) error {
-	tx := p.DB.Debug().Begin()
-	err := tx.
-		Model(&model.ProblemGroup{}).
-		Where("raw_problem_id = ?", rawProblemId).
-		Update("group_id", groupId).Error
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	var problem model.Problem
-	if err = tx.Where("group_id = ?", groupId).First(&problem).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			problem = model.Problem{
-				GroupId:      groupId,
-				RawProblemId: rawProblemId,
-			}
-			if err = tx.Create(&problem).Error; err != nil {
-				tx.Rollback()
-				return err
-			}
-		} else {
-			tx.Rollback()
-			return err
-		}
-	}
-	return tx.Commit().Error
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		return moveToGroup(tx, rawProblemId, groupId)
+	})
+	return errors.Wrap("problem_mapper.UpdateProblemGroup", err)
 }
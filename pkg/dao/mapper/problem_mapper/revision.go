@@ -0,0 +1,150 @@
+package problem_mapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"reflect"
+	"strconv"
+)
+
+type RevisionFieldDiff struct {
+	//列名
+	Field string
+	Old   string
+	New   string
+	Lines []*util.DiffLine
+}
+
+type statementField struct {
+	column string
+	value  func(*model.RawProblemRevision) string
+}
+
+//参与修订历史与内容哈希的题面字段
+var statementFields = []statementField{
+	{"title", func(r *model.RawProblemRevision) string { return r.Title }},
+	{"description", func(r *model.RawProblemRevision) string { return r.Description }},
+	{"sample_input", func(r *model.RawProblemRevision) string { return r.SampleInput }},
+	{"sample_output", func(r *model.RawProblemRevision) string { return r.SampleOutput }},
+	{"input", func(r *model.RawProblemRevision) string { return r.Input }},
+	{"output", func(r *model.RawProblemRevision) string { return r.Output }},
+	{"hint", func(r *model.RawProblemRevision) string { return r.Hint }},
+	{"time_limit", func(r *model.RawProblemRevision) string { return r.TimeLimit }},
+	{"memory_limit", func(r *model.RawProblemRevision) string { return r.MemoryLimit }},
+	{"source", func(r *model.RawProblemRevision) string { return r.Source }},
+}
+
+//以原始题目当前的题面生成一个修订 Revision由调用方填写
+func NewRawProblemRevision(rawProblem *model.RawProblem) *model.RawProblemRevision {
+	revision := &model.RawProblemRevision{
+		RawProblemId: rawProblem.ID,
+		Title:        rawProblem.Title,
+		Description:  rawProblem.Description,
+		SampleInput:  rawProblem.SampleInput,
+		SampleOutput: rawProblem.SampleOutput,
+		Input:        rawProblem.Input,
+		Output:       rawProblem.Output,
+		Hint:         rawProblem.Hint,
+		TimeLimit:    rawProblem.TimeLimit,
+		MemoryLimit:  rawProblem.MemoryLimit,
+		Source:       rawProblem.Source,
+	}
+	revision.ContentHash = revisionHash(revision)
+	return revision
+}
+
+//题面内容的sha256 字段以列名和长度分隔 避免内容拼接产生歧义
+func StatementHash(rawProblem *model.RawProblem) string {
+	return NewRawProblemRevision(rawProblem).ContentHash
+}
+
+func revisionHash(revision *model.RawProblemRevision) string {
+	h := sha256.New()
+	for _, f := range statementFields {
+		value := f.value(revision)
+		h.Write([]byte(f.column))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(len(value))))
+		h.Write([]byte{0})
+		h.Write([]byte(value))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//比较两个修订 只返回有变化的字段
+func DiffRevisions(from *model.RawProblemRevision, to *model.RawProblemRevision) []*RevisionFieldDiff {
+	diffs := make([]*RevisionFieldDiff, 0)
+	for _, f := range statementFields {
+		oldValue, newValue := f.value(from), f.value(to)
+		if oldValue == newValue {
+			continue
+		}
+		diffs = append(diffs, &RevisionFieldDiff{
+			Field: f.column,
+			Old:   oldValue,
+			New:   newValue,
+			Lines: util.DiffLines(oldValue, newValue),
+		})
+	}
+	return diffs
+}
+
+//按版本号降序 最新的修订在前
+func (p *ProblemMapperImpl) FindRawProblemRevisions(rawProblemId uint) ([]*model.RawProblemRevision, error) {
+	var revisions []*model.RawProblemRevision
+	result := p.DB.
+		Where("raw_problem_id = ?", rawProblemId).
+		Order("revision desc").
+		Find(&revisions)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.FindRawProblemRevisions", result.Error)
+	}
+	return revisions, nil
+}
+
+func (p *ProblemMapperImpl) DiffRawProblemRevisions(rawProblemId uint, from int32, to int32) ([]*RevisionFieldDiff, error) {
+	var fromRevision, toRevision model.RawProblemRevision
+	if err := p.DB.Where("raw_problem_id = ? and revision = ?", rawProblemId, from).First(&fromRevision).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.DiffRawProblemRevisions", err)
+	}
+	if err := p.DB.Where("raw_problem_id = ? and revision = ?", rawProblemId, to).First(&toRevision).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.DiffRawProblemRevisions", err)
+	}
+	return DiffRevisions(&fromRevision, &toRevision), nil
+}
+
+//追加一个修订 版本号接在该题已有的最大版本号之后
+func addRevision(tx *gorm.DB, rawProblem *model.RawProblem) error {
+	var last model.RawProblemRevision
+	err := tx.Where("raw_problem_id = ?", rawProblem.ID).Order("revision desc").First(&last).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	revision := NewRawProblemRevision(rawProblem)
+	revision.Revision = last.Revision + 1
+	return tx.Create(revision).Error
+}
+
+//incoming中非零且与stored不同的普通列 与gorm的Update(struct)更新的列一致
+func changedColumns(db *gorm.DB, stored *model.RawProblem, incoming *model.RawProblem) map[string]interface{} {
+	current := db.NewScope(stored)
+	changes := make(map[string]interface{})
+	for _, field := range db.NewScope(incoming).Fields() {
+		if field.IsBlank || field.IsIgnored || !field.IsNormal || field.IsPrimaryKey {
+			continue
+		}
+		switch field.DBName {
+		case "created_at", "updated_at", "deleted_at", "content_hash":
+			continue
+		}
+		if old, ok := current.FieldByName(field.Name); ok && reflect.DeepEqual(old.Field.Interface(), field.Field.Interface()) {
+			continue
+		}
+		changes[field.DBName] = field.Field.Interface()
+	}
+	return changes
+}
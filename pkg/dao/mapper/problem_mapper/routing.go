@@ -0,0 +1,110 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"time"
+)
+
+const (
+	//连续失败达到该次数的远程oj视为不可用
+	RemoteFailureThreshold = 3
+	//不可用的远程oj在最近一次失败后经过该时长重新参与尝试
+	RemoteRetryAfter = 10 * time.Minute
+)
+
+//远程oj当前是否可用
+func RemoteAvailable(group *model.ProblemGroup, now time.Time) bool {
+	return group.ConsecutiveFailures < RemoteFailureThreshold ||
+		group.LastFailureAt == nil || now.Sub(*group.LastFailureAt) >= RemoteRetryAfter
+}
+
+//按尝试顺序原地排序组内的原始题目:
+//可用的在前 依次按连续失败次数升序、主题目优先、成功率降序(没有记录时视为1)
+//不可用的在后 最早失败的在前
+func RankRemoteTargets(groups []*model.ProblemGroup, now time.Time) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		availableA, availableB := RemoteAvailable(a, now), RemoteAvailable(b, now)
+		if availableA != availableB {
+			return availableA
+		}
+		if !availableA {
+			return a.LastFailureAt.Before(*b.LastFailureAt)
+		}
+		if a.ConsecutiveFailures != b.ConsecutiveFailures {
+			return a.ConsecutiveFailures < b.ConsecutiveFailures
+		}
+		if a.MainProblem != b.MainProblem {
+			return a.MainProblem
+		}
+		if ratioA, ratioB := successRatio(a), successRatio(b); ratioA != ratioB {
+			return ratioA > ratioB
+		}
+		return a.RawProblemId < b.RawProblemId
+	})
+}
+
+func successRatio(group *model.ProblemGroup) float64 {
+	total := group.RemoteSuccess + group.RemoteFailure
+	if total == 0 {
+		return 1
+	}
+	return float64(group.RemoteSuccess) / float64(total)
+}
+
+//按提交的题目与RemoteOJ记录一次远程判题结果
+//success表示远程oj正常完成了判题 与提交是否通过无关
+func (p *ProblemMapperImpl) RecordRemoteResult(submissionId uint, success bool) error {
+	if submissionId <= 0 {
+		return errors.InvalidArgument("problem_mapper.RecordRemoteResult", "submission id is incorrect")
+	}
+	var submission model.Submission
+	if err := p.DB.Select("problem_id, remote_oj").Where("id = ?", submissionId).First(&submission).Error; err != nil {
+		return errors.Wrap("problem_mapper.RecordRemoteResult", err)
+	}
+	var problem model.Problem
+	if err := p.DB.Select("group_id").Where("id = ?", submission.ProblemId).First(&problem).Error; err != nil {
+		return errors.Wrap("problem_mapper.RecordRemoteResult", err)
+	}
+	now := time.Now()
+	columns := map[string]interface{}{
+		"remote_failure":       gorm.Expr("remote_failure + ?", 1),
+		"consecutive_failures": gorm.Expr("consecutive_failures + ?", 1),
+		"last_failure_at":      now,
+	}
+	if success {
+		columns = map[string]interface{}{
+			"remote_success":       gorm.Expr("remote_success + ?", 1),
+			"consecutive_failures": 0,
+			"last_success_at":      now,
+		}
+	}
+	//健康度不属于题目内容 不更新updated_at
+	result := p.DB.
+		Model(&model.ProblemGroup{}).
+		Where("group_id = ? and remote_oj = ?", problem.GroupId, submission.RemoteOJ).
+		UpdateColumns(columns)
+	if result.Error != nil {
+		return errors.Wrap("problem_mapper.RecordRemoteResult", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("problem_mapper.RecordRemoteResult", "problem %v has no raw problem on remote oj %v", submission.ProblemId, submission.RemoteOJ)
+	}
+	return nil
+}
+
+//返回题目所在组的全部原始题目 按RankRemoteTargets的顺序排列 调度方依次尝试
+func (p *ProblemMapperImpl) FindRemoteTargets(problemId uint) ([]*model.ProblemGroup, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.FindRemoteTargets", "problem id is incorrect")
+	}
+	groups, err := p.FindGroupProblemsById(problemId)
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.FindRemoteTargets", err)
+	}
+	RankRemoteTargets(groups, time.Now())
+	return groups, nil
+}
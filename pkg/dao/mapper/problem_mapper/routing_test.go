@@ -0,0 +1,32 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"testing"
+	"time"
+)
+
+func TestRankRemoteTargets(t *testing.T) {
+	now := time.Now()
+	recent, earlier, expired := now.Add(-time.Minute), now.Add(-2*time.Minute), now.Add(-RemoteRetryAfter)
+	groups := []*model.ProblemGroup{
+		{RawProblemId: 1, MainProblem: true, ConsecutiveFailures: RemoteFailureThreshold, LastFailureAt: &recent},
+		{RawProblemId: 2, ConsecutiveFailures: RemoteFailureThreshold, LastFailureAt: &earlier},
+		{RawProblemId: 3, RemoteSuccess: 1, RemoteFailure: 1},
+		{RawProblemId: 4},
+		{RawProblemId: 5, ConsecutiveFailures: 1, LastFailureAt: &recent},
+		{RawProblemId: 6, ConsecutiveFailures: RemoteFailureThreshold, LastFailureAt: &expired},
+	}
+	RankRemoteTargets(groups, now)
+	want := []uint{4, 3, 5, 6, 2, 1}
+	for i, group := range groups {
+		if group.RawProblemId != want[i] {
+			t.Fatalf("position %v: want raw problem %v, got %v", i, want[i], group.RawProblemId)
+		}
+	}
+	groups[0].MainProblem, groups[1].MainProblem = false, true
+	RankRemoteTargets(groups, now)
+	if groups[0].RawProblemId != 3 {
+		t.Fatalf("main problem should come first among equally healthy targets, got %v", groups[0].RawProblemId)
+	}
+}
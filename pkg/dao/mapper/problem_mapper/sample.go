@@ -0,0 +1,122 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+const (
+	//爬虫在SampleInput、SampleOutput中用独占一行的标记分隔多组样例
+	SampleBoundary = "##sample##"
+	//样例输出中该标记之后的内容为样例解释
+	ExplanationBoundary = "##explanation##"
+)
+
+//按SampleBoundary把样例文本拆成多组 没有标记或输入输出组数不一致时返回nil
+func SplitSamples(sampleInput string, sampleOutput string) []*model.ProblemSample {
+	if !strings.Contains(sampleInput, SampleBoundary) && !strings.Contains(sampleOutput, SampleBoundary) {
+		return nil
+	}
+	inputs := splitOnBoundary(sampleInput, SampleBoundary)
+	outputs := splitOnBoundary(sampleOutput, SampleBoundary)
+	if len(inputs) != len(outputs) {
+		return nil
+	}
+	samples := make([]*model.ProblemSample, len(inputs))
+	for i := range inputs {
+		sample := &model.ProblemSample{SampleOrder: int32(i + 1), Input: inputs[i], Output: outputs[i]}
+		if parts := splitOnBoundary(outputs[i], ExplanationBoundary); len(parts) == 2 {
+			sample.Output, sample.Explanation = parts[0], parts[1]
+		}
+		samples[i] = sample
+	}
+	return samples
+}
+
+//以独占一行的boundary切分 去掉每段首尾的换行 开头的空段忽略
+func splitOnBoundary(text string, boundary string) []string {
+	parts := make([]string, 0)
+	current := make([]string, 0)
+	flush := func() {
+		parts = append(parts, strings.Trim(strings.Join(current, "\n"), "\r\n"))
+		current = current[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == boundary {
+			if len(parts) > 0 || len(current) > 0 {
+				flush()
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return parts
+}
+
+//题目没有单独保存的样例时 由原始题目的样例文本得到
+//文本带有分隔标记时拆分为多组 否则整段作为一组
+func BlobSamples(rawProblem *model.RawProblem) []*model.ProblemSample {
+	samples := SplitSamples(rawProblem.SampleInput, rawProblem.SampleOutput)
+	if samples == nil {
+		samples = make([]*model.ProblemSample, 0, 1)
+		if rawProblem.SampleInput != "" || rawProblem.SampleOutput != "" {
+			samples = append(samples, &model.ProblemSample{SampleOrder: 1, Input: rawProblem.SampleInput, Output: rawProblem.SampleOutput})
+		}
+	}
+	for _, sample := range samples {
+		sample.RawProblemId = rawProblem.ID
+	}
+	return samples
+}
+
+//用samples整组替换原始题目的样例 按切片顺序重新编号
+func (p *ProblemMapperImpl) ReplaceProblemSamples(rawProblemId uint, samples []*model.ProblemSample) ([]*model.ProblemSample, error) {
+	if rawProblemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.ReplaceProblemSamples", "raw problem id is incorrect")
+	}
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.RawProblem{}, rawProblemId).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("raw_problem_id = ?", rawProblemId).Delete(&model.ProblemSample{}).Error; err != nil {
+			return err
+		}
+		for i, sample := range samples {
+			sample.ID = 0
+			sample.RawProblemId = rawProblemId
+			sample.SampleOrder = int32(i + 1)
+			if err := tx.Create(sample).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.ReplaceProblemSamples", err)
+	}
+	return samples, nil
+}
+
+//按SampleOrder返回样例 没有单独保存的样例时见BlobSamples
+func (p *ProblemMapperImpl) FindProblemSamples(rawProblemId uint) ([]*model.ProblemSample, error) {
+	var samples []*model.ProblemSample
+	result := p.DB.
+		Where("raw_problem_id = ?", rawProblemId).
+		Order("sample_order").
+		Find(&samples)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemSamples", result.Error)
+	}
+	if len(samples) > 0 {
+		return samples, nil
+	}
+	rawProblem := &model.RawProblem{}
+	if err := p.DB.First(rawProblem, rawProblemId).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemSamples", err)
+	}
+	return BlobSamples(rawProblem), nil
+}
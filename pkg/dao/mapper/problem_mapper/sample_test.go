@@ -0,0 +1,25 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"testing"
+)
+
+func TestSplitSamples(t *testing.T) {
+	input := "##sample##\n1 2\n##sample##\n3 4\n"
+	output := "3\n##explanation##\n1 + 2 = 3\n##sample##\n7"
+	samples := SplitSamples(input, output)
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %+v", samples)
+	}
+	if *samples[0] != (model.ProblemSample{SampleOrder: 1, Input: "1 2", Output: "3", Explanation: "1 + 2 = 3"}) ||
+		*samples[1] != (model.ProblemSample{SampleOrder: 2, Input: "3 4", Output: "7"}) {
+		t.Fatalf("unexpected samples: %+v %+v", samples[0], samples[1])
+	}
+	if SplitSamples("1 2", "3") != nil {
+		t.Fatal("text without boundary should not be split")
+	}
+	if SplitSamples("1\n##sample##\n2", "1") != nil {
+		t.Fatal("mismatched sample counts should not be split")
+	}
+}
@@ -0,0 +1,289 @@
+package problem_mapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/similarity"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+)
+
+const (
+	statementWeight = 0.75
+	sampleWeight    = 0.25
+	//SuggestGroupMerges的minScore为0时使用
+	DefaultMinScore = 0.6
+)
+
+//由标题、描述与样例生成指纹及其LSH桶
+func Fingerprint(rawProblem *model.RawProblem) (*model.ProblemFingerprint, []*model.ProblemFingerprintBand) {
+	contentHash := rawProblem.ContentHash
+	if contentHash == "" {
+		contentHash = StatementHash(rawProblem)
+	}
+	signature := similarity.MinHash(rawProblem.Title + "\n" + rawProblem.Description)
+	fingerprint := &model.ProblemFingerprint{
+		RawProblemId: rawProblem.ID,
+		ContentHash:  contentHash,
+		Signature:    signature.Encode(),
+	}
+	sampleInput, sampleOutput := similarity.Normalize(rawProblem.SampleInput), similarity.Normalize(rawProblem.SampleOutput)
+	if sampleInput != "" || sampleOutput != "" {
+		sum := sha256.Sum256([]byte(sampleInput + "\x00" + sampleOutput))
+		fingerprint.SampleHash = hex.EncodeToString(sum[:])
+	}
+	bands := make([]*model.ProblemFingerprintBand, 0, similarity.Bands)
+	for band, bucket := range signature.Buckets() {
+		bands = append(bands, &model.ProblemFingerprintBand{RawProblemId: rawProblem.ID, Band: int32(band), Bucket: bucket})
+	}
+	return fingerprint, bands
+}
+
+//题面相似度与样例是否相同的加权 任一方没有样例时只看题面
+func MatchScore(a *model.ProblemFingerprint, b *model.ProblemFingerprint) float64 {
+	sa, _ := similarity.Decode(a.Signature)
+	sb, _ := similarity.Decode(b.Signature)
+	statement := sa.Similarity(sb)
+	if a.SampleHash == "" || b.SampleHash == "" {
+		return statement
+	}
+	samples := 0.0
+	if a.SampleHash == b.SampleHash {
+		samples = 1
+	}
+	return statementWeight*statement + sampleWeight*samples
+}
+
+//subject能否并入candidate所在的组: 来自不同的oj 且尚不在同一组
+func MergeCandidate(subject *model.RawProblem, subjectGroup *model.ProblemGroup, candidate *model.RawProblem, candidateGroup *model.ProblemGroup) bool {
+	if candidate == nil || candidateGroup == nil || candidate.RemoteOJ == subject.RemoteOJ {
+		return false
+	}
+	return subjectGroup == nil || subjectGroup.GroupId != candidateGroup.GroupId
+}
+
+//题面变化时追加修订并更新指纹
+func recordStatement(tx *gorm.DB, rawProblem *model.RawProblem) error {
+	if err := addRevision(tx, rawProblem); err != nil {
+		return err
+	}
+	_, err := saveFingerprint(tx, rawProblem)
+	return err
+}
+
+//ContentHash未变化时直接返回已保存的指纹
+func saveFingerprint(tx *gorm.DB, rawProblem *model.RawProblem) (*model.ProblemFingerprint, error) {
+	fingerprint, bands := Fingerprint(rawProblem)
+	var stored model.ProblemFingerprint
+	err := tx.Where("raw_problem_id = ?", rawProblem.ID).First(&stored).Error
+	if err == nil && stored.ContentHash == fingerprint.ContentHash {
+		return &stored, nil
+	}
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	if err = tx.Where("raw_problem_id = ?", rawProblem.ID).Delete(&model.ProblemFingerprint{}).Error; err != nil {
+		return nil, err
+	}
+	if err = tx.Create(fingerprint).Error; err != nil {
+		return nil, err
+	}
+	if err = tx.Where("raw_problem_id = ?", rawProblem.ID).Delete(&model.ProblemFingerprintBand{}).Error; err != nil {
+		return nil, err
+	}
+	for _, band := range bands {
+		if err = tx.Create(band).Error; err != nil {
+			return nil, err
+		}
+	}
+	return fingerprint, nil
+}
+
+//为原始题目寻找其他oj上的重复题目 得分不低于minScore的记为待处理建议 按得分降序返回
+//已被接受或拒绝的题目对不会再次建议
+func (p *ProblemMapperImpl) SuggestGroupMerges(rawProblemId uint, minScore float64) ([]*model.GroupSuggestion, error) {
+	if minScore <= 0 {
+		minScore = DefaultMinScore
+	}
+	suggestions := make([]*model.GroupSuggestion, 0)
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		subject := &model.RawProblem{}
+		if err := tx.First(subject, rawProblemId).Error; err != nil {
+			return err
+		}
+		fingerprint, err := saveFingerprint(tx, subject)
+		if err != nil {
+			return err
+		}
+		signature, _ := similarity.Decode(fingerprint.Signature)
+		conditions := make([]string, 0, similarity.Bands)
+		args := make([]interface{}, 0, 2*similarity.Bands)
+		for band, bucket := range signature.Buckets() {
+			conditions = append(conditions, "(band = ? and bucket = ?)")
+			args = append(args, band, bucket)
+		}
+		if len(conditions) == 0 {
+			return nil
+		}
+		var candidateIds []uint
+		err = tx.Model(&model.ProblemFingerprintBand{}).
+			Where("raw_problem_id <> ?", rawProblemId).
+			Where(strings.Join(conditions, " or "), args...).
+			Pluck("distinct raw_problem_id", &candidateIds).Error
+		if err != nil || len(candidateIds) == 0 {
+			return err
+		}
+		var candidates []*model.RawProblem
+		var fingerprints []*model.ProblemFingerprint
+		var groups []*model.ProblemGroup
+		if err = tx.Find(&candidates, candidateIds).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("raw_problem_id in (?)", candidateIds).Find(&fingerprints).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("raw_problem_id in (?)", append(candidateIds, rawProblemId)).Find(&groups).Error; err != nil {
+			return err
+		}
+		groupOf := make(map[uint]*model.ProblemGroup, len(groups))
+		for _, group := range groups {
+			groupOf[group.RawProblemId] = group
+		}
+		fingerprintOf := make(map[uint]*model.ProblemFingerprint, len(fingerprints))
+		for _, f := range fingerprints {
+			fingerprintOf[f.RawProblemId] = f
+		}
+		for _, candidate := range candidates {
+			if !MergeCandidate(subject, groupOf[rawProblemId], candidate, groupOf[candidate.ID]) || fingerprintOf[candidate.ID] == nil {
+				continue
+			}
+			score := MatchScore(fingerprint, fingerprintOf[candidate.ID])
+			if score < minScore {
+				continue
+			}
+			suggestion, err := upsertSuggestion(tx, rawProblemId, candidate.ID, score)
+			if err != nil {
+				return err
+			}
+			if suggestion != nil {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.SuggestGroupMerges", err)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Score > suggestions[j].Score })
+	return suggestions, nil
+}
+
+//两个方向上已有的建议都算作同一对 待处理的更新得分 已处理的返回nil
+func upsertSuggestion(tx *gorm.DB, rawProblemId uint, targetRawProblemId uint, score float64) (*model.GroupSuggestion, error) {
+	suggestion := &model.GroupSuggestion{}
+	err := tx.
+		Where("(raw_problem_id = ? and target_raw_problem_id = ?) or (raw_problem_id = ? and target_raw_problem_id = ?)",
+			rawProblemId, targetRawProblemId, targetRawProblemId, rawProblemId).
+		First(suggestion).Error
+	if gorm.IsRecordNotFoundError(err) {
+		suggestion = &model.GroupSuggestion{
+			RawProblemId:       rawProblemId,
+			TargetRawProblemId: targetRawProblemId,
+			Score:              score,
+			Status:             model.SuggestionPending,
+		}
+		return suggestion, tx.Create(suggestion).Error
+	}
+	if err != nil || suggestion.Status != model.SuggestionPending {
+		return nil, err
+	}
+	suggestion.Score = score
+	return suggestion, tx.Model(suggestion).Update("score", score).Error
+}
+
+//按得分降序分页返回某一状态的建议
+func (p *ProblemMapperImpl) FindGroupSuggestions(status int32, pageNo int32, pageSize int32) ([]*model.GroupSuggestion, int32, error) {
+	limit, offset := util.CalLimitOffset(pageNo, pageSize)
+	var count int32
+	var suggestions []*model.GroupSuggestion
+	result := p.DB.
+		Model(&model.GroupSuggestion{}).
+		Where("status = ?", status).
+		Count(&count).
+		Order("score desc").
+		Order("id").
+		Limit(limit).
+		Offset(offset).
+		Find(&suggestions)
+	if result.Error != nil {
+		return nil, 0, errors.Wrap("problem_mapper.FindGroupSuggestions", result.Error)
+	}
+	return suggestions, count, nil
+}
+
+//把建议中的原始题目所在的组整体并入目标所在的组 见MergeProblemGroups 只能处理待处理的建议
+//原始题目还没有组时经UpdateProblemGroup加入目标所在的组
+func (p *ProblemMapperImpl) AcceptGroupSuggestion(suggestionId uint) error {
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		suggestion, err := pendingSuggestion(tx, "problem_mapper.AcceptGroupSuggestion", suggestionId)
+		if err != nil {
+			return err
+		}
+		var target model.ProblemGroup
+		if err = tx.Where("raw_problem_id = ?", suggestion.TargetRawProblemId).First(&target).Error; err != nil {
+			return err
+		}
+		var subject model.ProblemGroup
+		err = tx.Where("raw_problem_id = ?", suggestion.RawProblemId).First(&subject).Error
+		switch {
+		case gorm.IsRecordNotFoundError(err):
+			rawProblem := &model.RawProblem{}
+			if err = tx.First(rawProblem, suggestion.RawProblemId).Error; err != nil {
+				return err
+			}
+			subject = model.ProblemGroup{
+				RawProblemId:    rawProblem.ID,
+				GroupId:         target.GroupId,
+				RemoteOJ:        rawProblem.RemoteOJ,
+				RemoteProblemId: rawProblem.RemoteProblemId,
+			}
+			if err = tx.Create(&subject).Error; err != nil {
+				return err
+			}
+			err = NewMapper(tx).UpdateProblemGroup(suggestion.RawProblemId, target.GroupId)
+		case err == nil && subject.GroupId != target.GroupId:
+			_, err = mergeProblemGroups(tx, target.GroupId, subject.GroupId)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(suggestion).Update("status", model.SuggestionAccepted).Error
+	})
+	return errors.Wrap("problem_mapper.AcceptGroupSuggestion", err)
+}
+
+func (p *ProblemMapperImpl) RejectGroupSuggestion(suggestionId uint) error {
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		suggestion, err := pendingSuggestion(tx, "problem_mapper.RejectGroupSuggestion", suggestionId)
+		if err != nil {
+			return err
+		}
+		return tx.Model(suggestion).Update("status", model.SuggestionRejected).Error
+	})
+	return errors.Wrap("problem_mapper.RejectGroupSuggestion", err)
+}
+
+func pendingSuggestion(tx *gorm.DB, op string, suggestionId uint) (*model.GroupSuggestion, error) {
+	suggestion := &model.GroupSuggestion{}
+	if err := tx.First(suggestion, suggestionId).Error; err != nil {
+		return nil, err
+	}
+	if suggestion.Status != model.SuggestionPending {
+		return nil, errors.Conflict(op, "suggestion %v has already been handled", suggestionId)
+	}
+	return suggestion, nil
+}
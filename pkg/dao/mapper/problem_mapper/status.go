@@ -0,0 +1,68 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/jinzhu/gorm"
+)
+
+//每个状态允许转换到的状态 草稿发布后不能回到草稿 退役的题目只能先恢复为隐藏
+var statusTransitions = map[model.ProblemStatus][]model.ProblemStatus{
+	model.ProblemDraft:       {model.ProblemPublic, model.ProblemHidden, model.ProblemContestOnly},
+	model.ProblemPublic:      {model.ProblemHidden, model.ProblemContestOnly, model.ProblemRetired},
+	model.ProblemHidden:      {model.ProblemPublic, model.ProblemContestOnly, model.ProblemRetired},
+	model.ProblemContestOnly: {model.ProblemPublic, model.ProblemHidden, model.ProblemRetired},
+	model.ProblemRetired:     {model.ProblemHidden},
+}
+
+//状态不变视为合法
+func CanTransition(from model.ProblemStatus, to model.ProblemStatus) bool {
+	if from == to {
+		return from.Valid()
+	}
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+//默认只有公开的题目出现在列表、搜索与随机选题中 allStatuses为true时不过滤(管理员视图)
+func visible(db *gorm.DB, allStatuses bool) *gorm.DB {
+	if allStatuses {
+		return db
+	}
+	return db.Where("problems.status = ?", model.ProblemPublic)
+}
+
+//修改题目状态 转换不合法时返回errors.ErrConflict
+func (p *ProblemMapperImpl) UpdateProblemStatus(problemId uint, status model.ProblemStatus) (*model.Problem, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.UpdateProblemStatus", "problem id is incorrect")
+	}
+	if !status.Valid() {
+		return nil, errors.InvalidArgument("problem_mapper.UpdateProblemStatus", "unknown problem status %v", int32(status))
+	}
+	var problem model.Problem
+	if err := p.DB.Select("id, status").Where("id = ?", problemId).First(&problem).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.UpdateProblemStatus", err)
+	}
+	if !CanTransition(problem.Status, status) {
+		return nil, errors.Conflict("problem_mapper.UpdateProblemStatus", "problem %v cannot change from %v to %v", problemId, problem.Status, status)
+	}
+	if problem.Status != status {
+		//带上原状态 并发修改时只有一个成功
+		result := p.DB.
+			Model(&model.Problem{}).
+			Where("id = ? and status = ?", problemId, problem.Status).
+			Update("status", status)
+		if result.Error != nil {
+			return nil, errors.Wrap("problem_mapper.UpdateProblemStatus", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, errors.Conflict("problem_mapper.UpdateProblemStatus", "problem %v status was changed concurrently", problemId)
+		}
+	}
+	return p.FindProblemById(problemId)
+}
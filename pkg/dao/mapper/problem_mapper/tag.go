@@ -0,0 +1,159 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"sync"
+)
+
+type TagCount struct {
+	Name  string
+	Count int32
+}
+
+//把RawProblem.RemoteTags解析为标签名
+type TagParser func(remoteTags string) []string
+
+var (
+	tagParsersMu sync.RWMutex
+	tagParsers   = map[remote_oj.RemoteOJ]TagParser{}
+)
+
+//为远程oj注册标签解析器 未注册的oj按逗号分隔解析 parser为nil时取消注册
+func RegisterTagParser(oj remote_oj.RemoteOJ, parser TagParser) {
+	tagParsersMu.Lock()
+	defer tagParsersMu.Unlock()
+	if parser == nil {
+		delete(tagParsers, oj)
+		return
+	}
+	tagParsers[oj] = parser
+}
+
+//解析原始题目携带的远程标签 返回规范化后的标签名
+func ParseRemoteTags(rawProblem *model.RawProblem) []string {
+	if rawProblem == nil || rawProblem.RemoteTags == "" {
+		return nil
+	}
+	tagParsersMu.RLock()
+	parser, ok := tagParsers[rawProblem.RemoteOJ]
+	tagParsersMu.RUnlock()
+	if !ok {
+		parser = splitTags
+	}
+	return NormalizeTags(parser(rawProblem.RemoteTags))
+}
+
+func splitTags(remoteTags string) []string {
+	return strings.FieldsFunc(remoteTags, func(r rune) bool {
+		return r == ',' || r == ';' || r == '，'
+	})
+}
+
+//标签名统一为去掉首尾空白的小写 并去重、去空
+func NormalizeTags(names []string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+//为题目添加标签 不存在的标签会被创建 已有的关联保持不变
+func (p *ProblemMapperImpl) AttachProblemTags(problemId uint, names []string) ([]*model.Tag, error) {
+	if problemId <= 0 {
+		return nil, errors.InvalidArgument("problem_mapper.AttachProblemTags", "problem id is incorrect")
+	}
+	names = NormalizeTags(names)
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		for _, name := range names {
+			tag := &model.Tag{}
+			if err := tx.Where(model.Tag{Name: name}).FirstOrCreate(tag).Error; err != nil {
+				return err
+			}
+			var count int
+			if err := tx.Model(&model.ProblemTag{}).Where("problem_id = ? and tag_id = ?", problemId, tag.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&model.ProblemTag{ProblemId: problemId, TagId: tag.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.AttachProblemTags", err)
+	}
+	return p.FindProblemTags(problemId)
+}
+
+func (p *ProblemMapperImpl) DetachProblemTags(problemId uint, names []string) error {
+	names = NormalizeTags(names)
+	if len(names) == 0 {
+		return nil
+	}
+	result := p.DB.
+		Where("problem_id = ? and tag_id in ?", problemId, p.DB.Model(&model.Tag{}).Select("id").Where("name in (?)", names).SubQuery()).
+		Delete(&model.ProblemTag{})
+	return errors.Wrap("problem_mapper.DetachProblemTags", result.Error)
+}
+
+//按标签名排序
+func (p *ProblemMapperImpl) FindProblemTags(problemId uint) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	result := p.DB.
+		Joins("join problem_tags on problem_tags.tag_id = tags.id").
+		Where("problem_tags.problem_id = ?", problemId).
+		Order("tags.name").
+		Find(&tags)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.FindProblemTags", result.Error)
+	}
+	return tags, nil
+}
+
+//统计每个标签下未删除题目的数量 按数量降序、标签名升序 没有题目的标签不返回
+//与FindAllProblems一样默认只统计公开的题目 options的过滤条件同样生效
+func (p *ProblemMapperImpl) CountTags(options ...*ProblemListOption) ([]*TagCount, error) {
+	var counts []*TagCount
+	query := p.DB.
+		Table("tags").
+		Select("tags.name, count(problems.id) as count").
+		Joins("join problem_tags on problem_tags.tag_id = tags.id").
+		Joins("join problems on problems.id = problem_tags.problem_id and problems.deleted_at is null").
+		Where("tags.deleted_at is null")
+	result := listFilter(query, options).
+		Group("tags.name").
+		Order("count desc, tags.name").
+		Scan(&counts)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.CountTags", result.Error)
+	}
+	return counts, nil
+}
+
+//按题目所属RemoteOJ的解析器导入原始题目携带的远程标签
+func (p *ProblemMapperImpl) ImportRemoteTags(problemId uint) ([]*model.Tag, error) {
+	problem, err := p.FindProblemById(problemId)
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.ImportRemoteTags", err)
+	}
+	tags, err := p.AttachProblemTags(problem.ID, ParseRemoteTags(problem.RawProblem))
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.ImportRemoteTags", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,23 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"reflect"
+	"testing"
+)
+
+func TestParseRemoteTags(t *testing.T) {
+	raw := &model.RawProblem{RemoteOJ: remote_oj.HDU, RemoteTags: " DP, graphs;，dp "}
+	if tags := ParseRemoteTags(raw); !reflect.DeepEqual(tags, []string{"dp", "graphs"}) {
+		t.Fatalf("unexpected default parse: %v", tags)
+	}
+	RegisterTagParser(remote_oj.HDU, func(remoteTags string) []string {
+		return []string{"hdu-" + remoteTags}
+	})
+	defer RegisterTagParser(remote_oj.HDU, nil)
+	raw.RemoteTags = "Math"
+	if tags := ParseRemoteTags(raw); !reflect.DeepEqual(tags, []string{"hdu-math"}) {
+		t.Fatalf("registered parser not used: %v", tags)
+	}
+}
@@ -0,0 +1,153 @@
+package problem_mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+//统一为小写并以-分隔 如 zh_CN -> zh-cn
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+//依次尝试的翻译 如 zh-cn 之后是 zh 都没有时使用原文
+func LocaleCandidates(locale string) []string {
+	locale = NormalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	return candidates
+}
+
+//从translations中按LocaleCandidates的顺序选出最合适的翻译 没有时返回nil
+func PickTranslation(translations []*model.RawProblemTranslation, locale string) *model.RawProblemTranslation {
+	for _, candidate := range LocaleCandidates(locale) {
+		for _, translation := range translations {
+			if translation.Locale == candidate {
+				return translation
+			}
+		}
+	}
+	return nil
+}
+
+//用翻译中非空的字段覆盖原始题目 translation为nil时保持原文
+func ApplyTranslation(rawProblem *model.RawProblem, translation *model.RawProblemTranslation) {
+	if rawProblem == nil || translation == nil {
+		return
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&rawProblem.Title, translation.Title},
+		{&rawProblem.Description, translation.Description},
+		{&rawProblem.Input, translation.Input},
+		{&rawProblem.Output, translation.Output},
+		{&rawProblem.Hint, translation.Hint},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	rawProblem.Locale = translation.Locale
+}
+
+//按raw_problem_id与locale新增或更新翻译
+func (p *ProblemMapperImpl) AddOrModifyTranslation(translation *model.RawProblemTranslation) (*model.RawProblemTranslation, error) {
+	translation.Locale = NormalizeLocale(translation.Locale)
+	if translation.RawProblemId <= 0 || translation.Locale == "" {
+		return nil, errors.InvalidArgument("problem_mapper.AddOrModifyTranslation", "raw problem id and locale are required")
+	}
+	err := util.Transaction(p.DB, func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.RawProblem{}, translation.RawProblemId).Error; err != nil {
+			return err
+		}
+		var stored model.RawProblemTranslation
+		err := tx.Unscoped().
+			Where("raw_problem_id = ? and locale = ?", translation.RawProblemId, translation.Locale).
+			First(&stored).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return tx.Create(translation).Error
+		}
+		if err != nil {
+			return err
+		}
+		translation.ID, translation.CreatedAt, translation.DeletedAt = stored.ID, stored.CreatedAt, nil
+		return tx.Unscoped().Save(translation).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap("problem_mapper.AddOrModifyTranslation", err)
+	}
+	return translation, nil
+}
+
+func (p *ProblemMapperImpl) FindRawProblemTranslations(rawProblemId uint) ([]*model.RawProblemTranslation, error) {
+	var translations []*model.RawProblemTranslation
+	result := p.DB.
+		Where("raw_problem_id = ?", rawProblemId).
+		Order("locale").
+		Find(&translations)
+	if result.Error != nil {
+		return nil, errors.Wrap("problem_mapper.FindRawProblemTranslations", result.Error)
+	}
+	return translations, nil
+}
+
+//返回locale对应语言的原始题目 没有该语言的翻译时回退到原文 RawProblem.Locale给出实际使用的翻译
+func (p *ProblemMapperImpl) FindLocalizedRawProblem(rawProblemId uint, locale string) (*model.RawProblem, error) {
+	rawProblem := &model.RawProblem{}
+	if err := p.DB.First(rawProblem, rawProblemId).Error; err != nil {
+		return nil, errors.Wrap("problem_mapper.FindLocalizedRawProblem", err)
+	}
+	if err := p.localize([]*model.RawProblem{rawProblem}, locale); err != nil {
+		return nil, errors.Wrap("problem_mapper.FindLocalizedRawProblem", err)
+	}
+	return rawProblem, nil
+}
+
+//批量为原始题目套用翻译 locale为空时不查询
+func (p *ProblemMapperImpl) localize(rawProblems []*model.RawProblem, locale string) error {
+	candidates := LocaleCandidates(locale)
+	if len(candidates) == 0 || len(rawProblems) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(rawProblems))
+	for _, rawProblem := range rawProblems {
+		if rawProblem != nil {
+			ids = append(ids, rawProblem.ID)
+		}
+	}
+	var translations []*model.RawProblemTranslation
+	result := p.DB.
+		Where("raw_problem_id in (?) and locale in (?)", ids, candidates).
+		Find(&translations)
+	if result.Error != nil {
+		return result.Error
+	}
+	byRawProblem := make(map[uint][]*model.RawProblemTranslation)
+	for _, translation := range translations {
+		byRawProblem[translation.RawProblemId] = append(byRawProblem[translation.RawProblemId], translation)
+	}
+	for _, rawProblem := range rawProblems {
+		if rawProblem != nil {
+			ApplyTranslation(rawProblem, PickTranslation(byRawProblem[rawProblem.ID], locale))
+		}
+	}
+	return nil
+}
+
+//locale为可选参数 取第一个
+func firstLocale(locale []string) string {
+	if len(locale) == 0 {
+		return ""
+	}
+	return locale[0]
+}
@@ -0,0 +1,1030 @@
+package mapper
+
+import (
+	"context"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/blobstore"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/difficulty"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProblemMapperImpl_AddOrModifyRawProblem(t *testing.T) {
+	store, f := setup(t)
+	raw, err := store.ProblemMapper().AddOrModifyRawProblem(&model.RawProblem{
+		Title:           "A + B Problem (updated)",
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	if raw.ID != f.RawProblems[0].ID || raw.Title != "A + B Problem (updated)" || raw.Description != f.RawProblems[0].Description {
+		t.Fatalf("existing raw problem should be updated in place: %+v", raw)
+	}
+	raw, err = store.ProblemMapper().AddOrModifyRawProblem(&model.RawProblem{
+		Title:           "New Problem",
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "2000",
+	})
+	assertNoError(t, err)
+	if raw.ID == 0 {
+		t.Fatal("new raw problem not created")
+	}
+}
+
+func TestProblemMapperImpl_RawProblemRevisions(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	raw := f.RawProblems[0]
+	unchanged, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Title:           raw.Title,
+		Description:     raw.Description,
+		RemoteOJ:        raw.RemoteOJ,
+		RemoteProblemId: raw.RemoteProblemId,
+	})
+	assertNoError(t, err)
+	if !unchanged.UpdatedAt.Equal(raw.UpdatedAt) || unchanged.ContentHash != raw.ContentHash {
+		t.Fatalf("unchanged statement should not be written: %+v", unchanged)
+	}
+	revisions, err := mapper.FindRawProblemRevisions(raw.ID)
+	assertNoError(t, err)
+	if len(revisions) != 1 || revisions[0].Revision != 1 || revisions[0].ContentHash != raw.ContentHash {
+		t.Fatalf("expected the initial revision only, got %+v", revisions)
+	}
+	updated, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Description:     "Calculate a + b.\nBoth numbers are positive.",
+		TimeLimit:       "2000MS",
+		RemoteOJ:        raw.RemoteOJ,
+		RemoteProblemId: raw.RemoteProblemId,
+	})
+	assertNoError(t, err)
+	if updated.ContentHash == raw.ContentHash || updated.Title != raw.Title {
+		t.Fatalf("statement change not applied: %+v", updated)
+	}
+	revisions, err = mapper.FindRawProblemRevisions(raw.ID)
+	assertNoError(t, err)
+	if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[0].ContentHash != updated.ContentHash {
+		t.Fatalf("expected a second revision, got %+v", revisions)
+	}
+	diffs, err := mapper.DiffRawProblemRevisions(raw.ID, 1, 2)
+	assertNoError(t, err)
+	if len(diffs) != 2 || diffs[0].Field != "description" || diffs[1].Field != "time_limit" || diffs[1].Old != "1000MS" || diffs[1].New != "2000MS" {
+		t.Fatalf("unexpected diff: %+v", diffs)
+	}
+	if len(diffs[0].Lines) != 3 {
+		t.Fatalf("expected one deleted and two inserted lines, got %+v", diffs[0].Lines)
+	}
+	_, err = mapper.DiffRawProblemRevisions(raw.ID, 1, 3)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing revision, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_ProblemSamples(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	raw := f.RawProblems[0]
+	samples, err := mapper.FindProblemSamples(raw.ID)
+	assertNoError(t, err)
+	if len(samples) != 1 || samples[0].Input != "1 2" || samples[0].Output != "3" {
+		t.Fatalf("expected the sample blob as one sample, got %+v", samples)
+	}
+	_, err = mapper.ReplaceProblemSamples(raw.ID, []*model.ProblemSample{
+		{Input: "1 2", Output: "3"},
+		{Input: "-1 1", Output: "0", Explanation: "negative numbers are allowed"},
+	})
+	assertNoError(t, err)
+	_, err = mapper.ReplaceProblemSamples(raw.ID, []*model.ProblemSample{
+		{Input: "5 5", Output: "10"},
+		{Input: "-1 1", Output: "0", Explanation: "negative numbers are allowed"},
+	})
+	assertNoError(t, err)
+	samples, err = mapper.FindProblemSamples(raw.ID)
+	assertNoError(t, err)
+	if len(samples) != 2 || samples[0].SampleOrder != 1 || samples[0].Input != "5 5" ||
+		samples[1].SampleOrder != 2 || samples[1].Explanation != "negative numbers are allowed" {
+		t.Fatalf("unexpected samples after replace: %+v", samples)
+	}
+	_, err = mapper.ReplaceProblemSamples(9999, []*model.ProblemSample{{Input: "1", Output: "1"}})
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing raw problem, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_ProblemAssets(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	blobs, err := blobstore.NewLocalStore(t.TempDir(), "/assets")
+	assertNoError(t, err)
+	const remoteUrl = "http://acm.hdu.edu.cn/data/images/1000-1.jpg"
+	raw, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Description:     `Calculate a + b. <img src="` + remoteUrl + `">`,
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	asset, err := problem_mapper.UploadProblemAsset(context.Background(), blobs, raw.ID, remoteUrl, "image/jpeg", strings.NewReader("jpeg"))
+	assertNoError(t, err)
+	if !strings.HasPrefix(asset.LocalUrl, "/assets/raw_problems/") || !strings.HasSuffix(asset.LocalUrl, ".jpg") || asset.Size != 4 {
+		t.Fatalf("unexpected uploaded asset: %+v", asset)
+	}
+	_, err = mapper.RegisterProblemAsset(asset)
+	assertNoError(t, err)
+	_, err = mapper.RegisterProblemAsset(asset)
+	assertNoError(t, err)
+	assets, err := mapper.FindProblemAssets(raw.ID)
+	assertNoError(t, err)
+	if len(assets) != 1 || assets[0].StorageKey != asset.StorageKey {
+		t.Fatalf("registering the same source url twice should keep one asset: %+v", assets)
+	}
+	rewritten, err := mapper.RewriteAssetUrls(raw.ID)
+	assertNoError(t, err)
+	if strings.Contains(rewritten.Description, remoteUrl) || !strings.Contains(rewritten.Description, asset.LocalUrl) {
+		t.Fatalf("description not rewritten: %q", rewritten.Description)
+	}
+	//重新抓取到的原地址按已登记的附件改写 不算作变化
+	recrawled, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Description:     `Calculate a + b. <img src="` + remoteUrl + `">`,
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	revisions, err := mapper.FindRawProblemRevisions(raw.ID)
+	assertNoError(t, err)
+	if recrawled.Description != rewritten.Description || len(revisions) != 3 {
+		t.Fatalf("re-crawl should not change the rewritten statement: %q, %v revisions", recrawled.Description, len(revisions))
+	}
+	_, err = mapper.RegisterProblemAsset(&model.ProblemAsset{RawProblemId: f.RawProblems[1].ID, SourceUrl: remoteUrl})
+	if !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument without local url, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_Translations(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	_, err := mapper.AddOrModifyTranslation(&model.RawProblemTranslation{
+		RawProblemId: f.RawProblems[0].ID,
+		Locale:       "zh",
+		Title:        "A + B 问题",
+	})
+	assertNoError(t, err)
+	_, err = mapper.AddOrModifyTranslation(&model.RawProblemTranslation{
+		RawProblemId: f.RawProblems[0].ID,
+		Locale:       "ZH",
+		Title:        "A+B 问题",
+		Description:  "计算 a + b",
+	})
+	assertNoError(t, err)
+	translations, err := mapper.FindRawProblemTranslations(f.RawProblems[0].ID)
+	assertNoError(t, err)
+	if len(translations) != 1 || translations[0].Locale != "zh" || translations[0].Title != "A+B 问题" {
+		t.Fatalf("translation should be updated in place: %+v", translations)
+	}
+	problem, err := mapper.FindProblemById(f.Problems[0].ID, "zh_CN")
+	assertNoError(t, err)
+	if problem.RawProblem.Title != "A+B 问题" || problem.RawProblem.Locale != "zh" || problem.RawProblem.SampleInput != "1 2" {
+		t.Fatalf("expected the zh translation over the original: %+v", problem.RawProblem)
+	}
+	problem, err = mapper.FindProblemById(f.Problems[0].ID)
+	assertNoError(t, err)
+	if problem.RawProblem.Title != "A + B Problem" || problem.RawProblem.Locale != "" {
+		t.Fatalf("expected the original without locale: %+v", problem.RawProblem)
+	}
+	problems, err := mapper.FindProblemsByIds([]uint{f.Problems[0].ID, f.Problems[1].ID}, "zh")
+	assertNoError(t, err)
+	if len(problems) != 2 || problems[0].RawProblem.Title != "A+B 问题" || problems[1].RawProblem.Title != "Sum Problem" {
+		t.Fatalf("expected translation with fallback to the original: %+v %+v", problems[0].RawProblem, problems[1].RawProblem)
+	}
+	raw, err := mapper.FindLocalizedRawProblem(f.RawProblems[1].ID, "en")
+	assertNoError(t, err)
+	if raw.Title != "Sum Problem" || raw.Locale != "" {
+		t.Fatalf("expected the original for a missing translation: %+v", raw)
+	}
+	_, err = mapper.AddOrModifyTranslation(&model.RawProblemTranslation{RawProblemId: f.RawProblems[0].ID})
+	if !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument without locale, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_GroupSuggestions(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	//另一个oj上的同一道题 以及一道不相关的题
+	mirror, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Title:           "A + B Problem",
+		Description:     "<p>Calculate A + B Problem</p>",
+		SampleInput:     "1 2",
+		SampleOutput:    "3",
+		RemoteOJ:        remote_oj.HDU + 1,
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	unrelated, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Title:           "Shortest Path",
+		Description:     "Find the shortest path in a weighted graph.",
+		RemoteOJ:        remote_oj.HDU + 1,
+		RemoteProblemId: "2000",
+	})
+	assertNoError(t, err)
+	suggestions, err := mapper.SuggestGroupMerges(mirror.ID, 0)
+	assertNoError(t, err)
+	if len(suggestions) == 0 || suggestions[0].TargetRawProblemId != f.RawProblems[0].ID || suggestions[0].Score < 0.9 {
+		t.Fatalf("expected HDU 1000 as the best match, got %+v", suggestions)
+	}
+	for _, suggestion := range suggestions {
+		if suggestion.TargetRawProblemId == unrelated.ID {
+			t.Fatalf("unrelated problem suggested: %+v", suggestion)
+		}
+	}
+	again, err := mapper.SuggestGroupMerges(mirror.ID, 0)
+	assertNoError(t, err)
+	pending, count, err := mapper.FindGroupSuggestions(model.SuggestionPending, 1, 10)
+	assertNoError(t, err)
+	if len(again) != len(suggestions) || int(count) != len(suggestions) || pending[0].ID != suggestions[0].ID {
+		t.Fatalf("suggesting twice should not duplicate: %+v %+v", again, pending)
+	}
+	assertNoError(t, mapper.AcceptGroupSuggestion(suggestions[0].ID))
+	groups, err := mapper.FindGroupProblemsById(f.Problems[0].ID)
+	assertNoError(t, err)
+	if len(groups) != 2 || groups[1].RawProblemId != mirror.ID {
+		t.Fatalf("mirror should join the group of HDU 1000: %+v", groups)
+	}
+	if err = mapper.AcceptGroupSuggestion(suggestions[0].ID); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a handled suggestion, got %v", err)
+	}
+	for _, suggestion := range suggestions[1:] {
+		assertNoError(t, mapper.RejectGroupSuggestion(suggestion.ID))
+	}
+	suggestions, err = mapper.SuggestGroupMerges(mirror.ID, 0)
+	assertNoError(t, err)
+	if len(suggestions) != 0 {
+		t.Fatalf("handled pairs should not be suggested again: %+v", suggestions)
+	}
+}
+
+func TestProblemMapperImpl_ProblemCounters(t *testing.T) {
+	store, f := setup(t)
+	problem := f.Problems[0]
+	assertNoError(t, store.ProblemMapper().AddProblemSubmittedCountById(problem.ID))
+	assertNoError(t, store.ProblemMapper().AddProblemSubmittedCountById(problem.ID))
+	assertNoError(t, store.ProblemMapper().AddProblemAcceptedCountById(problem.ID))
+	found, err := store.ProblemMapper().FindProblemById(problem.ID)
+	assertNoError(t, err)
+	if found.Submitted != 2 || found.Accepted != 1 {
+		t.Fatalf("unexpected counters: %+v", found)
+	}
+	if err = store.ProblemMapper().AddProblemSubmittedCountById(0); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if err = store.ProblemMapper().AddProblemAcceptedCountById(0); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_ContestProblemCounters(t *testing.T) {
+	store, f := setup(t)
+	contest, problem := f.Contests[0], f.Problems[0]
+	assertNoError(t, store.ProblemMapper().AddContestProblemSubmittedCountById(contest.ID, problem.ID))
+	assertNoError(t, store.ProblemMapper().AddContestProblemAcceptedCountById(contest.ID, problem.ID))
+	problems, err := store.ContestMapper().FindContestProblems(contest.ID)
+	assertNoError(t, err)
+	for _, p := range problems {
+		if p.ProblemId == problem.ID && (p.Submitted != 1 || p.Accepted != 1) {
+			t.Fatalf("unexpected contest counters: %+v", p)
+		}
+	}
+}
+
+func TestProblemMapperImpl_AddOrModifyProblemGroup(t *testing.T) {
+	store, f := setup(t)
+	group, err := store.ProblemMapper().AddOrModifyProblemGroup(&model.ProblemGroup{
+		RawProblemId:    f.RawProblems[1].ID,
+		GroupId:         f.RawProblems[0].ID,
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1001",
+	})
+	assertNoError(t, err)
+	if group.ID != f.Groups[1].ID || group.GroupId != f.RawProblems[0].ID {
+		t.Fatalf("group should be updated in place: %+v", group)
+	}
+}
+
+func TestProblemMapperImpl_AddOrModifyProblem(t *testing.T) {
+	store, f := setup(t)
+	problem, err := store.ProblemMapper().AddOrModifyProblem(&model.Problem{
+		GroupId:      f.Problems[2].GroupId,
+		RawProblemId: f.RawProblems[3].ID,
+	})
+	assertNoError(t, err)
+	if problem.ID != f.Problems[2].ID || problem.RawProblemId != f.RawProblems[3].ID {
+		t.Fatalf("problem should be updated in place: %+v", problem)
+	}
+}
+
+func TestProblemMapperImpl_UpdateProblemGroupId(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.ProblemMapper().UpdateProblemGroupId(f.RawProblems[1].ID, f.Problems[0].GroupId))
+	groups, err := store.ProblemMapper().FindGroupProblemsById(f.Problems[0].ID)
+	assertNoError(t, err)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 group members, got %+v", groups)
+	}
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == f.RawProblems[0].ID) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+	//原组已空 原组的题目并入新组的题目
+	if _, err = store.ProblemMapper().FindProblemById(f.Problems[1].ID); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("problem of the emptied group should be merged, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_FindGroupProblemsById(t *testing.T) {
+	store, f := setup(t)
+	groups, err := store.ProblemMapper().FindGroupProblemsById(f.Problems[2].ID)
+	assertNoError(t, err)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 group members, got %+v", groups)
+	}
+	_, err = store.ProblemMapper().FindGroupProblemsById(9999)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_FindAllProblems(t *testing.T) {
+	store, f := setup(t)
+	problems, count, err := store.ProblemMapper().FindAllProblems(1, 2, false)
+	assertNoError(t, err)
+	if count != 3 || len(problems) != 2 || problems[0].RawProblem == nil {
+		t.Fatalf("unexpected page: count=%v problems=%+v", count, problems)
+	}
+	//更新后按updated_at倒序排在第一
+	time.Sleep(10 * time.Millisecond)
+	assertNoError(t, store.ProblemMapper().AddProblemSubmittedCountById(f.Problems[1].ID))
+	problems, _, err = store.ProblemMapper().FindAllProblems(1, 1, true)
+	assertNoError(t, err)
+	if len(problems) != 1 || problems[0].ID != f.Problems[1].ID {
+		t.Fatalf("expected most recently updated problem first, got %+v", problems)
+	}
+}
+
+func TestProblemMapperImpl_FindAllProblemsByCursor(t *testing.T) {
+	store, f := setup(t)
+	seen := make(map[uint]bool)
+	cursor := ""
+	for {
+		problems, page, err := store.ProblemMapper().FindAllProblemsByCursor(cursor, 2)
+		assertNoError(t, err)
+		for _, problem := range problems {
+			if seen[problem.ID] || problem.RawProblem == nil {
+				t.Fatalf("unexpected problem: %+v", problem)
+			}
+			seen[problem.ID] = true
+		}
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	if len(seen) != len(f.Problems) {
+		t.Fatalf("expected %v problems, got %v", len(f.Problems), len(seen))
+	}
+}
+
+func TestProblemMapperImpl_Difficulty(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	//第一题: 两次提交一次通过 解题者alice
+	assertNoError(t, mapper.AddProblemSubmittedCountById(f.Problems[0].ID))
+	assertNoError(t, mapper.AddProblemSubmittedCountById(f.Problems[0].ID))
+	assertNoError(t, mapper.AddProblemAcceptedCountById(f.Problems[0].ID))
+	before, err := mapper.FindProblemById(f.Problems[0].ID)
+	assertNoError(t, err)
+	added, err := mapper.AddProblemSolver(f.Problems[0].ID, f.Users[0].ID)
+	assertNoError(t, err)
+	if !added {
+		t.Fatalf("first solve should add a solver")
+	}
+	//解题者计数不影响updated_at 不改变题目在游标分页中的位置
+	if solved, err := mapper.FindProblemById(f.Problems[0].ID); err != nil || !solved.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Fatalf("solver update moved updated_at: %v", err)
+	}
+	if added, err = mapper.AddProblemSolver(f.Problems[0].ID, f.Users[0].ID); err != nil || added {
+		t.Fatalf("repeated solve should be ignored: %v %v", added, err)
+	}
+	//第二题: 四次提交无人通过
+	for i := 0; i < 4; i++ {
+		assertNoError(t, mapper.AddProblemSubmittedCountById(f.Problems[1].ID))
+	}
+	first, err := mapper.FindProblemById(f.Problems[0].ID)
+	assertNoError(t, err)
+	second, err := mapper.FindProblemById(f.Problems[1].ID)
+	assertNoError(t, err)
+	if first.Solvers != 1 || first.Difficulty == difficulty.Unrated || second.Difficulty <= first.Difficulty {
+		t.Fatalf("unexpected difficulties: %+v %+v", first, second)
+	}
+	problems, count, err := mapper.FindAllProblems(1, 10, true, &problem_mapper.ProblemListOption{SortByDifficulty: true})
+	assertNoError(t, err)
+	if count != 3 || problems[0].ID != second.ID || problems[1].ID != first.ID || problems[2].Difficulty != difficulty.Unrated {
+		t.Fatalf("unexpected difficulty order: %+v", problems)
+	}
+	problems, count, err = mapper.FindAllProblems(1, 10, false, &problem_mapper.ProblemListOption{MinDifficulty: 1, MaxDifficulty: first.Difficulty})
+	assertNoError(t, err)
+	if count != 1 || problems[0].ID != first.ID {
+		t.Fatalf("unexpected difficulty filter: count=%v problems=%+v", count, problems)
+	}
+	//解题者的解题数变化后重新计算
+	for i := 0; i < 50; i++ {
+		assertNoError(t, store.UserMapper().AddUserAcceptCountById(f.Users[0].ID))
+	}
+	rating, err := mapper.RecomputeDifficulty(f.Problems[0].ID)
+	assertNoError(t, err)
+	if rating <= first.Difficulty {
+		t.Fatalf("stronger solver should raise difficulty: %v <= %v", rating, first.Difficulty)
+	}
+}
+
+func TestProblemMapperImpl_FindProblemById(t *testing.T) {
+	store, f := setup(t)
+	problem, err := store.ProblemMapper().FindProblemById(f.Problems[1].ID)
+	assertNoError(t, err)
+	if problem.RawProblem == nil || problem.RawProblem.Title != "Sum Problem" {
+		t.Fatalf("raw problem not loaded: %+v", problem)
+	}
+	if _, err = store.ProblemMapper().FindProblemById(0); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if _, err = store.ProblemMapper().FindProblemById(9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_FindProblemsByIds(t *testing.T) {
+	store, f := setup(t)
+	problems, err := store.ProblemMapper().FindProblemsByIds([]uint{f.Problems[0].ID, f.Problems[2].ID})
+	assertNoError(t, err)
+	if len(problems) != 2 || problems[1].RawProblem.Title != "A + B Problem II" {
+		t.Fatalf("unexpected problems: %+v", problems)
+	}
+}
+
+func TestProblemMapperImpl_SearchProblemByCondition(t *testing.T) {
+	store, f := setup(t)
+	problems, count, err := store.ProblemMapper().SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Title: "a + b"}, 1, 10)
+	assertNoError(t, err)
+	if count != 2 || len(problems) != 2 {
+		t.Fatalf("expected 2 matches, got count=%v problems=%+v", count, problems)
+	}
+	problems, count, err = store.ProblemMapper().SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Title: "a + b"}, 2, 1)
+	assertNoError(t, err)
+	if count != 2 || len(problems) != 1 {
+		t.Fatalf("expected second page of 1, got count=%v problems=%+v", count, problems)
+	}
+	problems, count, err = store.ProblemMapper().SearchProblemByCondition(&problem_mapper.ProblemSearchParam{ProblemId: f.Problems[1].ID}, 1, 10)
+	assertNoError(t, err)
+	if count != 1 || problems[0].ID != f.Problems[1].ID {
+		t.Fatalf("unexpected id search: count=%v problems=%+v", count, problems)
+	}
+	_, count, err = store.ProblemMapper().SearchProblemByCondition(&problem_mapper.ProblemSearchParam{}, 1, 10)
+	assertNoError(t, err)
+	if count != 3 {
+		t.Fatalf("empty search should list all problems, got %v", count)
+	}
+}
+
+func TestProblemMapperImpl_SearchProblemByConditionFilters(t *testing.T) {
+	store, f := setup(t)
+	db := store.DB
+	assertNoError(t, db.Model(f.Problems[0]).Updates(map[string]interface{}{"submitted": 4, "accepted": 1}).Error)
+	assertNoError(t, db.Model(f.Problems[1]).Updates(map[string]interface{}{"submitted": 2, "accepted": 2}).Error)
+	assertNoError(t, db.Model(f.Problems[2]).Update("status", model.ProblemHidden).Error)
+	assertNoError(t, db.Model(f.RawProblems[1]).Update("source", "HDU 2007-Spring Contest").Error)
+	hidden := model.ProblemHidden
+	cases := []struct {
+		param *problem_mapper.ProblemSearchParam
+		ids   []uint
+	}{
+		{&problem_mapper.ProblemSearchParam{RemoteOJ: remote_oj.HDU}, []uint{f.Problems[0].ID, f.Problems[1].ID}},
+		{&problem_mapper.ProblemSearchParam{RemoteOJ: remote_oj.HDU, AllStatuses: true}, []uint{f.Problems[0].ID, f.Problems[1].ID, f.Problems[2].ID}},
+		{&problem_mapper.ProblemSearchParam{Source: "spring"}, []uint{f.Problems[1].ID}},
+		{&problem_mapper.ProblemSearchParam{Source: "2007_spring"}, []uint{}},
+		{&problem_mapper.ProblemSearchParam{Title: "%"}, []uint{}},
+		{&problem_mapper.ProblemSearchParam{Status: &hidden}, []uint{}},
+		{&problem_mapper.ProblemSearchParam{Status: &hidden, AllStatuses: true}, []uint{f.Problems[2].ID}},
+		{&problem_mapper.ProblemSearchParam{MinAcceptRatio: 0.2}, []uint{f.Problems[0].ID, f.Problems[1].ID}},
+		{&problem_mapper.ProblemSearchParam{MinAcceptRatio: 0.2, MaxAcceptRatio: 0.5}, []uint{f.Problems[0].ID}},
+		{&problem_mapper.ProblemSearchParam{Title: "problem", MaxAcceptRatio: 0.5}, []uint{f.Problems[0].ID}},
+		{&problem_mapper.ProblemSearchParam{Title: "problem", MaxAcceptRatio: 0.5, AllStatuses: true}, []uint{f.Problems[0].ID, f.Problems[2].ID}},
+	}
+	for _, c := range cases {
+		problems, count, err := store.ProblemMapper().SearchProblemByCondition(c.param, 1, 10)
+		assertNoError(t, err)
+		if int(count) != len(c.ids) || len(problems) != len(c.ids) {
+			t.Fatalf("param %+v: expected %v, got count=%v problems=%+v", c.param, c.ids, count, problems)
+		}
+		for i, problem := range problems {
+			if problem.ID != c.ids[i] || problem.RawProblem == nil {
+				t.Fatalf("param %+v: unexpected problem %+v", c.param, problem)
+			}
+		}
+	}
+}
+
+func TestProblemMapperImpl_SearchProblemByLimits(t *testing.T) {
+	store, f := setup(t)
+	if f.RawProblems[0].TimeLimitMs != 1000 || f.RawProblems[0].MemoryLimitKb != 32768 {
+		t.Fatalf("limits not normalized: %+v", f.RawProblems[0])
+	}
+	raw, err := store.ProblemMapper().AddOrModifyRawProblem(&model.RawProblem{
+		TimeLimit:       "3000/3000 MS (Java/Others)",
+		MemoryLimit:     "131072/65536 K (Java/Others)",
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1002",
+	})
+	assertNoError(t, err)
+	if raw.TimeLimitMs != 3000 || raw.MemoryLimitKb != 65536 {
+		t.Fatalf("limits not updated: %+v", raw)
+	}
+	cases := []struct {
+		param *problem_mapper.ProblemSearchParam
+		ids   []uint
+	}{
+		{&problem_mapper.ProblemSearchParam{MinTimeLimitMs: 2000}, []uint{f.Problems[2].ID}},
+		{&problem_mapper.ProblemSearchParam{MaxTimeLimitMs: 1000}, []uint{f.Problems[0].ID, f.Problems[1].ID}},
+		{&problem_mapper.ProblemSearchParam{MinMemoryLimitKb: 32768, MaxMemoryLimitKb: 32768}, []uint{f.Problems[0].ID, f.Problems[1].ID}},
+	}
+	for _, c := range cases {
+		problems, count, err := store.ProblemMapper().SearchProblemByCondition(c.param, 1, 10)
+		assertNoError(t, err)
+		if int(count) != len(c.ids) || len(problems) != len(c.ids) {
+			t.Fatalf("param %+v: expected %v, got count=%v problems=%+v", c.param, c.ids, count, problems)
+		}
+		for i, problem := range problems {
+			if problem.ID != c.ids[i] {
+				t.Fatalf("param %+v: unexpected problem %+v", c.param, problem)
+			}
+		}
+	}
+}
+
+func TestProblemMapperImpl_FullTextSearch(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.DB.Model(f.RawProblems[1]).Update("hint", "Use long long for the sum.").Error)
+	hits, count, err := store.ProblemMapper().FullTextSearch("problem II", 1, 10)
+	assertNoError(t, err)
+	if count != 3 || len(hits) != 3 {
+		t.Fatalf("expected 3 hits, got count=%v hits=%+v", count, hits)
+	}
+	if hits[0].Problem.ID != f.Problems[2].ID || hits[1].Problem.ID != f.Problems[0].ID || hits[0].Score <= hits[1].Score {
+		t.Fatalf("unexpected ranking: %+v", hits)
+	}
+	if title := hits[0].Snippets["title"]; title != "A + B <em>Problem</em> <em>II</em>" {
+		t.Fatalf("unexpected title snippet: %q", title)
+	}
+	hits, count, err = store.ProblemMapper().FullTextSearch("problem II", 2, 1)
+	assertNoError(t, err)
+	if count != 3 || len(hits) != 1 || hits[0].Problem.ID != f.Problems[0].ID {
+		t.Fatalf("unexpected second page: count=%v hits=%+v", count, hits)
+	}
+	hits, count, err = store.ProblemMapper().FullTextSearch("SUM", 1, 10)
+	assertNoError(t, err)
+	if count != 1 || hits[0].Problem.RawProblem == nil || hits[0].Snippets["hint"] != "Use long long for the <em>sum</em>." {
+		t.Fatalf("unexpected hits: %+v", hits[0])
+	}
+	assertNoError(t, store.DB.Model(f.RawProblems[1]).Update("hint", "<p>Print 100% of the <b>sum</b> &amp; a_b</p>").Error)
+	hits, count, err = store.ProblemMapper().FullTextSearch("%", 1, 10)
+	assertNoError(t, err)
+	if count != 1 || hits[0].Snippets["hint"] != "Print 100<em>%</em> of the sum &amp; a_b" {
+		t.Fatalf("expected only the literal %% to match, got count=%v hits=%+v", count, hits)
+	}
+	_, count, err = store.ProblemMapper().FullTextSearch("_", 1, 10)
+	assertNoError(t, err)
+	if count != 1 {
+		t.Fatalf("expected only the literal _ to match, got %v", count)
+	}
+	//LIKE在题面原文上匹配 只命中标签的字段计分但没有片段
+	assertNoError(t, store.DB.Model(f.RawProblems[1]).Update("hint", `<span class="note">Mind the overflow</span>`).Error)
+	hits, count, err = store.ProblemMapper().FullTextSearch("span", 1, 10)
+	assertNoError(t, err)
+	if _, ok := hits[0].Snippets["hint"]; count != 1 || hits[0].Problem.ID != f.Problems[1].ID || ok {
+		t.Fatalf("unexpected markup hits: count=%v hits=%+v", count, hits)
+	}
+	_, _, err = store.ProblemMapper().FullTextSearch("  ", 1, 10)
+	if !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_ProblemTags(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	tags, err := mapper.AttachProblemTags(f.Problems[0].ID, []string{" DP ", "graphs", "dp"})
+	assertNoError(t, err)
+	if len(tags) != 2 || tags[0].Name != "dp" || tags[1].Name != "graphs" {
+		t.Fatalf("unexpected tags: %+v", tags)
+	}
+	_, err = mapper.AttachProblemTags(f.Problems[1].ID, []string{"dp"})
+	assertNoError(t, err)
+	_, err = mapper.AttachProblemTags(f.Problems[0].ID, []string{"graphs", "math"})
+	assertNoError(t, err)
+	counts, err := mapper.CountTags()
+	assertNoError(t, err)
+	if len(counts) != 3 || counts[0].Name != "dp" || counts[0].Count != 2 || counts[1].Name != "graphs" {
+		t.Fatalf("unexpected tag counts: %+v", counts)
+	}
+	problems, count, err := mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Tags: []string{"dp", "Graphs"}}, 1, 10)
+	assertNoError(t, err)
+	if count != 1 || problems[0].ID != f.Problems[0].ID {
+		t.Fatalf("unexpected tag search: count=%v problems=%+v", count, problems)
+	}
+	_, count, err = mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Tags: []string{"dp"}}, 1, 10)
+	assertNoError(t, err)
+	if count != 2 {
+		t.Fatalf("expected 2 problems tagged dp, got %v", count)
+	}
+	assertNoError(t, mapper.DetachProblemTags(f.Problems[0].ID, []string{"dp", "math"}))
+	tags, err = mapper.FindProblemTags(f.Problems[0].ID)
+	assertNoError(t, err)
+	if len(tags) != 1 || tags[0].Name != "graphs" {
+		t.Fatalf("unexpected tags after detach: %+v", tags)
+	}
+}
+
+func TestProblemMapperImpl_ImportRemoteTags(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.DB.Model(f.RawProblems[1]).Update("remote_tags", "Greedy, Sorting，greedy").Error)
+	tags, err := store.ProblemMapper().ImportRemoteTags(f.Problems[1].ID)
+	assertNoError(t, err)
+	if len(tags) != 2 || tags[0].Name != "greedy" || tags[1].Name != "sorting" {
+		t.Fatalf("unexpected imported tags: %+v", tags)
+	}
+}
+
+func TestProblemMapperImpl_DeleteProblemById(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.ProblemMapper().DeleteProblemById(f.Problems[0].ID))
+	_, count, err := store.ProblemMapper().FindAllProblems(1, 10, false)
+	assertNoError(t, err)
+	if count != 2 {
+		t.Fatalf("expected soft deleted problem to be hidden, got %v", count)
+	}
+}
+
+func TestProblemMapperImpl_FindProblemByRandom(t *testing.T) {
+	store, f := setup(t)
+	problem, err := store.ProblemMapper().FindProblemByRandom()
+	assertNoError(t, err)
+	found := false
+	for _, p := range f.Problems {
+		found = found || p.ID == problem.ID
+	}
+	if !found {
+		t.Fatalf("random problem %v is not a fixture problem", problem.ID)
+	}
+}
+
+func TestProblemMapperImpl_FindRawProblemsWithGroup(t *testing.T) {
+	store, f := setup(t)
+	rawProblems, groups, count, err := store.ProblemMapper().FindRawProblemsWithGroup(1, 3)
+	assertNoError(t, err)
+	if count != 4 || len(rawProblems) != 3 || len(groups) != 3 {
+		t.Fatalf("unexpected page: count=%v raw=%v groups=%v", count, len(rawProblems), len(groups))
+	}
+	if rawProblems[0].ID != f.RawProblems[3].ID {
+		t.Fatalf("expected id desc order, got %v first", rawProblems[0].ID)
+	}
+}
+
+func TestProblemMapperImpl_UpdateProblemGroup(t *testing.T) {
+	store, f := setup(t)
+	raw := f.RawProblems[3]
+	assertNoError(t, store.ProblemMapper().UpdateProblemGroup(raw.ID, raw.ID))
+	problems, count, err := store.ProblemMapper().FindAllProblems(1, 10, false)
+	assertNoError(t, err)
+	if count != 4 || problems[3].RawProblemId != raw.ID || problems[3].GroupId != raw.ID {
+		t.Fatalf("expected new problem for new group, got count=%v problems=%+v", count, problems)
+	}
+	groups, err := store.ProblemMapper().FindGroupProblemsById(problems[3].ID)
+	assertNoError(t, err)
+	if len(groups) != 1 || !groups[0].MainProblem {
+		t.Fatalf("moved raw problem should be main of its new group: %+v", groups)
+	}
+}
+
+//移出原组的主题目后 原组的题目指向剩下的原始题目
+func TestProblemMapperImpl_UpdateProblemGroupMovesMain(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.ProblemMapper().UpdateProblemGroup(f.RawProblems[2].ID, f.Problems[0].GroupId))
+	old, err := store.ProblemMapper().FindProblemById(f.Problems[2].ID)
+	assertNoError(t, err)
+	if old.RawProblemId != f.RawProblems[3].ID {
+		t.Fatalf("old problem should point at the remaining raw problem: %+v", old)
+	}
+	groups, err := store.ProblemMapper().FindGroupProblemsById(f.Problems[0].ID)
+	assertNoError(t, err)
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == f.RawProblems[0].ID) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+}
+
+func TestProblemMapperImpl_AcceptGroupSuggestionMergesProblems(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	//镜像已经单独成组并有自己的题目与提交
+	mirror, err := mapper.AddOrModifyRawProblem(&model.RawProblem{
+		Title:           "A + B Problem",
+		Description:     "Calculate A + B Problem",
+		SampleInput:     "1 2",
+		SampleOutput:    "3",
+		RemoteOJ:        remote_oj.HDU + 1,
+		RemoteProblemId: "1000",
+	})
+	assertNoError(t, err)
+	_, err = mapper.AddOrModifyProblemGroup(&model.ProblemGroup{RawProblemId: mirror.ID, GroupId: mirror.ID, MainProblem: true, RemoteOJ: mirror.RemoteOJ})
+	assertNoError(t, err)
+	duplicate, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: mirror.ID, RawProblemId: mirror.ID})
+	assertNoError(t, err)
+	assertNoError(t, mapper.AddProblemSubmittedCountById(duplicate.ID))
+	assertNoError(t, mapper.AddProblemAcceptedCountById(duplicate.ID))
+	assertNoError(t, mapper.AddProblemSubmittedCountById(f.Problems[0].ID))
+	suggestions, err := mapper.SuggestGroupMerges(mirror.ID, 0)
+	assertNoError(t, err)
+	if len(suggestions) == 0 || suggestions[0].TargetRawProblemId != f.RawProblems[0].ID {
+		t.Fatalf("expected HDU 1000 as the best match, got %+v", suggestions)
+	}
+	assertNoError(t, mapper.AcceptGroupSuggestion(suggestions[0].ID))
+	if _, err = mapper.FindProblemById(duplicate.ID); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("duplicate problem should be merged away, got %v", err)
+	}
+	merged, err := mapper.FindProblemById(f.Problems[0].ID)
+	assertNoError(t, err)
+	if merged.Submitted != 2 || merged.Accepted != 1 || merged.RawProblemId != f.RawProblems[0].ID {
+		t.Fatalf("counters should be summed: %+v", merged)
+	}
+	groups, err := mapper.FindGroupProblemsById(merged.ID)
+	assertNoError(t, err)
+	if len(groups) != 2 {
+		t.Fatalf("mirror should join the group of HDU 1000: %+v", groups)
+	}
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == f.RawProblems[0].ID) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+	if _, count, err := mapper.FindAllProblems(1, 10, false); err != nil || count != 3 {
+		t.Fatalf("duplicate should not be listed: count=%v err=%v", count, err)
+	}
+}
+
+func TestProblemMapperImpl_MergeProblemGroups(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	target, source := f.Problems[1], f.Problems[0]
+	assertNoError(t, mapper.AddProblemSubmittedCountById(source.ID))
+	assertNoError(t, mapper.AddProblemAcceptedCountById(source.ID))
+	assertNoError(t, mapper.AddProblemSubmittedCountById(target.ID))
+	_, err := mapper.AttachProblemTags(source.ID, []string{"math", "greedy"})
+	assertNoError(t, err)
+	_, err = mapper.AttachProblemTags(target.ID, []string{"math"})
+	assertNoError(t, err)
+	_, err = mapper.AddProblemSolver(source.ID, f.Users[1].ID)
+	assertNoError(t, err)
+	merged, err := mapper.MergeProblemGroups(target.GroupId, source.GroupId)
+	assertNoError(t, err)
+	if merged.ID != target.ID || merged.RawProblemId != f.RawProblems[1].ID || merged.Submitted != 2 || merged.Accepted != 1 || merged.Solvers != 1 {
+		t.Fatalf("unexpected merged problem: %+v", merged)
+	}
+	if _, err = mapper.FindProblemById(source.ID); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("source problem should be deleted, got %v", err)
+	}
+	groups, err := mapper.FindGroupProblemsById(target.ID)
+	assertNoError(t, err)
+	if len(groups) != 2 {
+		t.Fatalf("expected both raw problems in the group: %+v", groups)
+	}
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == merged.RawProblemId) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+	var submissions int
+	assertNoError(t, store.DB.Model(&model.Submission{}).Where("problem_id = ?", target.ID).Count(&submissions).Error)
+	if submissions != 4 {
+		t.Fatalf("submissions should follow the merge, got %v", submissions)
+	}
+	var contestProblems int
+	assertNoError(t, store.DB.Model(&model.ContestProblem{}).Where("problem_id = ?", target.ID).Count(&contestProblems).Error)
+	if contestProblems != 2 {
+		t.Fatalf("contest problems should follow the merge, got %v", contestProblems)
+	}
+	tags, err := mapper.FindProblemTags(target.ID)
+	assertNoError(t, err)
+	if len(tags) != 2 {
+		t.Fatalf("tags should be unioned: %+v", tags)
+	}
+	if _, err = mapper.MergeProblemGroups(target.GroupId, target.GroupId); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err = mapper.MergeProblemGroups(target.GroupId, 9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_SplitProblemGroup(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	//1002是所在组的主题目 拆出后1003成为原组的主题目
+	split, err := mapper.SplitProblemGroup(f.RawProblems[2].ID)
+	assertNoError(t, err)
+	if split.ID == f.Problems[2].ID || split.RawProblemId != f.RawProblems[2].ID || split.GroupId == f.Problems[2].GroupId {
+		t.Fatalf("unexpected split problem: %+v", split)
+	}
+	old, err := mapper.FindProblemById(f.Problems[2].ID)
+	assertNoError(t, err)
+	if old.RawProblemId != f.RawProblems[3].ID {
+		t.Fatalf("remaining member should become main: %+v", old)
+	}
+	groups, err := mapper.FindGroupProblemsById(old.ID)
+	assertNoError(t, err)
+	if len(groups) != 1 || !groups[0].MainProblem {
+		t.Fatalf("unexpected remaining group: %+v", groups)
+	}
+	groups, err = mapper.FindGroupProblemsById(split.ID)
+	assertNoError(t, err)
+	if len(groups) != 1 || !groups[0].MainProblem || groups[0].RawProblemId != f.RawProblems[2].ID {
+		t.Fatalf("unexpected split group: %+v", groups)
+	}
+	if _, err = mapper.SplitProblemGroup(f.RawProblems[0].ID); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_SetMainProblem(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	assertNoError(t, mapper.SetMainProblem(f.RawProblems[3].ID))
+	problem, err := mapper.FindProblemById(f.Problems[2].ID)
+	assertNoError(t, err)
+	if problem.RawProblemId != f.RawProblems[3].ID || problem.RawProblem.Title != f.RawProblems[3].Title {
+		t.Fatalf("problem should point at the new main raw problem: %+v", problem)
+	}
+	groups, err := mapper.FindGroupProblemsById(problem.ID)
+	assertNoError(t, err)
+	for _, group := range groups {
+		if group.MainProblem != (group.RawProblemId == f.RawProblems[3].ID) {
+			t.Fatalf("unexpected main flag: %+v", group)
+		}
+	}
+	if err = mapper.SetMainProblem(9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_RemoteTargets(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	problem := f.Problems[0]
+	mirror, err := mapper.AddOrModifyRawProblem(&model.RawProblem{Title: "A + B", RemoteOJ: remote_oj.HDU + 1, RemoteProblemId: "1"})
+	assertNoError(t, err)
+	_, err = mapper.AddOrModifyProblemGroup(&model.ProblemGroup{RawProblemId: mirror.ID, GroupId: problem.GroupId, RemoteOJ: mirror.RemoteOJ, RemoteProblemId: "1"})
+	assertNoError(t, err)
+	targets, err := mapper.FindRemoteTargets(problem.ID)
+	assertNoError(t, err)
+	if len(targets) != 2 || targets[0].RawProblemId != problem.RawProblemId {
+		t.Fatalf("main problem should be tried first: %+v", targets)
+	}
+	//f.Submissions[0]提交到HDU 连续失败后回退到镜像
+	for i := 0; i < problem_mapper.RemoteFailureThreshold; i++ {
+		assertNoError(t, mapper.RecordRemoteResult(f.Submissions[0].ID, false))
+	}
+	targets, err = mapper.FindRemoteTargets(problem.ID)
+	assertNoError(t, err)
+	if targets[0].RawProblemId != mirror.ID || targets[1].RemoteFailure != 3 || targets[1].ConsecutiveFailures != 3 || targets[1].LastFailureAt == nil {
+		t.Fatalf("failing remote oj should be tried last: %+v %+v", targets[0], targets[1])
+	}
+	assertNoError(t, mapper.RecordRemoteResult(f.Submissions[0].ID, true))
+	targets, err = mapper.FindRemoteTargets(problem.ID)
+	assertNoError(t, err)
+	if targets[0].RawProblemId != problem.RawProblemId || targets[0].ConsecutiveFailures != 0 || targets[0].RemoteSuccess != 1 {
+		t.Fatalf("recovered remote oj should be tried first again: %+v", targets[0])
+	}
+	//题目所在组没有该oj的原始题目
+	submission, err := store.SubmissionMapper().AddOrModifySubmission(&model.Submission{ProblemId: f.Problems[1].ID, UserId: f.Users[1].ID, RemoteOJ: remote_oj.HDU + 1})
+	assertNoError(t, err)
+	if err = mapper.RecordRemoteResult(submission.ID, true); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestProblemMapperImpl_UpdateProblemStatus(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	problem, err := mapper.UpdateProblemStatus(f.Problems[0].ID, model.ProblemHidden)
+	assertNoError(t, err)
+	if problem.Status != model.ProblemHidden || problem.RawProblem == nil {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	_, err = mapper.UpdateProblemStatus(f.Problems[1].ID, model.ProblemContestOnly)
+	assertNoError(t, err)
+	//隐藏与仅比赛的题目不出现在列表、搜索与随机选题中
+	problems, count, err := mapper.FindAllProblems(1, 10, false)
+	assertNoError(t, err)
+	if count != 1 || problems[0].ID != f.Problems[2].ID {
+		t.Fatalf("only the public problem should be listed: count=%v problems=%+v", count, problems)
+	}
+	if _, count, err = mapper.FindAllProblems(1, 10, false, &problem_mapper.ProblemListOption{AllStatuses: true}); err != nil || count != 3 {
+		t.Fatalf("admin view should list every problem: count=%v err=%v", count, err)
+	}
+	if problems, _, err = mapper.FindAllProblemsByCursor("", 10); err != nil || len(problems) != 1 {
+		t.Fatalf("cursor listing should hide problems: %+v %v", problems, err)
+	}
+	if _, count, err = mapper.SearchProblemByCondition(&problem_mapper.ProblemSearchParam{Title: "a + b"}, 1, 10); err != nil || count != 1 {
+		t.Fatalf("search should hide problems: count=%v err=%v", count, err)
+	}
+	for i := 0; i < 5; i++ {
+		random, err := mapper.FindProblemByRandom()
+		assertNoError(t, err)
+		if random.ID != f.Problems[2].ID {
+			t.Fatalf("random problem %v is not public", random.ID)
+		}
+	}
+	//比赛与按id查询不受状态影响
+	if _, err = mapper.FindProblemById(f.Problems[1].ID); err != nil {
+		t.Fatalf("contest-only problem should be found by id: %v", err)
+	}
+	_, err = mapper.UpdateProblemStatus(f.Problems[0].ID, model.ProblemRetired)
+	assertNoError(t, err)
+	if _, err = mapper.UpdateProblemStatus(f.Problems[0].ID, model.ProblemPublic); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("retired problem cannot be published directly, got %v", err)
+	}
+	if _, err = mapper.UpdateProblemStatus(f.Problems[2].ID, model.ProblemDraft); !errors.Is(err, errors.ErrConflict) {
+		t.Fatalf("public problem cannot return to draft, got %v", err)
+	}
+	if _, err = mapper.UpdateProblemStatus(f.Problems[2].ID, model.ProblemStatus(99)); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+	//AddOrModifyProblem不能绕过状态转换
+	modified, err := mapper.AddOrModifyProblem(&model.Problem{GroupId: f.Problems[2].GroupId, RawProblemId: f.Problems[2].RawProblemId, Status: model.ProblemDraft})
+	assertNoError(t, err)
+	if modified.Status != model.ProblemPublic {
+		t.Fatalf("status should not change through AddOrModifyProblem: %+v", modified)
+	}
+	if _, err = mapper.AddOrModifyProblem(&model.Problem{GroupId: f.RawProblems[3].ID, RawProblemId: f.RawProblems[3].ID, Status: model.ProblemStatus(99)}); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for unknown status, got %v", err)
+	}
+	//管理员视图的随机选题包含非公开题目
+	if _, err = mapper.UpdateProblemStatus(f.Problems[2].ID, model.ProblemHidden); err != nil {
+		t.Fatalf("hide: %v", err)
+	}
+	if _, err = mapper.FindProblemByRandom(); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without public problems, got %v", err)
+	}
+	if _, err = mapper.FindProblemByRandom(&problem_mapper.ProblemListOption{AllStatuses: true}); err != nil {
+		t.Fatalf("admin view should pick any problem: %v", err)
+	}
+}
+
+func TestProblemMapperImpl_CountTagsVisibility(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.ProblemMapper()
+	for _, problem := range f.Problems[:2] {
+		_, err := mapper.AttachProblemTags(problem.ID, []string{"math"})
+		assertNoError(t, err)
+	}
+	_, err := mapper.UpdateProblemStatus(f.Problems[1].ID, model.ProblemHidden)
+	assertNoError(t, err)
+	counts, err := mapper.CountTags()
+	assertNoError(t, err)
+	if len(counts) != 1 || counts[0].Count != 1 {
+		t.Fatalf("hidden problems should not be counted: %+v", counts)
+	}
+	counts, err = mapper.CountTags(&problem_mapper.ProblemListOption{AllStatuses: true})
+	assertNoError(t, err)
+	if len(counts) != 1 || counts[0].Count != 2 {
+		t.Fatalf("admin view should count every problem: %+v", counts)
+	}
+}
+
+func TestProblemMapperImpl_WithContext(t *testing.T) {
+	store, f := setup(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	mapper := store.ProblemMapper().WithContext(ctx)
+	_, err := mapper.FindProblemById(f.Problems[0].ID)
+	assertNoError(t, err)
+	cancel()
+	_, err = mapper.FindProblemById(f.Problems[0].ID)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	if _, _, err = mapper.FindAllProblems(1, 10, true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled listing, got %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package submission_mapper
 
 import (
+	"context"
 	"github.com/ecnuvj/vhoj_common/pkg/common/constants/language"
 	"github.com/ecnuvj/vhoj_common/pkg/common/constants/status_type"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
 	"github.com/ecnuvj/vhoj_db/pkg/util"
 	"github.com/jinzhu/gorm"
 	"time"
@@ -24,10 +26,12 @@ type UserSubmissionCondition struct {
 }
 
 type ISubmissionMapper interface {
+	WithContext(ctx context.Context) ISubmissionMapper
 	AddOrModifySubmission(submission *model.Submission) (*model.Submission, error)
 	FindSubmissionById(submissionId uint) (*model.Submission, error)
 	FindProblemGroupById(submissionId uint) ([]*model.ProblemGroup, error)
 	FindSubmissions(pageNo int32, pageSize int32, condition *SearchSubmissionCondition) ([]*model.Submission, int32, error)
+	FindSubmissionsByCursor(cursor string, pageSize int32, condition *SearchSubmissionCondition) ([]*model.Submission, *util.CursorPage, error)
 	FindSubmissionsGroupByResult(condition *UserSubmissionCondition) ([]*model.Submission, error)
 	FindSubmissionsByContestId(uint, time.Time, time.Time) ([]*model.Submission, error)
 	UpdateSubmissionById(submission *model.Submission) (*model.Submission, error)
@@ -41,25 +45,36 @@ type SubmissionMapperImpl struct {
 	DB *gorm.DB
 }
 
-func InitMapper(db *gorm.DB) {
-	SubmissionMapper = &SubmissionMapperImpl{
+func NewMapper(db *gorm.DB) ISubmissionMapper {
+	return &SubmissionMapperImpl{
 		DB: db,
 	}
 }
 
+func InitMapper(db *gorm.DB) {
+	SubmissionMapper = NewMapper(db)
+}
+
+//返回绑定ctx的mapper 其所有查询都会随ctx取消或超时而中断
+func (s *SubmissionMapperImpl) WithContext(ctx context.Context) ISubmissionMapper {
+	return NewMapper(util.WithContext(s.DB, ctx))
+}
+
 func (s *SubmissionMapperImpl) AddOrModifySubmission(submission *model.Submission) (*model.Submission, error) {
 	var sub model.Submission
 	if err := s.DB.Where("id = ?", submission.ID).First(&sub).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			result := s.DB.Create(submission)
 			if result.Error != nil {
-				return nil, result.Error
+				return nil, errors.Wrap("submission_mapper.AddOrModifySubmission", result.Error)
 			}
+		} else {
+			return nil, errors.Wrap("submission_mapper.AddOrModifySubmission", err)
 		}
 	} else {
 		result := s.DB.Model(submission).Update(submission)
 		if result.Error != nil {
-			return nil, result.Error
+			return nil, errors.Wrap("submission_mapper.AddOrModifySubmission", result.Error)
 		}
 	}
 	return submission, nil
@@ -68,7 +83,7 @@ func (s *SubmissionMapperImpl) AddOrModifySubmission(submission *model.Submissio
 func (s *SubmissionMapperImpl) UpdateSubmissionById(submission *model.Submission) (*model.Submission, error) {
 	result := s.DB.Model(submission).Update(submission).Find(submission)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("submission_mapper.UpdateSubmissionById", result.Error)
 	}
 	return submission, nil
 }
@@ -81,13 +96,15 @@ func (s *SubmissionMapperImpl) UpdateSubmissionCEInfoById(submissionId uint, inf
 			compileInfo.Info = info
 			result := s.DB.Create(&compileInfo)
 			if result.Error != nil {
-				return result.Error
+				return errors.Wrap("submission_mapper.UpdateSubmissionCEInfoById", result.Error)
 			}
+		} else {
+			return errors.Wrap("submission_mapper.UpdateSubmissionCEInfoById", err)
 		}
 	} else {
 		result := s.DB.Model(&compileInfo).Where("submission_id = ?", submissionId).Update(&compileInfo)
 		if result.Error != nil {
-			return result.Error
+			return errors.Wrap("submission_mapper.UpdateSubmissionCEInfoById", result.Error)
 		}
 	}
 	return nil
@@ -98,7 +115,7 @@ func (s *SubmissionMapperImpl) FindSubmissionById(submissionId uint) (*model.Sub
 	code := &model.SubmissionCode{}
 	result := s.DB.Model(submission).Find(submission).Related(code)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("submission_mapper.FindSubmissionById", result.Error)
 	}
 	submission.SubmissionCode = code
 	return submission, nil
@@ -110,11 +127,11 @@ func (s *SubmissionMapperImpl) FindProblemGroupById(submissionId uint) ([]*model
 			ID: submissionId,
 		},
 	}
-	result := s.DB.First(submission)
+	result := s.DB.First(&submission)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("submission_mapper.FindProblemGroupById", result.Error)
 	}
-	return problem_mapper.ProblemMapper.FindGroupProblemsById(submission.ProblemId)
+	return problem_mapper.NewMapper(s.DB).FindGroupProblemsById(submission.ProblemId)
 }
 
 func (s *SubmissionMapperImpl) ResetSubmissionById(submissionId uint) error {
@@ -133,43 +150,64 @@ func (s *SubmissionMapperImpl) ResetSubmissionById(submissionId uint) error {
 			"real_run_id": "",
 		})
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("submission_mapper.ResetSubmissionById", result.Error)
 	}
 	return nil
 }
 
 func (s *SubmissionMapperImpl) FindSubmissions(pageNo int32, pageSize int32, condition *SearchSubmissionCondition) ([]*model.Submission, int32, error) {
-	result := s.DB.Model(&model.Submission{})
 	limit, offset := util.CalLimitOffset(pageNo, pageSize)
-	if condition == nil {
-		condition = &SearchSubmissionCondition{}
-	}
 	var count int32
 	var submissions []*model.Submission
-	if condition.Username != "" {
-		result = result.Where("username = ?", condition.Username)
-	}
-	if condition.ProblemId != 0 {
-		result = result.Where("problem_id = ?", condition.ProblemId)
-	}
-	if condition.Status != 0 {
-		result = result.Where("result = ?", condition.Status)
-	}
-	if condition.Language != 0 {
-		result = result.Where("language = ?", condition.Language)
-	}
-	result = result.
+	result := searchCondition(s.DB.Model(&model.Submission{}), condition).
 		Count(&count).
 		Order("updated_at desc").
 		Limit(limit).
 		Offset(offset).
 		Find(&submissions)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("submission_mapper.FindSubmissions", result.Error)
 	}
 	return submissions, count, nil
 }
 
+//keyset分页 不做count 适合提交记录这样的大表 cursor为空时返回第一页
+func (s *SubmissionMapperImpl) FindSubmissionsByCursor(cursor string, pageSize int32, condition *SearchSubmissionCondition) ([]*model.Submission, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("submission_mapper.FindSubmissionsByCursor", "%v", err)
+	}
+	var submissions []*model.Submission
+	result := util.KeysetQuery(searchCondition(s.DB.Model(&model.Submission{}), condition), "", c, pageSize).
+		Find(&submissions)
+	if result.Error != nil {
+		return nil, nil, errors.Wrap("submission_mapper.FindSubmissionsByCursor", result.Error)
+	}
+	n, page := util.KeysetPage(submissions, c, pageSize, func(i int) (time.Time, uint) {
+		return submissions[i].UpdatedAt, submissions[i].ID
+	})
+	return submissions[:n], page, nil
+}
+
+func searchCondition(db *gorm.DB, condition *SearchSubmissionCondition) *gorm.DB {
+	if condition == nil {
+		return db
+	}
+	if condition.Username != "" {
+		db = db.Where("username = ?", condition.Username)
+	}
+	if condition.ProblemId != 0 {
+		db = db.Where("problem_id = ?", condition.ProblemId)
+	}
+	if condition.Status != 0 {
+		db = db.Where("result = ?", condition.Status)
+	}
+	if condition.Language != 0 {
+		db = db.Where("language = ?", condition.Language)
+	}
+	return db
+}
+
 func (s *SubmissionMapperImpl) FindSubmissionsGroupByResult(condition *UserSubmissionCondition) ([]*model.Submission, error) {
 	var submissions []*model.Submission
 	result := s.DB.
@@ -179,7 +217,7 @@ func (s *SubmissionMapperImpl) FindSubmissionsGroupByResult(condition *UserSubmi
 		Group("result").
 		Find(&submissions)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("submission_mapper.FindSubmissionsGroupByResult", result.Error)
 	}
 	return submissions, nil
 }
@@ -192,7 +230,7 @@ func (s *SubmissionMapperImpl) FindSubmissionsByContestId(contestId uint, start
 		Where("created_at > ? and created_at < ?", start, end).
 		Find(&submission)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("submission_mapper.FindSubmissionsByContestId", result.Error)
 	}
 	return submission, nil
 }
@@ -0,0 +1,181 @@
+package mapper
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/language"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/submission_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/jinzhu/gorm"
+	"testing"
+	"time"
+)
+
+func TestSubmissionMapperImpl_AddOrModifySubmission(t *testing.T) {
+	store, f := setup(t)
+	submission := f.Submissions[0]
+	submission.TimeCost = 15
+	_, err := store.SubmissionMapper().AddOrModifySubmission(submission)
+	assertNoError(t, err)
+	found, err := store.SubmissionMapper().FindSubmissionById(submission.ID)
+	assertNoError(t, err)
+	if found.TimeCost != 15 || found.SubmissionCode.SourceCode == "" {
+		t.Fatalf("unexpected submission: %+v", found)
+	}
+	_, count, err := store.SubmissionMapper().FindSubmissions(1, 10, nil)
+	assertNoError(t, err)
+	if count != int32(len(f.Submissions)) {
+		t.Fatalf("existing submission should be modified in place, got %v rows", count)
+	}
+}
+
+func TestSubmissionMapperImpl_FindSubmissionById(t *testing.T) {
+	store, f := setup(t)
+	submission, err := store.SubmissionMapper().FindSubmissionById(f.Submissions[2].ID)
+	assertNoError(t, err)
+	if submission.Username != "carol" || submission.SubmissionCode.SubmissionID != submission.ID {
+		t.Fatalf("unexpected submission: %+v", submission)
+	}
+	if _, err = store.SubmissionMapper().FindSubmissionById(9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSubmissionMapperImpl_FindProblemGroupById(t *testing.T) {
+	store, f := setup(t)
+	groups, err := store.SubmissionMapper().FindProblemGroupById(f.Submissions[0].ID)
+	assertNoError(t, err)
+	if len(groups) != 1 || groups[0].RawProblemId != f.RawProblems[0].ID {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+}
+
+func TestSubmissionMapperImpl_FindSubmissions(t *testing.T) {
+	store, f := setup(t)
+	cases := []struct {
+		condition *submission_mapper.SearchSubmissionCondition
+		count     int32
+	}{
+		{nil, 4},
+		{&submission_mapper.SearchSubmissionCondition{Username: "bob"}, 2},
+		{&submission_mapper.SearchSubmissionCondition{ProblemId: f.Problems[0].ID}, 3},
+		{&submission_mapper.SearchSubmissionCondition{Status: 1}, 3},
+		{&submission_mapper.SearchSubmissionCondition{Language: language.CPP, Username: "carol"}, 2},
+	}
+	for _, c := range cases {
+		_, count, err := store.SubmissionMapper().FindSubmissions(1, 10, c.condition)
+		assertNoError(t, err)
+		if count != c.count {
+			t.Fatalf("condition %+v: expected %v, got %v", c.condition, c.count, count)
+		}
+	}
+	submissions, _, err := store.SubmissionMapper().FindSubmissions(1, 1, nil)
+	assertNoError(t, err)
+	if len(submissions) != 1 || submissions[0].ID != f.Submissions[3].ID {
+		t.Fatalf("expected latest submission first, got %+v", submissions)
+	}
+}
+
+func TestSubmissionMapperImpl_FindSubmissionsByCursor(t *testing.T) {
+	store, f := setup(t)
+	mapper := store.SubmissionMapper()
+	first, page, err := mapper.FindSubmissionsByCursor("", 2, nil)
+	assertNoError(t, err)
+	if len(first) != 2 || first[0].ID != f.Submissions[3].ID || first[1].ID != f.Submissions[2].ID {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	if page.Next == "" || page.Prev != "" {
+		t.Fatalf("unexpected cursors on first page: %+v", page)
+	}
+	//翻页期间插入的新记录排在最前 不影响后续页
+	_, err = mapper.AddOrModifySubmission(&model.Submission{ProblemId: f.Problems[0].ID, Username: "bob"})
+	assertNoError(t, err)
+	second, page, err := mapper.FindSubmissionsByCursor(page.Next, 2, nil)
+	assertNoError(t, err)
+	if len(second) != 2 || second[0].ID != f.Submissions[1].ID || second[1].ID != f.Submissions[0].ID {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+	if page.Next != "" || page.Prev == "" {
+		t.Fatalf("unexpected cursors on last page: %+v", page)
+	}
+	back, page, err := mapper.FindSubmissionsByCursor(page.Prev, 2, nil)
+	assertNoError(t, err)
+	if len(back) != 2 || back[0].ID != f.Submissions[3].ID || back[1].ID != f.Submissions[2].ID {
+		t.Fatalf("unexpected previous page: %+v", back)
+	}
+	if page.Prev == "" {
+		t.Fatalf("previous page should see the newly inserted submission: %+v", page)
+	}
+	carol, _, err := mapper.FindSubmissionsByCursor("", 10, &submission_mapper.SearchSubmissionCondition{Username: "carol"})
+	assertNoError(t, err)
+	if len(carol) != 2 {
+		t.Fatalf("expected 2 submissions of carol, got %+v", carol)
+	}
+	_, _, err = mapper.FindSubmissionsByCursor("not a cursor", 2, nil)
+	if !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestSubmissionMapperImpl_FindSubmissionsGroupByResult(t *testing.T) {
+	store, f := setup(t)
+	submissions, err := store.SubmissionMapper().FindSubmissionsGroupByResult(&submission_mapper.UserSubmissionCondition{
+		UserId:    f.Users[1].ID,
+		ProblemId: f.Problems[0].ID,
+	})
+	assertNoError(t, err)
+	if len(submissions) != 2 {
+		t.Fatalf("expected 2 distinct results, got %+v", submissions)
+	}
+}
+
+func TestSubmissionMapperImpl_FindSubmissionsByContestId(t *testing.T) {
+	store, f := setup(t)
+	now := time.Now()
+	submissions, err := store.SubmissionMapper().FindSubmissionsByContestId(f.Contests[0].ID, now.Add(-time.Hour), now.Add(time.Hour))
+	assertNoError(t, err)
+	if len(submissions) != 1 || submissions[0].ID != f.Submissions[3].ID {
+		t.Fatalf("unexpected contest submissions: %+v", submissions)
+	}
+	submissions, err = store.SubmissionMapper().FindSubmissionsByContestId(f.Contests[0].ID, now.Add(time.Hour), now.Add(2*time.Hour))
+	assertNoError(t, err)
+	if len(submissions) != 0 {
+		t.Fatalf("expected no submissions outside window, got %+v", submissions)
+	}
+}
+
+func TestSubmissionMapperImpl_UpdateSubmissionById(t *testing.T) {
+	store, f := setup(t)
+	submission, err := store.SubmissionMapper().UpdateSubmissionById(&model.Submission{
+		Model:      gorm.Model{ID: f.Submissions[1].ID},
+		TimeCost:   5,
+		MemoryCost: 5,
+		RealRunId:  "11111",
+	})
+	assertNoError(t, err)
+	if submission.RealRunId != "11111" || submission.Username != "bob" {
+		t.Fatalf("unexpected submission: %+v", submission)
+	}
+}
+
+func TestSubmissionMapperImpl_UpdateSubmissionCEInfoById(t *testing.T) {
+	store, f := setup(t)
+	id := f.Submissions[0].ID
+	assertNoError(t, store.SubmissionMapper().UpdateSubmissionCEInfoById(id, "error: expected ';'"))
+	assertNoError(t, store.SubmissionMapper().UpdateSubmissionCEInfoById(id, "error: expected '}'"))
+	var infos []*model.CompileInfo
+	assertNoError(t, store.DB.Where("submission_id = ?", id).Find(&infos).Error)
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 compile info, got %+v", infos)
+	}
+}
+
+func TestSubmissionMapperImpl_ResetSubmissionById(t *testing.T) {
+	store, f := setup(t)
+	id := f.Submissions[0].ID
+	assertNoError(t, store.SubmissionMapper().ResetSubmissionById(id))
+	submission, err := store.SubmissionMapper().FindSubmissionById(id)
+	assertNoError(t, err)
+	if submission.Result != 0 || submission.RemoteOJ != 0 || submission.RealRunId != "" {
+		t.Fatalf("submission not reset: %+v", submission)
+	}
+}
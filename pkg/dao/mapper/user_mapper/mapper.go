@@ -1,13 +1,16 @@
 package user_mapper
 
 import (
-	"fmt"
+	"context"
 	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
 	"github.com/ecnuvj/vhoj_db/pkg/util"
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
 type IUserMapper interface {
+	WithContext(ctx context.Context) IUserMapper
 	AddUser(*model.User) (*model.User, error)
 	AddUserRoleByRoleName(uint, string) (*model.Role, error)
 	AddUserRoleByRoleId(uint, uint) error
@@ -20,14 +23,26 @@ type IUserMapper interface {
 	FindUserByUsername(string) (*model.User, error)
 	FindUserRolesById(uint) ([]*model.Role, error)
 	FindAllUsers(int32, int32) ([]*model.User, int32, error)
+	FindAllUsersByCursor(cursor string, pageSize int32) ([]*model.User, *util.CursorPage, error)
 	FindRoleList() ([]*model.Role, error)
 	DeleteUserById(uint) error
 }
 
 var UserMapper IUserMapper
 
+func NewMapper(db *gorm.DB) IUserMapper {
+	return &UserMapperImpl{
+		DB: db,
+	}
+}
+
 func InitMapper(db *gorm.DB) {
-	UserMapper = &UserMapperImpl{DB: db}
+	UserMapper = NewMapper(db)
+}
+
+//返回绑定ctx的mapper 其所有查询都会随ctx取消或超时而中断
+func (u *UserMapperImpl) WithContext(ctx context.Context) IUserMapper {
+	return NewMapper(util.WithContext(u.DB, ctx))
 }
 
 type UserMapperImpl struct {
@@ -35,21 +50,22 @@ type UserMapperImpl struct {
 }
 
 func (u *UserMapperImpl) AddUser(user *model.User) (*model.User, error) {
-	tx := u.DB.Begin()
-	if err := tx.Create(user).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	for i, r := range user.Roles {
-		if rol, err := u.AddUserRoleByRoleName(user.ID, r.RoleName); err != nil {
-			tx.Rollback()
-			return nil, err
-		} else {
+	err := util.Transaction(u.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		txMapper := NewMapper(tx)
+		for i, r := range user.Roles {
+			rol, err := txMapper.AddUserRoleByRoleName(user.ID, r.RoleName)
+			if err != nil {
+				return err
+			}
 			user.Roles[i] = rol
 		}
-	}
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap("user_mapper.AddUser", err)
 	}
 	return user, nil
 }
@@ -61,11 +77,11 @@ func (u *UserMapperImpl) UpdateUser(user *model.User) (*model.User, error) {
 	}
 	result := u.DB.Model(&model.User{}).Update(user)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.UpdateUser", result.Error)
 	}
 	retUser, err := u.FindUserById(user.ID)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap("user_mapper.UpdateUser", err)
 	}
 	return retUser, nil
 }
@@ -77,7 +93,7 @@ func (u *UserMapperImpl) FindUsersByIds(userIds []uint) ([]*model.User, error) {
 		Model(&model.User{}).
 		Find(&users, userIds)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUsersByIds", result.Error)
 	}
 	for i, user := range users {
 		users[i].Roles, _ = u.FindUserRolesById(user.ID)
@@ -92,11 +108,11 @@ func (u *UserMapperImpl) FindUserByUsername(username string) (*model.User, error
 		Where("nickname = ?", username).
 		Find(user)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUserByUsername", result.Error)
 	}
 	result = u.DB.Model(user).Related(user.UserAuth)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUserByUsername", result.Error)
 	}
 	roles, _ := u.FindUserRolesById(user.ID)
 	user.Roles = roles
@@ -110,7 +126,7 @@ func (u *UserMapperImpl) FindUserById(userId uint) (*model.User, error) {
 	}
 	result := u.DB.Model(user).First(user).Related(user.UserAuth)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUserById", result.Error)
 	}
 	roles, _ := u.FindUserRolesById(userId)
 	user.Roles = roles
@@ -122,13 +138,16 @@ func (u *UserMapperImpl) AddUserRoleByRoleName(userId uint, roleName string) (*m
 		RoleName: roleName,
 	}
 	if err := u.DB.Model(&model.Role{}).Where("role_name = ?", roleName).First(role).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			u.DB.Create(role)
+		if !gorm.IsRecordNotFoundError(err) {
+			return nil, errors.Wrap("user_mapper.AddUserRoleByRoleName", err)
+		}
+		if err = u.DB.Create(role).Error; err != nil {
+			return nil, errors.Wrap("user_mapper.AddUserRoleByRoleName", err)
 		}
 	}
 	err := u.AddUserRoleByRoleId(userId, role.ID)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap("user_mapper.AddUserRoleByRoleName", err)
 	}
 	return role, nil
 }
@@ -140,7 +159,7 @@ func (u *UserMapperImpl) AddUserRoleByRoleId(userId uint, roleId uint) error {
 	}
 	result := u.DB.Create(userRole)
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("user_mapper.AddUserRoleByRoleId", result.Error)
 	}
 	return nil
 }
@@ -149,7 +168,7 @@ func (u *UserMapperImpl) FindUserRolesById(userId uint) ([]*model.Role, error) {
 	var userRoles []*model.UserRole
 	result := u.DB.Model(&model.UserRole{}).Where("user_id = ?", userId).Find(&userRoles)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUserRolesById", result.Error)
 	}
 	roleIds := make([]uint, len(userRoles))
 	for i, r := range userRoles {
@@ -158,58 +177,52 @@ func (u *UserMapperImpl) FindUserRolesById(userId uint) ([]*model.Role, error) {
 	var roles []*model.Role
 	result = u.DB.Model(&model.Role{}).Find(&roles, roleIds)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindUserRolesById", result.Error)
 	}
 	return roles, nil
 }
 
 //role id 必须给到
 func (u *UserMapperImpl) UpdateUserRoles(userId uint, roles []*model.Role) (*model.User, error) {
-	tx := u.DB.Begin()
-	if err := tx.Where("user_id = ?", userId).Delete(&model.UserRole{}).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	for _, r := range roles {
-		userRole := &model.UserRole{
-			UserId: userId,
-			RoleId: r.ID,
+	err := util.Transaction(u.DB, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&model.UserRole{}).Error; err != nil {
+			return err
 		}
-		if err := tx.Create(userRole).Error; err != nil {
-			tx.Rollback()
-			return nil, err
+		for _, r := range roles {
+			userRole := &model.UserRole{
+				UserId: userId,
+				RoleId: r.ID,
+			}
+			if err := tx.Create(userRole).Error; err != nil {
+				return err
+			}
 		}
-	}
-	err := tx.Commit().Error
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap("user_mapper.UpdateUserRoles", err)
 	}
 	return u.FindUserById(userId)
 }
 
 func (u *UserMapperImpl) DeleteUserById(userId uint) error {
-	tx := u.DB.Begin()
-	user := &model.User{
-		Model:    gorm.Model{ID: userId},
-		UserAuth: &model.UserAuth{},
-	}
-	if err := tx.First(user).Related(user.UserAuth).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err := tx.Delete(user).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err := tx.Delete(user.UserAuth).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err := tx.Where("user_id = ?", userId).Delete(&model.UserRole{}).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	return tx.Commit().Error
+	err := util.Transaction(u.DB, func(tx *gorm.DB) error {
+		user := &model.User{
+			Model:    gorm.Model{ID: userId},
+			UserAuth: &model.UserAuth{},
+		}
+		if err := tx.First(user).Related(user.UserAuth).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(user.UserAuth).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&model.UserRole{}).Error
+	})
+	return errors.Wrap("user_mapper.DeleteUserById", err)
 }
 
 func (u *UserMapperImpl) FindAllUsers(pageNo int32, pageSize int32) ([]*model.User, int32, error) {
@@ -224,7 +237,7 @@ func (u *UserMapperImpl) FindAllUsers(pageNo int32, pageSize int32) ([]*model.Us
 		Offset(offset).
 		Find(&users)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, 0, errors.Wrap("user_mapper.FindAllUsers", result.Error)
 	}
 	for i, user := range users {
 		users[i].Roles, _ = u.FindUserRolesById(user.ID)
@@ -232,28 +245,50 @@ func (u *UserMapperImpl) FindAllUsers(pageNo int32, pageSize int32) ([]*model.Us
 	return users, count, nil
 }
 
+//keyset分页 按updated_at desc, id desc排序 cursor为空时返回第一页
+func (u *UserMapperImpl) FindAllUsersByCursor(cursor string, pageSize int32) ([]*model.User, *util.CursorPage, error) {
+	c, err := util.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument("user_mapper.FindAllUsersByCursor", "%v", err)
+	}
+	var users []*model.User
+	result := util.KeysetQuery(u.DB.Model(&model.User{}).Preload("UserAuth"), "", c, pageSize).
+		Find(&users)
+	if result.Error != nil {
+		return nil, nil, errors.Wrap("user_mapper.FindAllUsersByCursor", result.Error)
+	}
+	n, page := util.KeysetPage(users, c, pageSize, func(i int) (time.Time, uint) {
+		return users[i].UpdatedAt, users[i].ID
+	})
+	users = users[:n]
+	for i, user := range users {
+		users[i].Roles, _ = u.FindUserRolesById(user.ID)
+	}
+	return users, page, nil
+}
+
 func (u *UserMapperImpl) AddUserSubmitCountById(userId uint) error {
 	if userId <= 0 {
-		return fmt.Errorf("user id is incorrect")
+		return errors.InvalidArgument("user_mapper.AddUserSubmitCountById", "user id is incorrect")
 	}
 	result := u.DB.
 		Model(&model.User{Model: gorm.Model{ID: userId}}).
 		Update("submitted", gorm.Expr("submitted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("user_mapper.AddUserSubmitCountById", result.Error)
 	}
 	return nil
 }
 
 func (u *UserMapperImpl) AddUserAcceptCountById(userId uint) error {
 	if userId <= 0 {
-		return fmt.Errorf("problem id is incorrect")
+		return errors.InvalidArgument("user_mapper.AddUserAcceptCountById", "user id is incorrect")
 	}
 	result := u.DB.
 		Model(&model.User{Model: gorm.Model{ID: userId}}).
 		Update("accepted", gorm.Expr("accepted + ?", 1))
 	if result.Error != nil {
-		return result.Error
+		return errors.Wrap("user_mapper.AddUserAcceptCountById", result.Error)
 	}
 	return nil
 }
@@ -264,7 +299,7 @@ func (u *UserMapperImpl) FindRoleList() ([]*model.Role, error) {
 		Model(&model.Role{}).
 		Find(&roles)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, errors.Wrap("user_mapper.FindRoleList", result.Error)
 	}
 	return roles, nil
 }
@@ -0,0 +1,194 @@
+package mapper
+
+import (
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/ecnuvj/vhoj_db/pkg/errors"
+	"github.com/jinzhu/gorm"
+	"testing"
+)
+
+func TestUserMapperImpl_AddUser(t *testing.T) {
+	store, _ := setup(t)
+	user, err := store.UserMapper().AddUser(&model.User{
+		UserAuth: &model.UserAuth{Password: "123456"},
+		Nickname: "bqx",
+		Roles:    []*model.Role{{RoleName: "normal"}, {RoleName: "judge"}},
+	})
+	assertNoError(t, err)
+	if user.ID == 0 || user.UserAuth.UserID != user.ID {
+		t.Fatalf("user not persisted: %+v", user)
+	}
+	roles, err := store.UserMapper().FindUserRolesById(user.ID)
+	assertNoError(t, err)
+	if len(roles) != 2 {
+		t.Fatalf("expected 2 roles, got %v", len(roles))
+	}
+	_, err = store.UserMapper().AddUser(&model.User{Nickname: "bqx"})
+	if !errors.Is(err, errors.ErrDuplicate) {
+		t.Fatalf("expected duplicate nickname error, got %v", err)
+	}
+}
+
+func TestUserMapperImpl_UpdateUser(t *testing.T) {
+	store, f := setup(t)
+	bob := f.Users[1]
+	user, err := store.UserMapper().UpdateUser(&model.User{
+		Model:    gorm.Model{ID: bob.ID},
+		UserAuth: &model.UserAuth{Password: "ignored without id"},
+		Email:    "bob@ecnu.edu.cn",
+	})
+	assertNoError(t, err)
+	if user.Email != "bob@ecnu.edu.cn" || user.Nickname != "bob" {
+		t.Fatalf("unexpected user after update: %+v", user)
+	}
+	if user.UserAuth.Password != "bob-password" {
+		t.Fatalf("user auth without id should not be updated: %+v", user.UserAuth)
+	}
+}
+
+func TestUserMapperImpl_FindUsersByIds(t *testing.T) {
+	store, f := setup(t)
+	users, err := store.UserMapper().FindUsersByIds([]uint{f.Users[0].ID, f.Users[2].ID})
+	assertNoError(t, err)
+	if len(users) != 2 || users[0].Nickname != "alice" || users[1].Nickname != "carol" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+	if len(users[0].Roles) != 1 || users[0].Roles[0].RoleName != "admin" {
+		t.Fatalf("roles not loaded: %+v", users[0].Roles)
+	}
+}
+
+func TestUserMapperImpl_FindUserById(t *testing.T) {
+	store, f := setup(t)
+	user, err := store.UserMapper().FindUserById(f.Users[1].ID)
+	assertNoError(t, err)
+	if user.Nickname != "bob" || user.UserAuth.Password != "bob-password" || len(user.Roles) != 1 {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	_, err = store.UserMapper().FindUserById(9999)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestUserMapperImpl_FindUserByUsername(t *testing.T) {
+	store, f := setup(t)
+	user, err := store.UserMapper().FindUserByUsername("carol")
+	assertNoError(t, err)
+	if user.ID != f.Users[2].ID || user.UserAuth.Password != "carol-password" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	_, err = store.UserMapper().FindUserByUsername("nobody")
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestUserMapperImpl_AddUserRoleByRoleName(t *testing.T) {
+	store, f := setup(t)
+	role, err := store.UserMapper().AddUserRoleByRoleName(f.Users[2].ID, "admin")
+	assertNoError(t, err)
+	if role.ID != f.Roles[0].ID {
+		t.Fatalf("existing role should be reused: %+v", role)
+	}
+	role, err = store.UserMapper().AddUserRoleByRoleName(f.Users[2].ID, "setter")
+	assertNoError(t, err)
+	if role.ID == 0 {
+		t.Fatal("new role not created")
+	}
+	roles, err := store.UserMapper().FindUserRolesById(f.Users[2].ID)
+	assertNoError(t, err)
+	if len(roles) != 3 {
+		t.Fatalf("expected 3 roles, got %+v", roles)
+	}
+}
+
+func TestUserMapperImpl_AddUserRoleByRoleId(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.UserMapper().AddUserRoleByRoleId(f.Users[1].ID, f.Roles[0].ID))
+	roles, err := store.UserMapper().FindUserRolesById(f.Users[1].ID)
+	assertNoError(t, err)
+	if len(roles) != 2 {
+		t.Fatalf("expected 2 roles, got %+v", roles)
+	}
+}
+
+func TestUserMapperImpl_UpdateUserRoles(t *testing.T) {
+	store, f := setup(t)
+	user, err := store.UserMapper().UpdateUserRoles(f.Users[0].ID, []*model.Role{f.Roles[1]})
+	assertNoError(t, err)
+	if len(user.Roles) != 1 || user.Roles[0].RoleName != "normal" {
+		t.Fatalf("roles not replaced: %+v", user.Roles)
+	}
+}
+
+func TestUserMapperImpl_FindAllUsers(t *testing.T) {
+	store, _ := setup(t)
+	users, count, err := store.UserMapper().FindAllUsers(2, 2)
+	assertNoError(t, err)
+	if count != 3 || len(users) != 1 || users[0].Nickname != "carol" {
+		t.Fatalf("unexpected page: count=%v users=%+v", count, users)
+	}
+	if users[0].UserAuth == nil || len(users[0].Roles) != 1 {
+		t.Fatalf("associations not loaded: %+v", users[0])
+	}
+}
+
+func TestUserMapperImpl_FindAllUsersByCursor(t *testing.T) {
+	store, f := setup(t)
+	users, page, err := store.UserMapper().FindAllUsersByCursor("", 2)
+	assertNoError(t, err)
+	if len(users) != 2 || users[0].ID != f.Users[2].ID || users[0].UserAuth == nil || len(users[0].Roles) != 1 {
+		t.Fatalf("unexpected first page: %+v", users)
+	}
+	users, page, err = store.UserMapper().FindAllUsersByCursor(page.Next, 2)
+	assertNoError(t, err)
+	if len(users) != 1 || users[0].ID != f.Users[0].ID || page.Next != "" {
+		t.Fatalf("unexpected last page: %+v %+v", users, page)
+	}
+}
+
+func TestUserMapperImpl_SubmitAcceptCount(t *testing.T) {
+	store, f := setup(t)
+	bob := f.Users[1]
+	assertNoError(t, store.UserMapper().AddUserSubmitCountById(bob.ID))
+	assertNoError(t, store.UserMapper().AddUserSubmitCountById(bob.ID))
+	assertNoError(t, store.UserMapper().AddUserAcceptCountById(bob.ID))
+	user, err := store.UserMapper().FindUserById(bob.ID)
+	assertNoError(t, err)
+	if user.Submitted != 2 || user.Accepted != 1 {
+		t.Fatalf("unexpected counters: submitted=%v accepted=%v", user.Submitted, user.Accepted)
+	}
+	if err = store.UserMapper().AddUserSubmitCountById(0); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if err = store.UserMapper().AddUserAcceptCountById(0); !errors.Is(err, errors.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestUserMapperImpl_FindRoleList(t *testing.T) {
+	store, _ := setup(t)
+	roles, err := store.UserMapper().FindRoleList()
+	assertNoError(t, err)
+	if len(roles) != 2 {
+		t.Fatalf("expected 2 roles, got %+v", roles)
+	}
+}
+
+func TestUserMapperImpl_DeleteUserById(t *testing.T) {
+	store, f := setup(t)
+	assertNoError(t, store.UserMapper().DeleteUserById(f.Users[2].ID))
+	_, err := store.UserMapper().FindUserById(f.Users[2].ID)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected deleted user to be not found, got %v", err)
+	}
+	roles, err := store.UserMapper().FindUserRolesById(f.Users[2].ID)
+	assertNoError(t, err)
+	if len(roles) != 0 {
+		t.Fatalf("user roles not removed: %+v", roles)
+	}
+	if err = store.UserMapper().DeleteUserById(9999); !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//回填只使用本文件中的解析逻辑 它们是对应迁移发布时problem_mapper与similarity的副本
+//之后对原实现的修改(包括运行时注册的解析器)不影响已发布迁移的结果
+
+const backfillBatchSize = 500
+
+//按id分批解析已有原始题目(含软删除的)的时空限制
+//列不存在时(如migrate plan只记录不执行AutoMigrate)跳过
+func backfillLimits(db *gorm.DB) error {
+	if !db.Dialect().HasColumn(db.NewScope(&rawProblemV7{}).TableName(), "time_limit_ms") {
+		return nil
+	}
+	var lastId uint
+	for {
+		var rawProblems []*rawProblemLimitsV7
+		result := db.
+			Where("id > ? and (time_limit_ms = 0 or memory_limit_kb = 0)", lastId).
+			Order("id").
+			Limit(backfillBatchSize).
+			Find(&rawProblems)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(rawProblems) == 0 {
+			return nil
+		}
+		for _, rawProblem := range rawProblems {
+			lastId = rawProblem.ID
+			timeLimitMs, memoryLimitKb := limitsV7(rawProblem)
+			if timeLimitMs > 0 {
+				rawProblem.TimeLimitMs = timeLimitMs
+			}
+			if memoryLimitKb > 0 {
+				rawProblem.MemoryLimitKb = memoryLimitKb
+			}
+			result = db.
+				Model(rawProblem).
+				UpdateColumns(map[string]interface{}{
+					"time_limit_ms":   rawProblem.TimeLimitMs,
+					"memory_limit_kb": rawProblem.MemoryLimitKb,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+}
+
+//把带有分隔标记的样例文本拆成样例 已有样例的题目跳过
+//同一题目的样例在一个事务中写入 中断后重新执行不会留下不完整的样例
+//表不存在时(如migrate plan只记录不执行AutoMigrate)跳过
+func splitSamples(db *gorm.DB) error {
+	if !db.HasTable(&problemSampleV8{}) {
+		return nil
+	}
+	var lastId uint
+	for {
+		var rawProblems []*rawProblemSamplesV8
+		result := db.
+			Where("id > ?", lastId).
+			Where("sample_input LIKE ? or sample_output LIKE ?", "%"+sampleBoundaryV8+"%", "%"+sampleBoundaryV8+"%").
+			Where("id not in ?", db.Table("problem_samples").Select("raw_problem_id").SubQuery()).
+			Order("id").
+			Limit(backfillBatchSize).
+			Find(&rawProblems)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(rawProblems) == 0 {
+			return nil
+		}
+		for _, rawProblem := range rawProblems {
+			lastId = rawProblem.ID
+			samples := splitSamplesV8(rawProblem)
+			err := util.Transaction(db, func(tx *gorm.DB) error {
+				for _, sample := range samples {
+					if err := tx.Create(sample).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//为还没有指纹的原始题目生成指纹 指纹与它的LSH桶在一个事务中写入
+//表不存在时(如migrate plan只记录不执行AutoMigrate)跳过
+func backfillFingerprints(db *gorm.DB) error {
+	if !db.HasTable(&problemFingerprintV11{}) {
+		return nil
+	}
+	var lastId uint
+	for {
+		var rawProblems []*rawProblemStatementV11
+		result := db.
+			Where("id > ?", lastId).
+			Where("id not in ?", db.Table("problem_fingerprints").Select("raw_problem_id").SubQuery()).
+			Order("id").
+			Limit(backfillBatchSize).
+			Find(&rawProblems)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(rawProblems) == 0 {
+			return nil
+		}
+		for _, rawProblem := range rawProblems {
+			lastId = rawProblem.ID
+			fingerprint, bands := fingerprintV11(rawProblem)
+			err := util.Transaction(db, func(tx *gorm.DB) error {
+				if err := tx.Create(fingerprint).Error; err != nil {
+					return err
+				}
+				for _, band := range bands {
+					if err := tx.Create(band).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//迁移7 时空限制解析
+
+var limitPatternV7 = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([a-zA-Z]*)`)
+
+var timeUnitsV7 = map[string]float64{
+	"":             1,
+	"ms":           1,
+	"msec":         1,
+	"millisecond":  1,
+	"milliseconds": 1,
+	"s":            1000,
+	"sec":          1000,
+	"secs":         1000,
+	"second":       1000,
+	"seconds":      1000,
+}
+
+var memoryUnitsV7 = map[string]float64{
+	"":          1,
+	"k":         1,
+	"kb":        1,
+	"kib":       1,
+	"kilobyte":  1,
+	"kilobytes": 1,
+	"m":         1024,
+	"mb":        1024,
+	"mib":       1024,
+	"megabyte":  1024,
+	"megabytes": 1024,
+	"g":         1024 * 1024,
+	"gb":        1024 * 1024,
+	"gib":       1024 * 1024,
+	"b":         1.0 / 1024,
+	"byte":      1.0 / 1024,
+	"bytes":     1.0 / 1024,
+}
+
+//HDU的限制形如"2000/1000 MS (Java/Others)" 取斜线后其他语言的限制 其他oj直接解析
+func limitsV7(rawProblem *rawProblemLimitsV7) (int32, int32) {
+	timeLimit, memoryLimit := rawProblem.TimeLimit, rawProblem.MemoryLimit
+	if rawProblem.RemoteOJ == remote_oj.HDU {
+		timeLimit, memoryLimit = hduOthersLimitV7(timeLimit), hduOthersLimitV7(memoryLimit)
+	}
+	return parseLimitV7(timeLimit, timeUnitsV7), parseLimitV7(memoryLimit, memoryUnitsV7)
+}
+
+func hduOthersLimitV7(limit string) string {
+	if i := strings.Index(limit, "("); i >= 0 {
+		limit = limit[:i]
+	}
+	if i := strings.LastIndex(limit, "/"); i >= 0 {
+		limit = limit[i+1:]
+	}
+	return limit
+}
+
+//取第一个单位可识别的数值 无法解析时返回0
+func parseLimitV7(limit string, units map[string]float64) int32 {
+	for _, match := range limitPatternV7.FindAllStringSubmatch(limit, -1) {
+		scale, ok := units[strings.ToLower(match[2])]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		value = math.Round(value * scale)
+		if value <= 0 || value > math.MaxInt32 {
+			return 0
+		}
+		return int32(value)
+	}
+	return 0
+}
+
+//迁移8 样例拆分
+
+const (
+	sampleBoundaryV8      = "##sample##"
+	explanationBoundaryV8 = "##explanation##"
+)
+
+//没有标记或输入输出组数不一致时返回nil
+func splitSamplesV8(rawProblem *rawProblemSamplesV8) []*problemSampleV8 {
+	if !strings.Contains(rawProblem.SampleInput, sampleBoundaryV8) && !strings.Contains(rawProblem.SampleOutput, sampleBoundaryV8) {
+		return nil
+	}
+	inputs := splitOnBoundaryV8(rawProblem.SampleInput, sampleBoundaryV8)
+	outputs := splitOnBoundaryV8(rawProblem.SampleOutput, sampleBoundaryV8)
+	if len(inputs) != len(outputs) {
+		return nil
+	}
+	samples := make([]*problemSampleV8, len(inputs))
+	for i := range inputs {
+		sample := &problemSampleV8{RawProblemId: rawProblem.ID, SampleOrder: int32(i + 1), Input: inputs[i], Output: outputs[i]}
+		if parts := splitOnBoundaryV8(outputs[i], explanationBoundaryV8); len(parts) == 2 {
+			sample.Output, sample.Explanation = parts[0], parts[1]
+		}
+		samples[i] = sample
+	}
+	return samples
+}
+
+//以独占一行的boundary切分 去掉每段首尾的换行 开头的空段忽略
+func splitOnBoundaryV8(text string, boundary string) []string {
+	parts := make([]string, 0)
+	current := make([]string, 0)
+	flush := func() {
+		parts = append(parts, strings.Trim(strings.Join(current, "\n"), "\r\n"))
+		current = current[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == boundary {
+			if len(parts) > 0 || len(current) > 0 {
+				flush()
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return parts
+}
+
+//迁移11 题面指纹
+
+const (
+	numHashesV11   = 64
+	shingleSizeV11 = 5
+	bandsV11       = 16
+	rowsV11        = numHashesV11 / bandsV11
+)
+
+var htmlTagV11 = regexp.MustCompile(`<[^>]*>`)
+
+func fingerprintV11(rawProblem *rawProblemStatementV11) (*problemFingerprintV11, []*problemFingerprintBandV11) {
+	contentHash := rawProblem.ContentHash
+	if contentHash == "" {
+		contentHash = statementHashV11(rawProblem)
+	}
+	signature := minHashV11(rawProblem.Title + "\n" + rawProblem.Description)
+	fingerprint := &problemFingerprintV11{
+		RawProblemId: rawProblem.ID,
+		ContentHash:  contentHash,
+		Signature:    encodeSignatureV11(signature),
+	}
+	sampleInput, sampleOutput := normalizeV11(rawProblem.SampleInput), normalizeV11(rawProblem.SampleOutput)
+	if sampleInput != "" || sampleOutput != "" {
+		sum := sha256.Sum256([]byte(sampleInput + "\x00" + sampleOutput))
+		fingerprint.SampleHash = hex.EncodeToString(sum[:])
+	}
+	bands := make([]*problemFingerprintBandV11, 0, bandsV11)
+	for band, bucket := range bucketsV11(signature) {
+		bands = append(bands, &problemFingerprintBandV11{RawProblemId: rawProblem.ID, Band: int32(band), Bucket: bucket})
+	}
+	return fingerprint, bands
+}
+
+//题面内容的sha256 字段以列名和长度分隔
+func statementHashV11(rawProblem *rawProblemStatementV11) string {
+	fields := []struct{ column, value string }{
+		{"title", rawProblem.Title},
+		{"description", rawProblem.Description},
+		{"sample_input", rawProblem.SampleInput},
+		{"sample_output", rawProblem.SampleOutput},
+		{"input", rawProblem.Input},
+		{"output", rawProblem.Output},
+		{"hint", rawProblem.Hint},
+		{"time_limit", rawProblem.TimeLimit},
+		{"memory_limit", rawProblem.MemoryLimit},
+		{"source", rawProblem.Source},
+	}
+	h := sha256.New()
+	for _, f := range fields {
+		h.Write([]byte(f.column))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(len(f.value))))
+		h.Write([]byte{0})
+		h.Write([]byte(f.value))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//去掉html标签 转小写 合并空白
+func normalizeV11(text string) string {
+	text = htmlTagV11.ReplaceAllString(text, " ")
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), unicode.IsSpace), " ")
+}
+
+//空文本返回nil
+func minHashV11(text string) []uint64 {
+	runes := []rune(normalizeV11(text))
+	shingles := make(map[uint64]struct{})
+	if len(runes) > 0 && len(runes) < shingleSizeV11 {
+		shingles[hashStringV11(string(runes))] = struct{}{}
+	}
+	for i := 0; i+shingleSizeV11 <= len(runes); i++ {
+		shingles[hashStringV11(string(runes[i:i+shingleSizeV11]))] = struct{}{}
+	}
+	if len(shingles) == 0 {
+		return nil
+	}
+	signature := make([]uint64, numHashesV11)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for shingle := range shingles {
+		for i := range signature {
+			if h := mixV11(shingle ^ seedsV11[i]); h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+	return signature
+}
+
+func bucketsV11(signature []uint64) []string {
+	if len(signature) != numHashesV11 {
+		return nil
+	}
+	buckets := make([]string, bandsV11)
+	buf := make([]byte, 8)
+	for band := 0; band < bandsV11; band++ {
+		h := fnv.New64a()
+		for _, v := range signature[band*rowsV11 : (band+1)*rowsV11] {
+			binary.LittleEndian.PutUint64(buf, v)
+			h.Write(buf)
+		}
+		buckets[band] = fmt.Sprintf("%016x", h.Sum64())
+	}
+	return buckets
+}
+
+func encodeSignatureV11(signature []uint64) string {
+	buf := make([]byte, 8*len(signature))
+	for i, v := range signature {
+		binary.LittleEndian.PutUint64(buf[8*i:], v)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func hashStringV11(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func mixV11(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+var seedsV11 = func() []uint64 {
+	seeds := make([]uint64, numHashesV11)
+	for i := range seeds {
+		seeds[i] = mixV11(uint64(i + 1))
+	}
+	return seeds
+}()
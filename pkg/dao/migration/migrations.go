@@ -0,0 +1,257 @@
+package migration
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+//所有版本化迁移 新增迁移只能追加 不能修改已发布的版本
+//表结构与数据回填都只使用schema.go中对应版本的快照 回填用到的解析逻辑见backfill.go
+var Migrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "create_initial_tables",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(initialTables()...).Error
+		},
+		Down: func(db *gorm.DB) error {
+			tables := initialTables()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := db.DropTableIfExists(tables[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_keyset_pagination_indexes",
+		Up: func(db *gorm.DB) error {
+			for _, index := range keysetIndexes() {
+				if err := addIndex(db, index.model, index.name, "updated_at", "id"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			for _, index := range keysetIndexes() {
+				if err := removeIndex(db, index.model, index.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_raw_problem_fulltext_index",
+		//只有mysql支持FULLTEXT索引 其他数据库的全文检索退化为LIKE
+		//ngram分词器使中文题面也能被检索 MariaDB与MySQL 5.7.6之前没有ngram 不建索引同样退化为LIKE
+		Up: func(db *gorm.DB) error {
+			if db.Dialect().GetName() != "mysql" || db.Dialect().HasIndex("raw_problems", "ft_raw_problems_statement") {
+				return nil
+			}
+			supported, err := hasNgramParser(db)
+			if err != nil || !supported {
+				return err
+			}
+			return db.Exec("ALTER TABLE raw_problems ADD FULLTEXT INDEX ft_raw_problems_statement " +
+				"(title, description, input, output, hint, source) WITH PARSER ngram").Error
+		},
+		Down: func(db *gorm.DB) error {
+			if db.Dialect().GetName() != "mysql" || !db.Dialect().HasIndex("raw_problems", "ft_raw_problems_statement") {
+				return nil
+			}
+			return db.Exec("ALTER TABLE raw_problems DROP INDEX ft_raw_problems_statement").Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_problem_tags",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&tagV4{}, &problemTagV4{}, &rawProblemV4{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.DropTableIfExists(&problemTagV4{}, &tagV4{}).Error; err != nil {
+				return err
+			}
+			return dropColumn(db, &rawProblemV4{}, "remote_tags")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_problem_difficulty",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&problemV5{}, &problemSolverV5{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.DropTableIfExists(&problemSolverV5{}).Error; err != nil {
+				return err
+			}
+			if err := removeIndex(db, &problemV5{}, "idx_problems_difficulty"); err != nil {
+				return err
+			}
+			for _, column := range []string{"difficulty", "solvers", "solver_strength"} {
+				if err := dropColumn(db, &problemV5{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_raw_problem_revisions",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&rawProblemV6{}, &rawProblemRevisionV6{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.DropTableIfExists(&rawProblemRevisionV6{}).Error; err != nil {
+				return err
+			}
+			return dropColumn(db, &rawProblemV6{}, "content_hash")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_raw_problem_numeric_limits",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&rawProblemV7{}).Error; err != nil {
+				return err
+			}
+			return backfillLimits(db)
+		},
+		Down: func(db *gorm.DB) error {
+			for _, name := range []string{"idx_raw_problems_time_limit_ms", "idx_raw_problems_memory_limit_kb"} {
+				if err := removeIndex(db, &rawProblemV7{}, name); err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"time_limit_ms", "memory_limit_kb"} {
+				if err := dropColumn(db, &rawProblemV7{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_problem_samples",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&problemSampleV8{}).Error; err != nil {
+				return err
+			}
+			return splitSamples(db)
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&problemSampleV8{}).Error
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_problem_assets",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&problemAssetV9{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&problemAssetV9{}).Error
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_raw_problem_translations",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&rawProblemTranslationV10{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&rawProblemTranslationV10{}).Error
+		},
+	},
+	{
+		Version: 11,
+		Name:    "add_group_suggestions",
+		Up: func(db *gorm.DB) error {
+			err := db.AutoMigrate(&problemFingerprintV11{}, &problemFingerprintBandV11{}, &groupSuggestionV11{}).Error
+			if err != nil {
+				return err
+			}
+			return backfillFingerprints(db)
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&groupSuggestionV11{}, &problemFingerprintBandV11{}, &problemFingerprintV11{}).Error
+		},
+	},
+	{
+		Version: 12,
+		Name:    "add_problem_group_health",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&problemGroupV12{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			for _, column := range []string{"remote_success", "remote_failure", "consecutive_failures", "last_success_at", "last_failure_at"} {
+				if err := dropColumn(db, &problemGroupV12{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 13,
+		Name:    "add_problem_status_index",
+		Up: func(db *gorm.DB) error {
+			return addIndex(db, &problemV1{}, "idx_problems_status", "status")
+		},
+		Down: func(db *gorm.DB) error {
+			return removeIndex(db, &problemV1{}, "idx_problems_status")
+		},
+	},
+}
+
+//查询mysql是否启用了ngram全文分词插件
+func hasNgramParser(db *gorm.DB) (bool, error) {
+	var count int
+	err := db.Raw("SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME = 'ngram' AND PLUGIN_STATUS = 'ACTIVE'").
+		Row().
+		Scan(&count)
+	return count > 0, err
+}
+
+//sqlite不支持DROP COLUMN 保留该列 列不存在时同样跳过
+func dropColumn(db *gorm.DB, value interface{}, column string) error {
+	if db.Dialect().GetName() == "sqlite3" || !db.Dialect().HasColumn(db.NewScope(value).TableName(), column) {
+		return nil
+	}
+	return db.Model(value).DropColumn(column).Error
+}
+
+type tableIndex struct {
+	model interface{}
+	name  string
+}
+
+//keyset分页按(updated_at, id)排序与比较
+func keysetIndexes() []tableIndex {
+	return []tableIndex{
+		{&submissionV1{}, "idx_submissions_updated_at_id"},
+		{&problemV1{}, "idx_problems_updated_at_id"},
+		{&userV1{}, "idx_users_updated_at_id"},
+	}
+}
+
+//索引已存在时跳过 使迁移可以在已有索引的库上重复执行
+func addIndex(db *gorm.DB, value interface{}, name string, columns ...string) error {
+	if db.Dialect().HasIndex(db.NewScope(value).TableName(), name) {
+		return nil
+	}
+	return db.Model(value).AddIndex(name, columns...).Error
+}
+
+func removeIndex(db *gorm.DB, value interface{}, name string) error {
+	if !db.Dialect().HasIndex(db.NewScope(value).TableName(), name) {
+		return nil
+	}
+	return db.Model(value).RemoveIndex(name).Error
+}
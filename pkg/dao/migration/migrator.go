@@ -0,0 +1,279 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/util"
+	"github.com/jinzhu/gorm"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	lockId              = 1
+	defaultLockTimeout  = 2 * time.Minute
+	defaultLockTTL      = 10 * time.Minute
+	defaultLockInterval = 500 * time.Millisecond
+)
+
+//一次版本化的表结构变更 Version需全局唯一且递增 Down为nil表示不可回滚
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(db *gorm.DB) error
+	Down    func(db *gorm.DB) error
+}
+
+//已执行的迁移记录
+type SchemaMigration struct {
+	Version   uint `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+//迁移锁 只有id=1一行 插入成功即持有锁 避免多个服务同时启动时重复迁移
+type SchemaMigrationLock struct {
+	ID       uint `gorm:"primary_key;auto_increment:false"`
+	Owner    string
+	LockedAt time.Time
+}
+
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	DB          *gorm.DB
+	Migrations  []*Migration
+	LockTimeout time.Duration
+	//超过LockTTL未刷新的锁视为进程已崩溃 可被抢占 持锁期间每LockTTL/3刷新一次
+	LockTTL time.Duration
+
+	owner         string
+	stopHeartbeat func()
+	//非0表示心跳发现锁已被抢占
+	lockLost int32
+}
+
+//同一进程内的多个Migrator也使用不同的owner
+var lockSeq uint32
+
+func NewMigrator(db *gorm.DB, migrations []*Migration) *Migrator {
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return &Migrator{
+		DB:          db,
+		Migrations:  sorted,
+		LockTimeout: defaultLockTimeout,
+		LockTTL:     defaultLockTTL,
+	}
+}
+
+func (m *Migrator) ensureTables() error {
+	for _, table := range []interface{}{&SchemaMigration{}, &SchemaMigrationLock{}} {
+		if err := m.DB.AutoMigrate(table).Error; err != nil {
+			//另一个进程可能刚好建了表
+			if !m.DB.HasTable(table) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) lock() error {
+	if err := m.ensureTables(); err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%v:%v:%v", hostname, os.Getpid(), atomic.AddUint32(&lockSeq, 1))
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		m.DB.
+			Where("id = ? and locked_at < ?", lockId, time.Now().Add(-m.LockTTL)).
+			Delete(&SchemaMigrationLock{})
+		err := m.DB.Create(&SchemaMigrationLock{ID: lockId, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			m.owner = owner
+			m.startHeartbeat()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquire migration lock timeout: %v", err)
+		}
+		time.Sleep(defaultLockInterval)
+	}
+}
+
+//持锁期间定期刷新locked_at 耗时较长的迁移不会被当作崩溃而被抢占锁
+func (m *Migrator) startHeartbeat() {
+	interval := m.LockTTL / 3
+	if interval <= 0 {
+		interval = defaultLockTTL / 3
+	}
+	atomic.StoreInt32(&m.lockLost, 0)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	m.stopHeartbeat = func() {
+		close(stop)
+		<-done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				result := m.DB.
+					Model(&SchemaMigrationLock{}).
+					Where("id = ? and owner = ?", lockId, m.owner).
+					UpdateColumn("locked_at", time.Now())
+				if result.Error == nil && result.RowsAffected == 0 {
+					atomic.StoreInt32(&m.lockLost, 1)
+				}
+			}
+		}
+	}()
+}
+
+//锁已被其他进程抢占时不能继续执行迁移
+func (m *Migrator) checkLock() error {
+	if atomic.LoadInt32(&m.lockLost) != 0 {
+		return fmt.Errorf("migration lock %v was taken over by another process", m.owner)
+	}
+	return nil
+}
+
+//只释放自己持有的锁
+func (m *Migrator) unlock() error {
+	m.stopHeartbeat()
+	return m.DB.Where("id = ? and owner = ?", lockId, m.owner).Delete(&SchemaMigrationLock{}).Error
+}
+
+func (m *Migrator) appliedMigrations() (map[uint]*SchemaMigration, error) {
+	var records []*SchemaMigration
+	if err := m.DB.Model(&SchemaMigration{}).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]*SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+//postgres与sqlite的DDL可以回滚 迁移与它的执行记录在同一事务中提交 中断时整体回滚
+//mysql的DDL会隐式提交 只能依次执行 迁移需保证可以重复执行
+func (m *Migrator) apply(fn func(tx *gorm.DB) error) error {
+	if m.DB.Dialect().GetName() == "mysql" {
+		return fn(m.DB)
+	}
+	return util.Transaction(m.DB, fn)
+}
+
+//按版本顺序执行所有未执行的迁移
+func (m *Migrator) Up() error {
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err = m.checkLock(); err != nil {
+			return err
+		}
+		err = m.apply(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return fmt.Errorf("migration %v_%v up: %v", migration.Version, migration.Name, err)
+			}
+			record := &SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}
+			return tx.Create(record).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//回滚最近执行的n个迁移
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for i := len(m.Migrations) - 1; i >= 0 && n > 0; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return fmt.Errorf("migration %v_%v is irreversible", migration.Version, migration.Name)
+		}
+		if err = m.checkLock(); err != nil {
+			return err
+		}
+		err = m.apply(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return fmt.Errorf("migration %v_%v down: %v", migration.Version, migration.Name, err)
+			}
+			return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
+
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	if err := m.ensureTables(); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*MigrationStatus, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := &MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,338 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/mapper/problem_mapper"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type migrationTestTable struct {
+	ID   uint
+	Name string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	dir, err := ioutil.TempDir("", "vhoj_db_migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "vhoj.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version: 2,
+			Name:    "seed",
+			Up: func(db *gorm.DB) error {
+				return db.Create(&migrationTestTable{Name: "seed"}).Error
+			},
+			Down: func(db *gorm.DB) error {
+				return db.Where("name = ?", "seed").Delete(&migrationTestTable{}).Error
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create_table",
+			Up: func(db *gorm.DB) error {
+				return db.AutoMigrate(&migrationTestTable{}).Error
+			},
+			Down: func(db *gorm.DB) error {
+				return db.DropTableIfExists(&migrationTestTable{}).Error
+			},
+		},
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db, testMigrations())
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	//重复执行不应重复迁移
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	var count int
+	db.Model(&migrationTestTable{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 seeded row, got %v", count)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Version != 1 || !statuses[0].Applied || !statuses[1].Applied {
+		t.Fatalf("unexpected status: %+v", statuses)
+	}
+	if err = migrator.Down(1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	statuses, _ = migrator.Status()
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Fatalf("expected only version 1 applied: %+v %+v", statuses[0], statuses[1])
+	}
+	db.Model(&migrationTestTable{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected seed rolled back, got %v rows", count)
+	}
+}
+
+//sqlite的DDL可以回滚 失败的迁移不留下表也不留下执行记录
+func TestMigratorUpRollsBackFailedMigration(t *testing.T) {
+	db := openTestDB(t)
+	failing := []*Migration{{
+		Version: 1,
+		Name:    "create_then_fail",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&migrationTestTable{}).Error; err != nil {
+				return err
+			}
+			return fmt.Errorf("boom")
+		},
+	}}
+	if err := NewMigrator(db, failing).Up(); err == nil {
+		t.Fatalf("expected up to fail")
+	}
+	if db.HasTable(&migrationTestTable{}) {
+		t.Fatalf("expected the failed migration to be rolled back")
+	}
+	statuses, err := NewMigrator(db, failing).Status()
+	if err != nil || statuses[0].Applied {
+		t.Fatalf("expected migration pending, got %+v err=%v", statuses[0], err)
+	}
+}
+
+func TestMigratorConcurrentUp(t *testing.T) {
+	db := openTestDB(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- NewMigrator(db, testMigrations()).Up()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent up: %v", err)
+		}
+	}
+	var count int
+	db.Model(&migrationTestTable{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 seeded row, got %v", count)
+	}
+}
+
+//迁移耗时超过LockTTL时心跳保证锁不会被其他进程抢占 迁移只执行一次
+func TestMigratorLockHeartbeat(t *testing.T) {
+	db := openTestDB(t)
+	var runs int32
+	slow := []*Migration{{
+		Version: 1,
+		Name:    "slow",
+		Up: func(db *gorm.DB) error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(time.Second)
+			return nil
+		},
+	}}
+	holder := NewMigrator(db, slow)
+	holder.LockTTL = 300 * time.Millisecond
+	errs := make(chan error, 1)
+	go func() {
+		errs <- holder.Up()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	waiter := NewMigrator(db, slow)
+	waiter.LockTTL = 300 * time.Millisecond
+	if err := waiter.Up(); err != nil {
+		t.Fatalf("waiter up: %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("holder up: %v", err)
+	}
+	if runs != 1 {
+		t.Fatalf("expected the slow migration to run once, got %v", runs)
+	}
+}
+
+func TestMigrationsApplyOnSqlite(t *testing.T) {
+	db := openTestDB(t)
+	if err := NewMigrator(db, Migrations).Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	//全部回滚后可以重新执行
+	if err := NewMigrator(db, Migrations).Down(len(Migrations)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := NewMigrator(db, Migrations).Up(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
+
+//版本号不小于version的迁移数量 用于回滚到某个迁移之前
+func migrationsSince(version uint) int {
+	n := 0
+	for _, migration := range Migrations {
+		if migration.Version >= version {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBackfillLimits(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db, Migrations)
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	err := db.Exec("INSERT INTO raw_problems (remote_oj, remote_problem_id, time_limit, memory_limit, time_limit_ms, memory_limit_kb) VALUES (?, ?, ?, ?, 0, 0)",
+		remote_oj.HDU, "1000", "2000/1000 MS (Java/Others)", "65536/32768 K (Java/Others)").Error
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	//回滚并重新执行数值限制迁移
+	if err = migrator.Down(migrationsSince(7)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err = migrator.Up(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	var rawProblem model.RawProblem
+	if err = db.First(&rawProblem).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if rawProblem.TimeLimitMs != 1000 || rawProblem.MemoryLimitKb != 32768 {
+		t.Fatalf("limits not backfilled: %v ms %v KB", rawProblem.TimeLimitMs, rawProblem.MemoryLimitKb)
+	}
+}
+
+func TestSplitSamples(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db, Migrations)
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	err := db.Create(&model.RawProblem{
+		RemoteOJ:        remote_oj.HDU,
+		RemoteProblemId: "1000",
+		SampleInput:     "1 2\n##sample##\n3 4",
+		SampleOutput:    "3\n##sample##\n7",
+	}).Error
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	//回滚并重新执行样例迁移
+	if err = migrator.Down(migrationsSince(8)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err = migrator.Up(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	var samples []*model.ProblemSample
+	if err = db.Order("sample_order").Find(&samples).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Input != "1 2" || samples[1].Output != "7" {
+		t.Fatalf("samples not split: %+v", samples)
+	}
+}
+
+//迁移1只建立当时的表结构 之后的列与索引由各自的迁移添加
+func TestMigrationsUseSnapshots(t *testing.T) {
+	db := openTestDB(t)
+	if err := NewMigrator(db, Migrations[:1]).Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if db.Dialect().HasColumn("problems", "difficulty") || db.Dialect().HasColumn("raw_problems", "remote_tags") {
+		t.Fatalf("migration 1 created columns of later migrations")
+	}
+	if db.Dialect().HasIndex("problems", "idx_problems_status") || db.HasTable("tags") {
+		t.Fatalf("migration 1 created indexes or tables of later migrations")
+	}
+	if err := NewMigrator(db, Migrations).Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if !db.Dialect().HasIndex("problems", "idx_problems_status") || !db.Dialect().HasIndex("problems", "idx_problems_difficulty") {
+		t.Fatalf("expected problem indexes after all migrations")
+	}
+	//迁移后的表结构应包含当前model的所有列
+	models := []interface{}{
+		&model.User{}, &model.UserAuth{}, &model.Role{}, &model.UserRole{}, &model.Authority{}, &model.RoleAuthority{},
+		&model.Submission{}, &model.SubmissionCode{}, &model.CompileInfo{}, &model.RuntimeInfo{},
+		&model.RawProblem{}, &model.ProblemGroup{}, &model.Problem{},
+		&model.Contest{}, &model.ContestProblem{}, &model.ContestParticipant{}, &model.ContestAdmin{},
+		&model.Tag{}, &model.ProblemTag{}, &model.ProblemSolver{}, &model.RawProblemRevision{}, &model.ProblemSample{},
+		&model.ProblemAsset{}, &model.RawProblemTranslation{}, &model.ProblemFingerprint{}, &model.ProblemFingerprintBand{},
+		&model.GroupSuggestion{},
+	}
+	for _, m := range models {
+		scope := db.NewScope(m)
+		for _, field := range scope.Fields() {
+			if field.IsIgnored || !field.IsNormal {
+				continue
+			}
+			if !db.Dialect().HasColumn(scope.TableName(), field.DBName) {
+				t.Fatalf("missing column %v.%v", scope.TableName(), field.DBName)
+			}
+		}
+	}
+}
+
+//回填使用的副本与problem_mapper当前的实现一致
+func TestBackfillHelpersMatchMapper(t *testing.T) {
+	rawProblem := &model.RawProblem{
+		Title:        "A + B Problem",
+		Description:  "<p>Calculate A + B.</p>",
+		SampleInput:  "1 2",
+		SampleOutput: "3",
+		RemoteOJ:     remote_oj.HDU,
+		TimeLimit:    "2000/1000 MS (Java/Others)",
+		MemoryLimit:  "65536/32768 K (Java/Others)",
+	}
+	rawProblem.ID = 7
+	fingerprint, bands := fingerprintV11(&rawProblemStatementV11{
+		ID:           rawProblem.ID,
+		Title:        rawProblem.Title,
+		Description:  rawProblem.Description,
+		SampleInput:  rawProblem.SampleInput,
+		SampleOutput: rawProblem.SampleOutput,
+		TimeLimit:    rawProblem.TimeLimit,
+		MemoryLimit:  rawProblem.MemoryLimit,
+	})
+	want, wantBands := problem_mapper.Fingerprint(rawProblem)
+	if fingerprint.ContentHash != want.ContentHash || fingerprint.Signature != want.Signature || fingerprint.SampleHash != want.SampleHash {
+		t.Fatalf("fingerprint differs: %+v %+v", fingerprint, want)
+	}
+	if len(bands) != len(wantBands) || bands[3].Bucket != wantBands[3].Bucket {
+		t.Fatalf("bands differ: %+v %+v", bands, wantBands)
+	}
+	timeLimitMs, memoryLimitKb := limitsV7(&rawProblemLimitsV7{RemoteOJ: rawProblem.RemoteOJ, TimeLimit: rawProblem.TimeLimit, MemoryLimit: rawProblem.MemoryLimit})
+	problem_mapper.NormalizeLimits(rawProblem)
+	if timeLimitMs != rawProblem.TimeLimitMs || memoryLimitKb != rawProblem.MemoryLimitKb {
+		t.Fatalf("limits differ: %v %v, %v %v", timeLimitMs, memoryLimitKb, rawProblem.TimeLimitMs, rawProblem.MemoryLimitKb)
+	}
+}
@@ -0,0 +1,124 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+//*sql.DB与*sql.Tx都满足
+type planConn interface {
+	gorm.SQLCommon
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+//记录Exec语句而不执行 只读查询照常下发 以便迁移中的HasTable等判断仍然有效
+//经Query/QueryRow下发的写语句(如postgres的INSERT ... RETURNING)同样只记录 并以错误返回
+type recorder struct {
+	db         planConn
+	statements []string
+	rejected   []string
+}
+
+func (r *recorder) record(query string, args []interface{}) string {
+	if len(args) > 0 {
+		query = fmt.Sprintf("%v -- args: %v", query, args)
+	}
+	r.statements = append(r.statements, query)
+	return query
+}
+
+func (r *recorder) reject(query string, args []interface{}) {
+	r.rejected = append(r.rejected, r.record(query, args))
+}
+
+func (r *recorder) Exec(query string, args ...interface{}) (sql.Result, error) {
+	r.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (r *recorder) Prepare(query string) (*sql.Stmt, error) {
+	return r.db.Prepare(query)
+}
+
+func (r *recorder) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if !readOnly(query) {
+		r.reject(query, args)
+		return nil, fmt.Errorf("plan does not run writing query: %v", query)
+	}
+	return r.db.Query(query, args...)
+}
+
+func (r *recorder) QueryRow(query string, args ...interface{}) *sql.Row {
+	if readOnly(query) {
+		return r.db.QueryRow(query, args...)
+	}
+	r.reject(query, args)
+	//context已取消时database/sql在取得连接前就返回错误 语句不会下发
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return r.db.QueryRowContext(ctx, query, args...)
+}
+
+func readOnly(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "SHOW", "PRAGMA":
+		return true
+	}
+	return false
+}
+
+type PlannedMigration struct {
+	Version    uint
+	Name       string
+	Statements []string
+}
+
+//返回待执行迁移将会执行的sql 不修改数据库
+//依赖前一个迁移结果的判断(如前一步新建的表)会按当前数据库状态给出 仅供参考
+func (m *Migrator) Plan() ([]*PlannedMigration, error) {
+	applied := map[uint]*SchemaMigration{}
+	if m.DB.HasTable(&SchemaMigration{}) {
+		var err error
+		if applied, err = m.appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+	conn, ok := m.DB.CommonDB().(planConn)
+	if !ok {
+		return nil, fmt.Errorf("plan does not support connection %T", m.DB.CommonDB())
+	}
+	rec := &recorder{db: conn}
+	planDB, err := gorm.Open(m.DB.Dialect().GetName(), rec)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]*PlannedMigration, 0)
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		rec.statements = nil
+		rec.rejected = nil
+		if err = migration.Up(planDB); err != nil {
+			if len(rec.rejected) > 0 {
+				return nil, fmt.Errorf("migration %v_%v plan: writing query %v cannot be planned: %v",
+					migration.Version, migration.Name, rec.rejected[0], err)
+			}
+			return nil, fmt.Errorf("migration %v_%v plan: %v", migration.Version, migration.Name, err)
+		}
+		plans = append(plans, &PlannedMigration{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			Statements: rec.statements,
+		})
+	}
+	return plans, nil
+}
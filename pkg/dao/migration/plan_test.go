@@ -0,0 +1,86 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanLeavesDatabaseUnchanged(t *testing.T) {
+	db := openTestDB(t)
+	if err := NewMigrator(db, Migrations[:7]).Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	err := db.Exec("INSERT INTO raw_problems (remote_oj, remote_problem_id, sample_input, sample_output) VALUES (?, ?, ?, ?)",
+		remote_oj.HDU, "1000", "1 2\n##sample##\n3 4", "3\n##sample##\n7").Error
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	//sqlite_master中的表结构与各表行数
+	snapshot := func() map[string]string {
+		rows, err := db.Raw("SELECT type, name, COALESCE(sql, '') FROM sqlite_master").Rows()
+		if err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+		defer rows.Close()
+		schema := make(map[string]string)
+		tables := make([]string, 0)
+		for rows.Next() {
+			var kind, name, sql string
+			if err = rows.Scan(&kind, &name, &sql); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			schema[kind+" "+name] = sql
+			if kind == "table" {
+				tables = append(tables, name)
+			}
+		}
+		rows.Close()
+		for _, table := range tables {
+			var count int
+			if err = db.Table(table).Count(&count).Error; err != nil {
+				t.Fatalf("count %v: %v", table, err)
+			}
+			schema["rows "+table] = fmt.Sprint(count)
+		}
+		return schema
+	}
+	before := snapshot()
+	plans, err := NewMigrator(db, Migrations).Plan()
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plans) != len(Migrations)-7 || plans[0].Version != 8 {
+		t.Fatalf("unexpected plans: %+v", plans)
+	}
+	if len(plans[0].Statements) == 0 || !strings.Contains(plans[0].Statements[0], "problem_samples") {
+		t.Fatalf("expected problem_samples to be created: %v", plans[0].Statements)
+	}
+	if after := snapshot(); !reflect.DeepEqual(before, after) {
+		t.Fatalf("plan changed the database:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestPlanRecorderRejectsWritingQueries(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&migrationTestTable{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	rec := &recorder{db: db.CommonDB().(planConn)}
+	var id uint
+	if err := rec.QueryRow("INSERT INTO migration_test_tables (name) VALUES (?) RETURNING id", "x").Scan(&id); err == nil {
+		t.Fatalf("expected writing QueryRow to be rejected")
+	}
+	if _, err := rec.Query("DELETE FROM migration_test_tables RETURNING id"); err == nil {
+		t.Fatalf("expected writing Query to be rejected")
+	}
+	var count int
+	if err := rec.QueryRow("SELECT count(*) FROM migration_test_tables").Scan(&count); err != nil || count != 0 {
+		t.Fatalf("expected no rows written, got count=%v err=%v", count, err)
+	}
+	if len(rec.rejected) != 2 || len(rec.statements) != 2 {
+		t.Fatalf("expected 2 recorded statements, got %v", rec.statements)
+	}
+}
@@ -0,0 +1,407 @@
+package migration
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/language"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/status_type"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+//各版本迁移时的表结构快照 迁移只能使用这里的结构 不能引用pkg/dao/model
+//model之后的改动须通过新的迁移与新的快照体现 已发布的快照不能修改
+//只新增列的快照只声明新增的列 AutoMigrate会跳过已有的列
+
+//版本1
+type userV1 struct {
+	gorm.Model
+	Email        string
+	Nickname     string `gorm:"unique_index:uidx_name"`
+	School       string
+	GenerateUser bool
+	Submitted    int64
+	Accepted     int64
+}
+
+func (userV1) TableName() string { return "users" }
+
+type userAuthV1 struct {
+	gorm.Model
+	UserID   uint
+	Password string
+}
+
+func (userAuthV1) TableName() string { return "user_auths" }
+
+type roleV1 struct {
+	gorm.Model
+	RoleName string
+}
+
+func (roleV1) TableName() string { return "roles" }
+
+type userRoleV1 struct {
+	UserId uint
+	RoleId uint
+}
+
+func (userRoleV1) TableName() string { return "user_roles" }
+
+type authorityV1 struct {
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time `sql:"index"`
+	AuthorityId uint       `gorm:"primary_key"`
+	Privilege   string
+}
+
+func (authorityV1) TableName() string { return "authorities" }
+
+type roleAuthorityV1 struct {
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time `sql:"index"`
+	RoleId      uint
+	AuthorityId uint
+}
+
+func (roleAuthorityV1) TableName() string { return "role_authorities" }
+
+type submissionV1 struct {
+	gorm.Model
+	ProblemId  uint
+	UserId     uint
+	Username   string
+	Result     status_type.SubmissionStatusType
+	TimeCost   int64
+	MemoryCost int64
+	Language   language.Language
+	ContestId  uint
+	RemoteOJ   remote_oj.RemoteOJ
+	RealRunId  string
+}
+
+func (submissionV1) TableName() string { return "submissions" }
+
+type submissionCodeV1 struct {
+	gorm.Model
+	SubmissionID uint
+	SourceCode   string `gorm:"type:text"`
+	CodeLength   int64
+}
+
+func (submissionCodeV1) TableName() string { return "submission_codes" }
+
+type compileInfoV1 struct {
+	SubmissionId uint
+	Info         string `gorm:"type:text"`
+}
+
+func (compileInfoV1) TableName() string { return "compile_infos" }
+
+type runtimeInfoV1 struct {
+	SubmissionId uint
+	Info         string `gorm:"type:text"`
+}
+
+func (runtimeInfoV1) TableName() string { return "runtime_infos" }
+
+type rawProblemV1 struct {
+	gorm.Model
+	Title           string
+	Description     string             `gorm:"type:text"`
+	SampleInput     string             `gorm:"type:text"`
+	SampleOutput    string             `gorm:"type:text"`
+	Input           string             `gorm:"type:text"`
+	Output          string             `gorm:"type:text"`
+	Hint            string             `gorm:"type:text"`
+	RemoteOJ        remote_oj.RemoteOJ `gorm:"unique_index:uni_idx_pid"`
+	RemoteProblemId string             `gorm:"unique_index:uni_idx_pid"`
+	RemoteSubmitId  string
+	TimeLimit       string
+	MemoryLimit     string
+	Spj             string
+	Std             string
+	Source          string
+}
+
+func (rawProblemV1) TableName() string { return "raw_problems" }
+
+type problemGroupV1 struct {
+	gorm.Model
+	RawProblemId    uint `gorm:"unique_index:uidx_rawId"`
+	GroupId         uint
+	MainProblem     bool
+	RemoteOJ        remote_oj.RemoteOJ
+	RemoteProblemId string
+}
+
+func (problemGroupV1) TableName() string { return "problem_groups" }
+
+type problemV1 struct {
+	gorm.Model
+	GroupId      uint `gorm:"uniqueIndex"`
+	RawProblemId uint
+	Status       int32
+	Submitted    int64 `gorm:"default:0"`
+	Accepted     int64 `gorm:"default:0"`
+}
+
+func (problemV1) TableName() string { return "problems" }
+
+type contestV1 struct {
+	gorm.Model
+	Title       string
+	Description string
+	UserId      uint
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+func (contestV1) TableName() string { return "contests" }
+
+type contestProblemV1 struct {
+	ContestId    uint   `gorm:"unique_index:uni_idx_pod"`
+	ProblemOrder string `gorm:"unique_index:uni_idx_pod"`
+	ProblemId    uint
+	Title        string
+	Submitted    uint `gorm:"default:0"`
+	Accepted     uint `gorm:"default:0"`
+}
+
+func (contestProblemV1) TableName() string { return "contest_problems" }
+
+type contestParticipantV1 struct {
+	ContestId uint
+	UserId    uint
+}
+
+func (contestParticipantV1) TableName() string { return "contest_participants" }
+
+type contestAdminV1 struct {
+	ContestId uint
+	UserId    uint
+}
+
+func (contestAdminV1) TableName() string { return "contest_admins" }
+
+func initialTables() []interface{} {
+	return []interface{}{
+		&userV1{},
+		&userAuthV1{},
+		&roleV1{},
+		&userRoleV1{},
+		&authorityV1{},
+		&roleAuthorityV1{},
+		&submissionV1{},
+		&submissionCodeV1{},
+		&compileInfoV1{},
+		&runtimeInfoV1{},
+		&rawProblemV1{},
+		&problemGroupV1{},
+		&problemV1{},
+		&contestV1{},
+		&contestProblemV1{},
+		&contestParticipantV1{},
+		&contestAdminV1{},
+	}
+}
+
+//版本4
+type tagV4 struct {
+	gorm.Model
+	Name string `gorm:"unique_index:uidx_tag_name"`
+}
+
+func (tagV4) TableName() string { return "tags" }
+
+type problemTagV4 struct {
+	ProblemId uint `gorm:"unique_index:uidx_problem_tag"`
+	TagId     uint `gorm:"unique_index:uidx_problem_tag;index"`
+}
+
+func (problemTagV4) TableName() string { return "problem_tags" }
+
+type rawProblemV4 struct {
+	RemoteTags string
+}
+
+func (rawProblemV4) TableName() string { return "raw_problems" }
+
+//版本5
+type problemV5 struct {
+	Difficulty     int32   `gorm:"default:0;index:idx_problems_difficulty"`
+	Solvers        int64   `gorm:"default:0"`
+	SolverStrength float64 `gorm:"default:0"`
+}
+
+func (problemV5) TableName() string { return "problems" }
+
+type problemSolverV5 struct {
+	ProblemId uint `gorm:"unique_index:uidx_problem_solver"`
+	UserId    uint `gorm:"unique_index:uidx_problem_solver"`
+	CreatedAt time.Time
+}
+
+func (problemSolverV5) TableName() string { return "problem_solvers" }
+
+//版本6
+type rawProblemV6 struct {
+	ContentHash string `gorm:"size:64"`
+}
+
+func (rawProblemV6) TableName() string { return "raw_problems" }
+
+type rawProblemRevisionV6 struct {
+	gorm.Model
+	RawProblemId uint   `gorm:"unique_index:uidx_raw_problem_revision"`
+	Revision     int32  `gorm:"unique_index:uidx_raw_problem_revision"`
+	ContentHash  string `gorm:"size:64"`
+	Title        string
+	Description  string `gorm:"type:text"`
+	SampleInput  string `gorm:"type:text"`
+	SampleOutput string `gorm:"type:text"`
+	Input        string `gorm:"type:text"`
+	Output       string `gorm:"type:text"`
+	Hint         string `gorm:"type:text"`
+	TimeLimit    string
+	MemoryLimit  string
+	Source       string
+}
+
+func (rawProblemRevisionV6) TableName() string { return "raw_problem_revisions" }
+
+//版本7
+type rawProblemV7 struct {
+	TimeLimitMs   int32 `gorm:"index:idx_raw_problems_time_limit_ms"`
+	MemoryLimitKb int32 `gorm:"index:idx_raw_problems_memory_limit_kb"`
+}
+
+func (rawProblemV7) TableName() string { return "raw_problems" }
+
+//回填读取的原始题目 只包含迁移7时已有的列 没有DeletedAt 软删除的题目同样回填
+type rawProblemLimitsV7 struct {
+	ID            uint
+	RemoteOJ      remote_oj.RemoteOJ
+	TimeLimit     string
+	MemoryLimit   string
+	TimeLimitMs   int32
+	MemoryLimitKb int32
+}
+
+func (rawProblemLimitsV7) TableName() string { return "raw_problems" }
+
+//版本8
+type problemSampleV8 struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"unique_index:uidx_problem_sample"`
+	SampleOrder  int32  `gorm:"unique_index:uidx_problem_sample"`
+	Input        string `gorm:"type:text"`
+	Output       string `gorm:"type:text"`
+	Explanation  string `gorm:"type:text"`
+}
+
+func (problemSampleV8) TableName() string { return "problem_samples" }
+
+//回填读取的原始题目样例文本
+type rawProblemSamplesV8 struct {
+	ID           uint
+	SampleInput  string
+	SampleOutput string
+	DeletedAt    *time.Time
+}
+
+func (rawProblemSamplesV8) TableName() string { return "raw_problems" }
+
+//版本9
+type problemAssetV9 struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"unique_index:uidx_problem_asset"`
+	SourceUrl    string `gorm:"size:512;unique_index:uidx_problem_asset"`
+	StorageKey   string
+	LocalUrl     string `gorm:"size:512"`
+	ContentType  string
+	Size         int64
+	Sha256       string `gorm:"size:64"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (problemAssetV9) TableName() string { return "problem_assets" }
+
+//版本10
+type rawProblemTranslationV10 struct {
+	gorm.Model
+	RawProblemId uint   `gorm:"unique_index:uidx_raw_problem_translation"`
+	Locale       string `gorm:"size:16;unique_index:uidx_raw_problem_translation"`
+	Title        string
+	Description  string `gorm:"type:text"`
+	Input        string `gorm:"type:text"`
+	Output       string `gorm:"type:text"`
+	Hint         string `gorm:"type:text"`
+}
+
+func (rawProblemTranslationV10) TableName() string { return "raw_problem_translations" }
+
+//版本11
+type problemFingerprintV11 struct {
+	RawProblemId uint   `gorm:"primary_key;auto_increment:false"`
+	ContentHash  string `gorm:"size:64"`
+	Signature    string `gorm:"type:text"`
+	SampleHash   string `gorm:"size:64"`
+	UpdatedAt    time.Time
+}
+
+func (problemFingerprintV11) TableName() string { return "problem_fingerprints" }
+
+type problemFingerprintBandV11 struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"index"`
+	Band         int32  `gorm:"index:idx_fingerprint_bucket"`
+	Bucket       string `gorm:"size:16;index:idx_fingerprint_bucket"`
+}
+
+func (problemFingerprintBandV11) TableName() string { return "problem_fingerprint_bands" }
+
+type groupSuggestionV11 struct {
+	ID                 uint `gorm:"primary_key"`
+	RawProblemId       uint `gorm:"unique_index:uidx_group_suggestion"`
+	TargetRawProblemId uint `gorm:"unique_index:uidx_group_suggestion"`
+	Score              float64
+	Status             int32 `gorm:"default:0;index"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (groupSuggestionV11) TableName() string { return "group_suggestions" }
+
+//回填读取的原始题目题面
+type rawProblemStatementV11 struct {
+	ID           uint
+	Title        string
+	Description  string
+	SampleInput  string
+	SampleOutput string
+	Input        string
+	Output       string
+	Hint         string
+	TimeLimit    string
+	MemoryLimit  string
+	Source       string
+	ContentHash  string
+	DeletedAt    *time.Time
+}
+
+func (rawProblemStatementV11) TableName() string { return "raw_problems" }
+
+//版本12
+type problemGroupV12 struct {
+	RemoteSuccess       int64 `gorm:"default:0"`
+	RemoteFailure       int64 `gorm:"default:0"`
+	ConsecutiveFailures int32 `gorm:"default:0"`
+	LastSuccessAt       *time.Time
+	LastFailureAt       *time.Time
+}
+
+func (problemGroupV12) TableName() string { return "problem_groups" }
+
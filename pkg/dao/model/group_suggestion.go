@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+const (
+	SuggestionPending  int32 = 0
+	SuggestionAccepted int32 = 1
+	SuggestionRejected int32 = 2
+)
+
+//建议把RawProblemId并入TargetRawProblemId所在的题目组
+type GroupSuggestion struct {
+	ID                 uint `gorm:"primary_key"`
+	RawProblemId       uint `gorm:"unique_index:uidx_group_suggestion"`
+	TargetRawProblemId uint `gorm:"unique_index:uidx_group_suggestion"`
+	//0~1 越大越可能是同一道题
+	Score     float64
+	Status    int32 `gorm:"default:0;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -4,12 +4,49 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+//题目状态 零值为公开 引入状态前的题目都是公开的
+type ProblemStatus int32
+
+const (
+	ProblemPublic ProblemStatus = iota
+	ProblemDraft
+	ProblemHidden
+	//只能通过比赛访问 不出现在题目列表中
+	ProblemContestOnly
+	ProblemRetired
+)
+
+func (s ProblemStatus) Valid() bool {
+	return s >= ProblemPublic && s <= ProblemRetired
+}
+
+func (s ProblemStatus) String() string {
+	switch s {
+	case ProblemPublic:
+		return "public"
+	case ProblemDraft:
+		return "draft"
+	case ProblemHidden:
+		return "hidden"
+	case ProblemContestOnly:
+		return "contest_only"
+	case ProblemRetired:
+		return "retired"
+	}
+	return "unknown"
+}
+
 type Problem struct {
 	gorm.Model
 	GroupId      uint `gorm:"uniqueIndex"`
 	RawProblemId uint
 	RawProblem   *RawProblem
-	Status       int32
-	Submitted    int64 `gorm:"default:0"`
-	Accepted     int64 `gorm:"default:0"`
+	//status列建表时没有默认值 插入时由gorm写入零值
+	Status    ProblemStatus `gorm:"index:idx_problems_status"`
+	Submitted int64         `gorm:"default:0"`
+	Accepted  int64         `gorm:"default:0"`
+	//难度分 由pkg/difficulty计算 0表示尚未评分
+	Difficulty     int32   `gorm:"default:0;index:idx_problems_difficulty"`
+	Solvers        int64   `gorm:"default:0"`
+	SolverStrength float64 `gorm:"default:0"`
 }
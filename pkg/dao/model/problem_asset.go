@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+//题面引用的附件 SourceUrl为题面中出现的原地址 LocalUrl为转存后的地址
+type ProblemAsset struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"unique_index:uidx_problem_asset"`
+	SourceUrl    string `gorm:"size:512;unique_index:uidx_problem_asset"`
+	StorageKey   string
+	LocalUrl     string `gorm:"size:512"`
+	ContentType  string
+	Size         int64
+	Sha256       string `gorm:"size:64"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+//原始题目题面的minhash指纹 由problem_mapper在题面变化时更新
+type ProblemFingerprint struct {
+	RawProblemId uint `gorm:"primary_key;auto_increment:false"`
+	//生成指纹时题面的ContentHash 未变化时不重新计算
+	ContentHash string `gorm:"size:64"`
+	//标题与描述的similarity.Signature 经Encode编码
+	Signature string `gorm:"type:text"`
+	//规范化后样例的sha256 没有样例时为空
+	SampleHash string `gorm:"size:64"`
+	UpdatedAt  time.Time
+}
+
+//指纹的LSH桶 band与bucket都相同的题目互为候选
+type ProblemFingerprintBand struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"index"`
+	Band         int32  `gorm:"index:idx_fingerprint_bucket"`
+	Bucket       string `gorm:"size:16;index:idx_fingerprint_bucket"`
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
 type ProblemGroup struct {
@@ -12,4 +13,10 @@ type ProblemGroup struct {
 	MainProblem     bool
 	RemoteOJ        remote_oj.RemoteOJ
 	RemoteProblemId string
+	//远程判题的健康度 由RecordRemoteResult维护
+	RemoteSuccess       int64 `gorm:"default:0"`
+	RemoteFailure       int64 `gorm:"default:0"`
+	ConsecutiveFailures int32 `gorm:"default:0"`
+	LastSuccessAt       *time.Time
+	LastFailureAt       *time.Time
 }
@@ -0,0 +1,11 @@
+package model
+
+//原始题目的一组样例 SampleOrder从1开始 整组替换 不做软删除
+type ProblemSample struct {
+	ID           uint   `gorm:"primary_key"`
+	RawProblemId uint   `gorm:"unique_index:uidx_problem_sample"`
+	SampleOrder  int32  `gorm:"unique_index:uidx_problem_sample"`
+	Input        string `gorm:"type:text"`
+	Output       string `gorm:"type:text"`
+	Explanation  string `gorm:"type:text"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+type ProblemSolver struct {
+	ProblemId uint `gorm:"unique_index:uidx_problem_solver"`
+	UserId    uint `gorm:"unique_index:uidx_problem_solver"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,6 @@
+package model
+
+type ProblemTag struct {
+	ProblemId uint `gorm:"unique_index:uidx_problem_tag"`
+	TagId     uint `gorm:"unique_index:uidx_problem_tag;index"`
+}
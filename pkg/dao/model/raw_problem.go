@@ -22,4 +22,13 @@ type RawProblem struct {
 	Spj             string
 	Std             string
 	Source          string
+	//爬取到的远程题目标签 逗号分隔 由problem_mapper按RemoteOJ解析后导入
+	RemoteTags string
+	//由TimeLimit、MemoryLimit按RemoteOJ解析 0表示未知
+	TimeLimitMs   int32 `gorm:"index:idx_raw_problems_time_limit_ms"`
+	MemoryLimitKb int32 `gorm:"index:idx_raw_problems_memory_limit_kb"`
+	//题面内容的sha256 与最新的RawProblemRevision一致
+	ContentHash string `gorm:"size:64"`
+	//按locale查询时实际使用的翻译 为空表示原文 不落库
+	Locale string `gorm:"-"`
 }
@@ -0,0 +1,21 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+//RawProblem题面的一个历史版本 每次题面内容变化时追加一条
+type RawProblemRevision struct {
+	gorm.Model
+	RawProblemId uint   `gorm:"unique_index:uidx_raw_problem_revision"`
+	Revision     int32  `gorm:"unique_index:uidx_raw_problem_revision"`
+	ContentHash  string `gorm:"size:64"`
+	Title        string
+	Description  string `gorm:"type:text"`
+	SampleInput  string `gorm:"type:text"`
+	SampleOutput string `gorm:"type:text"`
+	Input        string `gorm:"type:text"`
+	Output       string `gorm:"type:text"`
+	Hint         string `gorm:"type:text"`
+	TimeLimit    string
+	MemoryLimit  string
+	Source       string
+}
@@ -0,0 +1,16 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+//原始题目题面的一种语言版本 为空的字段沿用原文
+type RawProblemTranslation struct {
+	gorm.Model
+	RawProblemId uint `gorm:"unique_index:uidx_raw_problem_translation"`
+	//小写并以-分隔 如 en zh-cn
+	Locale      string `gorm:"size:16;unique_index:uidx_raw_problem_translation"`
+	Title       string
+	Description string `gorm:"type:text"`
+	Input       string `gorm:"type:text"`
+	Output      string `gorm:"type:text"`
+	Hint        string `gorm:"type:text"`
+}
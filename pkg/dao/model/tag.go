@@ -0,0 +1,8 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+type Tag struct {
+	gorm.Model
+	Name string `gorm:"unique_index:uidx_tag_name"`
+}
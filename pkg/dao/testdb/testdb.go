@@ -0,0 +1,159 @@
+//testdb 为mapper测试提供隔离的sqlite数据库与固定数据 不依赖外部mysql
+package testdb
+
+import (
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/language"
+	"github.com/ecnuvj/vhoj_common/pkg/common/constants/remote_oj"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/datasource"
+	"github.com/ecnuvj/vhoj_db/pkg/dao/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//每次调用返回一个新建并完成迁移的数据库 测试结束时自动删除
+func New(t testing.TB) *datasource.Store {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "vhoj_db_test")
+	if err != nil {
+		t.Fatalf("testdb: create temp dir: %v", err)
+	}
+	store, err := datasource.Open(&datasource.MysqlConf{
+		Driver: datasource.DriverSqlite,
+		Path:   "file:" + filepath.Join(dir, "vhoj.db") + "?_busy_timeout=5000",
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("testdb: open: %v", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(dir)
+	})
+	return store
+}
+
+type Fixtures struct {
+	Roles       []*model.Role
+	Users       []*model.User
+	RawProblems []*model.RawProblem
+	Groups      []*model.ProblemGroup
+	Problems    []*model.Problem
+	Contests    []*model.Contest
+	Submissions []*model.Submission
+}
+
+//写入一组相互关联的数据:
+//  用户 alice(admin) bob(normal) carol(normal)
+//  题目 HDU 1000/1001/1002 各自成组 1003与1002同组
+//  比赛 alice创建 含前两题 bob为管理员 carol为参与者
+//  提交 bob与carol在题目与比赛中的若干提交
+func Load(t testing.TB, store *datasource.Store) *Fixtures {
+	t.Helper()
+	f := &Fixtures{}
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("testdb: load fixtures: %v", err)
+		}
+	}
+	for _, name := range []string{"admin", "normal"} {
+		role := &model.Role{RoleName: name}
+		must(store.DB.Create(role).Error)
+		f.Roles = append(f.Roles, role)
+	}
+	for i, name := range []string{"alice", "bob", "carol"} {
+		roleName := "normal"
+		if i == 0 {
+			roleName = "admin"
+		}
+		user, err := store.UserMapper().AddUser(&model.User{
+			UserAuth: &model.UserAuth{Password: name + "-password"},
+			Email:    name + "@example.com",
+			Nickname: name,
+			School:   "ECNU",
+			Roles:    []*model.Role{{RoleName: roleName}},
+		})
+		must(err)
+		f.Users = append(f.Users, user)
+	}
+	remotes := []struct {
+		oj    remote_oj.RemoteOJ
+		id    string
+		title string
+	}{
+		{remote_oj.HDU, "1000", "A + B Problem"},
+		{remote_oj.HDU, "1001", "Sum Problem"},
+		{remote_oj.HDU, "1002", "A + B Problem II"},
+		{remote_oj.HDU, "1003", "A+B Problem Again"},
+	}
+	for _, r := range remotes {
+		raw, err := store.ProblemMapper().AddOrModifyRawProblem(&model.RawProblem{
+			Title:           r.title,
+			Description:     "Calculate " + r.title,
+			SampleInput:     "1 2",
+			SampleOutput:    "3",
+			RemoteOJ:        r.oj,
+			RemoteProblemId: r.id,
+			TimeLimit:       "1000MS",
+			MemoryLimit:     "32768K",
+			Source:          "fixture",
+		})
+		must(err)
+		f.RawProblems = append(f.RawProblems, raw)
+	}
+	//前三道原始题各自成组 组号取原始题id 1003并入1002所在组
+	for i, raw := range f.RawProblems {
+		groupRaw := raw
+		if i == 3 {
+			groupRaw = f.RawProblems[2]
+		}
+		group, err := store.ProblemMapper().AddOrModifyProblemGroup(&model.ProblemGroup{
+			RawProblemId:    raw.ID,
+			GroupId:         groupRaw.ID,
+			MainProblem:     i < 3,
+			RemoteOJ:        raw.RemoteOJ,
+			RemoteProblemId: raw.RemoteProblemId,
+		})
+		must(err)
+		f.Groups = append(f.Groups, group)
+	}
+	for _, raw := range f.RawProblems[:3] {
+		problem, err := store.ProblemMapper().AddOrModifyProblem(&model.Problem{
+			GroupId:      raw.ID,
+			RawProblemId: raw.ID,
+		})
+		must(err)
+		f.Problems = append(f.Problems, problem)
+	}
+	alice, bob, carol := f.Users[0], f.Users[1], f.Users[2]
+	now := time.Now()
+	contest, err := store.ContestMapper().CreateContest(&model.Contest{
+		Title:       "fixture contest",
+		Description: "contest for tests",
+		UserId:      alice.ID,
+		StartTime:   now.Add(-time.Hour),
+		EndTime:     now.Add(time.Hour),
+	}, []*model.ContestProblem{
+		{ProblemId: f.Problems[0].ID, Title: "A + B Problem", ProblemOrder: "A"},
+		{ProblemId: f.Problems[1].ID, Title: "Sum Problem", ProblemOrder: "B"},
+	})
+	must(err)
+	must(store.ContestMapper().AddContestAdmins(contest.ID, []uint{bob.ID}))
+	must(store.ContestMapper().AddContestParticipants(contest.ID, []uint{carol.ID}))
+	f.Contests = append(f.Contests, contest)
+	submissions := []*model.Submission{
+		{ProblemId: f.Problems[0].ID, UserId: bob.ID, Username: bob.Nickname, Result: 1, Language: language.CPP, RemoteOJ: remote_oj.HDU},
+		{ProblemId: f.Problems[0].ID, UserId: bob.ID, Username: bob.Nickname, Result: 2, Language: language.CPP, RemoteOJ: remote_oj.HDU},
+		{ProblemId: f.Problems[1].ID, UserId: carol.ID, Username: carol.Nickname, Result: 1, Language: language.CPP, RemoteOJ: remote_oj.HDU},
+		{ProblemId: f.Problems[0].ID, UserId: carol.ID, Username: carol.Nickname, Result: 1, Language: language.CPP, RemoteOJ: remote_oj.HDU, ContestId: contest.ID},
+	}
+	for _, s := range submissions {
+		s.SubmissionCode = &model.SubmissionCode{SourceCode: "int main() { return 0; }", CodeLength: 24}
+		submission, err := store.SubmissionMapper().AddOrModifySubmission(s)
+		must(err)
+		f.Submissions = append(f.Submissions, submission)
+	}
+	return f
+}
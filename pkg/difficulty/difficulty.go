@@ -0,0 +1,56 @@
+//difficulty 根据提交统计计算题目难度分
+//难度由通过率、不同解题者数量与解题者强度三部分加权得到 分值落在[MinRating, MaxRating]
+package difficulty
+
+import "math"
+
+const (
+	//没有任何提交的题目不评分
+	Unrated   int32 = 0
+	MinRating int32 = 800
+	MaxRating int32 = 3500
+
+	ratioWeight    = 0.5
+	rarityWeight   = 0.3
+	strengthWeight = 0.2
+	//解题数达到该值的用户视为最强解题者
+	strongestSolved = 1000
+)
+
+type Stats struct {
+	Submitted int64
+	Accepted  int64
+	//不同解题者的数量
+	Solvers int64
+	//全部解题者Strength之和 新增解题者时累加 避免每次重新扫描
+	SolverStrength float64
+}
+
+//解题者强度 取解题数的对数 使刷题量的边际影响递减
+func Strength(solved int64) float64 {
+	if solved < 0 {
+		solved = 0
+	}
+	return math.Log1p(float64(solved))
+}
+
+func Rate(stats Stats) int32 {
+	if stats.Submitted <= 0 {
+		return Unrated
+	}
+	accepted := stats.Accepted
+	if accepted > stats.Submitted {
+		accepted = stats.Submitted
+	}
+	//加一平滑 提交很少时不会得到极端的通过率
+	ratio := float64(accepted+1) / float64(stats.Submitted+2)
+	rarity := 1 / (1 + math.Log1p(float64(stats.Solvers)))
+	strength := 0.0
+	if stats.Solvers > 0 {
+		strength = math.Min(stats.SolverStrength/float64(stats.Solvers)/Strength(strongestSolved), 1)
+	}
+	hardness := ratioWeight*(1-ratio) + rarityWeight*rarity + strengthWeight*strength
+	rating := float64(MinRating) + float64(MaxRating-MinRating)*hardness
+	//取整到10分
+	return int32(math.Round(rating/10) * 10)
+}
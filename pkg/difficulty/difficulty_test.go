@@ -0,0 +1,22 @@
+package difficulty
+
+import "testing"
+
+func TestRate(t *testing.T) {
+	if rating := Rate(Stats{}); rating != Unrated {
+		t.Fatalf("problem without submissions should be unrated, got %v", rating)
+	}
+	easy := Rate(Stats{Submitted: 100, Accepted: 90, Solvers: 80, SolverStrength: 80 * Strength(5)})
+	hard := Rate(Stats{Submitted: 100, Accepted: 5, Solvers: 3, SolverStrength: 3 * Strength(800)})
+	if easy < MinRating || hard > MaxRating || easy >= hard {
+		t.Fatalf("expected %v < %v within range", easy, hard)
+	}
+	weak := Rate(Stats{Submitted: 10, Accepted: 5, Solvers: 5, SolverStrength: 5 * Strength(1)})
+	strong := Rate(Stats{Submitted: 10, Accepted: 5, Solvers: 5, SolverStrength: 5 * Strength(500)})
+	if weak >= strong {
+		t.Fatalf("stronger solvers should mean a harder problem: %v >= %v", weak, strong)
+	}
+	if rating := Rate(Stats{Submitted: 1, Accepted: 0}); rating%10 != 0 || rating > MaxRating {
+		t.Fatalf("unexpected rating %v", rating)
+	}
+}
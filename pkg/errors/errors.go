@@ -0,0 +1,127 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+)
+
+//mapper返回的错误都可以用errors.Is与以下哨兵比较 调用方无需引入gorm
+var (
+	ErrNotFound        = stderrors.New("not found")
+	ErrInvalidArgument = stderrors.New("invalid argument")
+	ErrDuplicate       = stderrors.New("duplicate")
+	ErrConflict        = stderrors.New("conflict")
+)
+
+const (
+	mysqlDuplicateEntry   = 1062
+	postgresUniqueViolate = "23505"
+)
+
+var mysqlDuplicateKey = regexp.MustCompile(`for key '([^']+)'`)
+
+type Error struct {
+	//上面的哨兵之一 无法归类的数据库错误为nil
+	Kind error
+	//出错的mapper方法 如 problem_mapper.FindProblemById
+	Op string
+	//Kind为ErrDuplicate时冲突的唯一索引 如 uni_idx_pid uidx_name(sqlite只能给出 表.列)
+	Index string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.Op != "" {
+		b.WriteString(e.Op)
+		b.WriteString(": ")
+	}
+	if e.Kind != nil {
+		b.WriteString(e.Kind.Error())
+		if e.Index != "" {
+			b.WriteString(" (" + e.Index + ")")
+		}
+		if e.Err != nil {
+			b.WriteString(": ")
+		}
+	}
+	if e.Err != nil {
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func Is(err error, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+func New(text string) error {
+	return stderrors.New(text)
+}
+
+func InvalidArgument(op string, format string, args ...interface{}) error {
+	return &Error{Kind: ErrInvalidArgument, Op: op, Err: fmt.Errorf(format, args...)}
+}
+
+func NotFound(op string, format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Op: op, Err: fmt.Errorf(format, args...)}
+}
+
+func Conflict(op string, format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Op: op, Err: fmt.Errorf(format, args...)}
+}
+
+//把gorm/驱动错误转换为*Error 已经是*Error的原样返回
+func Wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if stderrors.As(err, &e) {
+		return err
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return &Error{Kind: ErrNotFound, Op: op, Err: err}
+	}
+	if index, ok := duplicateIndex(err); ok {
+		return &Error{Kind: ErrDuplicate, Op: op, Index: index, Err: err}
+	}
+	return &Error{Op: op, Err: err}
+}
+
+func duplicateIndex(err error) (string, bool) {
+	var mysqlErr *mysql.MySQLError
+	if stderrors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		if m := mysqlDuplicateKey.FindStringSubmatch(mysqlErr.Message); m != nil {
+			//mysql8会带上表名 如 users.uidx_name
+			return m[1][strings.LastIndex(m[1], ".")+1:], true
+		}
+		return "", true
+	}
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) && pqErr.Code == postgresUniqueViolate {
+		return pqErr.Constraint, true
+	}
+	//sqlite3需要cgo 这里按错误信息判断 避免引入驱动
+	if msg := err.Error(); strings.HasPrefix(msg, "UNIQUE constraint failed: ") {
+		return strings.TrimPrefix(msg, "UNIQUE constraint failed: "), true
+	}
+	return "", false
+}
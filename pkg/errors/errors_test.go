@@ -0,0 +1,44 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	cases := []struct {
+		err   error
+		kind  error
+		index string
+	}{
+		{gorm.ErrRecordNotFound, ErrNotFound, ""},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'bqx' for key 'uidx_name'"}, ErrDuplicate, "uidx_name"},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-1000' for key 'raw_problems.uni_idx_pid'"}, ErrDuplicate, "uni_idx_pid"},
+		{&pq.Error{Code: "23505", Constraint: "uni_idx_pid"}, ErrDuplicate, "uni_idx_pid"},
+		{fmt.Errorf("UNIQUE constraint failed: users.nickname"), ErrDuplicate, "users.nickname"},
+	}
+	for _, c := range cases {
+		err := Wrap("op", c.err)
+		if !Is(err, c.kind) {
+			t.Fatalf("%v: expected %v", err, c.kind)
+		}
+		var e *Error
+		if !As(err, &e) || e.Index != c.index {
+			t.Fatalf("%v: expected index %q", err, c.index)
+		}
+	}
+	if Wrap("op", nil) != nil {
+		t.Fatal("wrap nil should be nil")
+	}
+	err := Wrap("op", context.DeadlineExceeded)
+	if !Is(err, context.DeadlineExceeded) || Is(err, ErrNotFound) {
+		t.Fatalf("unexpected classification: %v", err)
+	}
+	if !Is(InvalidArgument("op", "id is %v", 0), ErrInvalidArgument) {
+		t.Fatal("expected invalid argument")
+	}
+}
@@ -0,0 +1,151 @@
+//similarity 用shingling与minhash为题面生成指纹 估计两段文本的Jaccard相似度
+//指纹按band切分后的桶用于LSH 只有至少一个桶相同的题目才需要比较
+package similarity
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	//每个签名的哈希函数个数
+	NumHashes = 64
+	//按字符切分的shingle长度 对中英文题面都适用
+	ShingleSize = 5
+	//Bands*rows = NumHashes 相似度约0.5以上的文本大概率落入同一个桶
+	Bands = 16
+	rows  = NumHashes / Bands
+)
+
+type Signature []uint64
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+//去掉html标签 转小写 合并空白
+func Normalize(text string) string {
+	text = htmlTag.ReplaceAllString(text, " ")
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), unicode.IsSpace), " ")
+}
+
+//ShingleSize个字符为一个shingle 不足ShingleSize的文本整体作为一个shingle
+func Shingles(text string) map[uint64]struct{} {
+	runes := []rune(Normalize(text))
+	shingles := make(map[uint64]struct{})
+	if len(runes) == 0 {
+		return shingles
+	}
+	if len(runes) < ShingleSize {
+		shingles[hashString(string(runes))] = struct{}{}
+		return shingles
+	}
+	for i := 0; i+ShingleSize <= len(runes); i++ {
+		shingles[hashString(string(runes[i:i+ShingleSize]))] = struct{}{}
+	}
+	return shingles
+}
+
+//空文本返回nil
+func MinHash(text string) Signature {
+	shingles := Shingles(text)
+	if len(shingles) == 0 {
+		return nil
+	}
+	signature := make(Signature, NumHashes)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for shingle := range shingles {
+		for i := range signature {
+			if h := mix(shingle ^ seeds[i]); h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+	return signature
+}
+
+//相同位置取值相等的比例 即Jaccard相似度的估计 任一签名为空时为0
+func (s Signature) Similarity(other Signature) float64 {
+	if len(s) == 0 || len(s) != len(other) {
+		return 0
+	}
+	equal := 0
+	for i := range s {
+		if s[i] == other[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(s))
+}
+
+//每个band的桶 下标即band序号
+func (s Signature) Buckets() []string {
+	if len(s) != NumHashes {
+		return nil
+	}
+	buckets := make([]string, Bands)
+	buf := make([]byte, 8)
+	for band := 0; band < Bands; band++ {
+		h := fnv.New64a()
+		for _, v := range s[band*rows : (band+1)*rows] {
+			binary.LittleEndian.PutUint64(buf, v)
+			h.Write(buf)
+		}
+		buckets[band] = fmt.Sprintf("%016x", h.Sum64())
+	}
+	return buckets
+}
+
+func (s Signature) Encode() string {
+	buf := make([]byte, 8*len(s))
+	for i, v := range s {
+		binary.LittleEndian.PutUint64(buf[8*i:], v)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func Decode(encoded string) (Signature, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%8 != 0 {
+		return nil, fmt.Errorf("similarity: signature length %v is not a multiple of 8", len(buf))
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	signature := make(Signature, len(buf)/8)
+	for i := range signature {
+		signature[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	return signature, nil
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+//splitmix64 与不同的种子异或后得到相互独立的哈希函数
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+var seeds = func() []uint64 {
+	seeds := make([]uint64, NumHashes)
+	for i := range seeds {
+		seeds[i] = mix(uint64(i + 1))
+	}
+	return seeds
+}()
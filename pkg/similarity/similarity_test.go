@@ -0,0 +1,32 @@
+package similarity
+
+import "testing"
+
+func TestMinHashSimilarity(t *testing.T) {
+	a := MinHash("Calculate A + B. Each line of the input contains two integers A and B.")
+	b := MinHash("<p>Calculate A + B.</p> Each line of the input contains  two integers a and b!")
+	c := MinHash("Find the shortest path between two vertices of a weighted graph.")
+	if sim := a.Similarity(b); sim < 0.7 {
+		t.Fatalf("near duplicates should be similar, got %v", sim)
+	}
+	if sim := a.Similarity(c); sim > 0.2 {
+		t.Fatalf("unrelated statements should not be similar, got %v", sim)
+	}
+	if a.Similarity(MinHash("")) != 0 || MinHash("  ") != nil {
+		t.Fatal("empty text should have no signature")
+	}
+}
+
+func TestBucketsAndEncoding(t *testing.T) {
+	a := MinHash("Calculate A + B.")
+	if len(a.Buckets()) != Bands {
+		t.Fatalf("expected %v buckets, got %v", Bands, len(a.Buckets()))
+	}
+	decoded, err := Decode(a.Encode())
+	if err != nil || decoded.Similarity(a) != 1 {
+		t.Fatalf("round trip failed: %v %v", decoded, err)
+	}
+	if _, err = Decode("abc"); err == nil {
+		t.Fatal("expected an error for a malformed signature")
+	}
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+)
+
+//把context透传给database/sql 使取消与超时能中断正在执行的查询
+type ctxDB struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+type ctxTx struct {
+	tx  *sql.Tx
+	ctx context.Context
+}
+
+//context已结束时统一返回ctx.Err() 调用方可用errors.Is(err, context.DeadlineExceeded)区分超时
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *ctxDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	result, err := c.db.ExecContext(c.ctx, query, args...)
+	return result, ctxErr(c.ctx, err)
+}
+
+func (c *ctxDB) Prepare(query string) (*sql.Stmt, error) {
+	stmt, err := c.db.PrepareContext(c.ctx, query)
+	return stmt, ctxErr(c.ctx, err)
+}
+
+func (c *ctxDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := c.db.QueryContext(c.ctx, query, args...)
+	return rows, ctxErr(c.ctx, err)
+}
+
+func (c *ctxDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c *ctxDB) Begin() (*sql.Tx, error) {
+	return c.BeginTx(c.ctx, nil)
+}
+
+//gorm.Begin总是传入context.Background 这里始终使用绑定的ctx
+func (c *ctxDB) BeginTx(_ context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := c.db.BeginTx(c.ctx, opts)
+	return tx, ctxErr(c.ctx, err)
+}
+
+func (c *ctxTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	result, err := c.tx.ExecContext(c.ctx, query, args...)
+	return result, ctxErr(c.ctx, err)
+}
+
+func (c *ctxTx) Prepare(query string) (*sql.Stmt, error) {
+	stmt, err := c.tx.PrepareContext(c.ctx, query)
+	return stmt, ctxErr(c.ctx, err)
+}
+
+func (c *ctxTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := c.tx.QueryContext(c.ctx, query, args...)
+	return rows, ctxErr(c.ctx, err)
+}
+
+func (c *ctxTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.tx.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c *ctxTx) Commit() error {
+	return ctxErr(c.ctx, c.tx.Commit())
+}
+
+func (c *ctxTx) Rollback() error {
+	return c.tx.Rollback()
+}
+
+//返回绑定了ctx的gorm.DB 原db可以是普通连接也可以是事务
+func WithContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	var common gorm.SQLCommon
+	switch c := db.CommonDB().(type) {
+	case *sql.DB:
+		common = &ctxDB{db: c, ctx: ctx}
+	case *sql.Tx:
+		common = &ctxTx{tx: c, ctx: ctx}
+	case *ctxDB:
+		common = &ctxDB{db: c.db, ctx: ctx}
+	case *ctxTx:
+		common = &ctxTx{tx: c.tx, ctx: ctx}
+	default:
+		return db
+	}
+	ctxDb, err := gorm.Open(db.Dialect().GetName(), common)
+	if err != nil {
+		return db
+	}
+	return ctxDb
+}
+
+//返回db绑定的ctx 未绑定时返回context.Background()
+func Context(db *gorm.DB) context.Context {
+	switch c := db.CommonDB().(type) {
+	case *ctxDB:
+		return c.ctx
+	case *ctxTx:
+		return c.ctx
+	}
+	return context.Background()
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type contextTestRow struct {
+	ID   uint
+	Name string
+}
+
+func TestWithContextDeadline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vhoj_db_util")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "vhoj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.AutoMigrate(&contextTestRow{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = WithContext(db, ctx).Create(&contextTestRow{Name: "a"}).Error; err != nil {
+		t.Fatalf("create with live context: %v", err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	var rows []*contextTestRow
+	err = WithContext(db, expired).Find(&rows).Error
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	tx := WithContext(db, expired).Begin()
+	if !errors.Is(tx.Error, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded on begin, got %v", tx.Error)
+	}
+	if Context(WithContext(db, ctx)) != ctx || Context(db) != context.Background() {
+		t.Fatal("bound context not returned")
+	}
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ecnuvj/vhoj_db/pkg/common"
+	"github.com/jinzhu/gorm"
+	"reflect"
+	"time"
+)
+
+//keyset分页游标 记录翻页起点行的(updated_at, id) 列表统一按updated_at desc, id desc排序
+type Cursor struct {
+	UpdatedAt time.Time `json:"u"`
+	Id        uint      `json:"i"`
+	//为true时向前翻页 取排在起点行之前的记录
+	Prev bool `json:"p,omitempty"`
+}
+
+//一页结果的前后游标 为空表示该方向没有更多记录
+type CursorPage struct {
+	Next string
+	Prev string
+}
+
+func EncodeCursor(cursor *Cursor) string {
+	bytes, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+//空字符串表示第一页 返回nil
+func DecodeCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	bytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor %q", token)
+	}
+	cursor := &Cursor{}
+	if err = json.Unmarshal(bytes, cursor); err != nil || cursor.Id == 0 {
+		return nil, fmt.Errorf("malformed cursor %q", token)
+	}
+	return cursor, nil
+}
+
+func CursorPageSize(pageSize int32) int32 {
+	if pageSize <= 0 {
+		return common.DEFAULT_PAGE_SIZE
+	}
+	return pageSize
+}
+
+//在db上追加keyset条件与排序 多取一条用于判断是否还有更多记录
+//table非空时用于限定列名 避免join时列名歧义
+func KeysetQuery(db *gorm.DB, table string, cursor *Cursor, pageSize int32) *gorm.DB {
+	updatedAt, id := "updated_at", "id"
+	if table != "" {
+		updatedAt, id = table+".updated_at", table+".id"
+	}
+	desc := fmt.Sprintf("%v desc, %v desc", updatedAt, id)
+	if cursor == nil {
+		return db.Order(desc).Limit(CursorPageSize(pageSize) + 1)
+	}
+	if cursor.Prev {
+		return db.
+			Where(fmt.Sprintf("%v > ? or (%v = ? and %v > ?)", updatedAt, updatedAt, id), cursor.UpdatedAt, cursor.UpdatedAt, cursor.Id).
+			Order(fmt.Sprintf("%v asc, %v asc", updatedAt, id)).
+			Limit(CursorPageSize(pageSize) + 1)
+	}
+	return db.
+		Where(fmt.Sprintf("%v < ? or (%v = ? and %v < ?)", updatedAt, updatedAt, id), cursor.UpdatedAt, cursor.UpdatedAt, cursor.Id).
+		Order(desc).
+		Limit(CursorPageSize(pageSize) + 1)
+}
+
+//整理KeysetQuery的结果 rows为结果切片 key返回第i行的(updated_at, id)
+//返回本页保留的行数 rows的前n行已按updated_at desc, id desc排好序
+func KeysetPage(rows interface{}, cursor *Cursor, pageSize int32, key func(i int) (time.Time, uint)) (int, *CursorPage) {
+	pageSize = CursorPageSize(pageSize)
+	total := reflect.ValueOf(rows).Len()
+	hasMore := total > int(pageSize)
+	n := total
+	if hasMore {
+		n = int(pageSize)
+	}
+	page := &CursorPage{}
+	if n == 0 {
+		return 0, page
+	}
+	backward := cursor != nil && cursor.Prev
+	if backward {
+		swap := reflect.Swapper(rows)
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	firstAt, firstId := key(0)
+	lastAt, lastId := key(n - 1)
+	if backward {
+		if hasMore {
+			page.Prev = EncodeCursor(&Cursor{UpdatedAt: firstAt, Id: firstId, Prev: true})
+		}
+		page.Next = EncodeCursor(&Cursor{UpdatedAt: lastAt, Id: lastId})
+	} else {
+		if hasMore {
+			page.Next = EncodeCursor(&Cursor{UpdatedAt: lastAt, Id: lastId})
+		}
+		if cursor != nil {
+			page.Prev = EncodeCursor(&Cursor{UpdatedAt: firstAt, Id: firstId, Prev: true})
+		}
+	}
+	return n, page
+}
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2021, 3, 4, 5, 6, 7, 890, time.FixedZone("CST", 8*3600))
+	cursor, err := DecodeCursor(EncodeCursor(&Cursor{UpdatedAt: at, Id: 42, Prev: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cursor.UpdatedAt.Equal(at) || cursor.Id != 42 || !cursor.Prev {
+		t.Fatalf("unexpected cursor: %+v", cursor)
+	}
+	if cursor, err = DecodeCursor(""); cursor != nil || err != nil {
+		t.Fatalf("empty cursor should mean first page: %+v %v", cursor, err)
+	}
+	for _, token := range []string{"%%%", "bm90IGpzb24", EncodeCursor(&Cursor{UpdatedAt: at})} {
+		if _, err = DecodeCursor(token); err == nil {
+			t.Fatalf("expected error for %q", token)
+		}
+	}
+}
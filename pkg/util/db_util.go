@@ -0,0 +1,11 @@
+package util
+
+import "github.com/jinzhu/gorm"
+
+//随机排序函数 mysql为rand() postgres与sqlite为random()
+func RandomFunc(db *gorm.DB) string {
+	if db.Dialect().GetName() == "mysql" {
+		return "rand()"
+	}
+	return "random()"
+}
@@ -0,0 +1,19 @@
+package util
+
+import "testing"
+
+func TestDiffLines(t *testing.T) {
+	lines := DiffLines("a\nb\nc", "a\nc\nd")
+	want := []DiffLine{{DiffEqual, "a"}, {DiffDelete, "b"}, {DiffEqual, "c"}, {DiffInsert, "d"}}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected diff: %+v", lines)
+	}
+	for i, line := range lines {
+		if *line != want[i] {
+			t.Fatalf("line %v: expected %+v, got %+v", i, want[i], *line)
+		}
+	}
+	if lines = DiffLines("", "x"); len(lines) != 1 || lines[0].Op != DiffInsert {
+		t.Fatalf("unexpected diff from empty text: %+v", lines)
+	}
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//数值与紧随其后的单位 如"1000MS" "1.5 seconds" "64 MB"
+var limitPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([a-zA-Z]*)`)
+
+var timeUnits = map[string]float64{
+	"":             1,
+	"ms":           1,
+	"msec":         1,
+	"millisecond":  1,
+	"milliseconds": 1,
+	"s":            1000,
+	"sec":          1000,
+	"secs":         1000,
+	"second":       1000,
+	"seconds":      1000,
+}
+
+var memoryUnits = map[string]float64{
+	"":          1,
+	"k":         1,
+	"kb":        1,
+	"kib":       1,
+	"kilobyte":  1,
+	"kilobytes": 1,
+	"m":         1024,
+	"mb":        1024,
+	"mib":       1024,
+	"megabyte":  1024,
+	"megabytes": 1024,
+	"g":         1024 * 1024,
+	"gb":        1024 * 1024,
+	"gib":       1024 * 1024,
+	"b":         1.0 / 1024,
+	"byte":      1.0 / 1024,
+	"bytes":     1.0 / 1024,
+}
+
+//把"1000MS" "1 second"等时间限制解析为毫秒 没有单位时按毫秒 无法解析时返回0
+func ParseTimeLimit(limit string) int32 {
+	return parseLimit(limit, timeUnits)
+}
+
+//把"65536K" "256 MB"等内存限制解析为KB 没有单位时按KB 无法解析时返回0
+func ParseMemoryLimit(limit string) int32 {
+	return parseLimit(limit, memoryUnits)
+}
+
+//取第一个单位可识别的数值
+func parseLimit(limit string, units map[string]float64) int32 {
+	for _, match := range limitPattern.FindAllStringSubmatch(limit, -1) {
+		scale, ok := units[strings.ToLower(match[2])]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		value = math.Round(value * scale)
+		if value <= 0 || value > math.MaxInt32 {
+			return 0
+		}
+		return int32(value)
+	}
+	return 0
+}
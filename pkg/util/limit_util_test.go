@@ -0,0 +1,45 @@
+package util
+
+import "testing"
+
+func TestParseTimeLimit(t *testing.T) {
+	cases := []struct {
+		limit string
+		ms    int32
+	}{
+		{"1000MS", 1000},
+		{"1000 ms", 1000},
+		{"1 second", 1000},
+		{"2 seconds", 2000},
+		{"1.5s", 1500},
+		{"500", 500},
+		{"", 0},
+		{"unknown", 0},
+	}
+	for _, c := range cases {
+		if got := ParseTimeLimit(c.limit); got != c.ms {
+			t.Errorf("ParseTimeLimit(%q) = %v, want %v", c.limit, got, c.ms)
+		}
+	}
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	cases := []struct {
+		limit string
+		kb    int32
+	}{
+		{"65536K", 65536},
+		{"32768 KB", 32768},
+		{"256 MB", 262144},
+		{"256 megabytes", 262144},
+		{"1 GB", 1048576},
+		{"1048576 bytes", 1024},
+		{"65536", 65536},
+		{"", 0},
+	}
+	for _, c := range cases {
+		if got := ParseMemoryLimit(c.limit); got != c.kb {
+			t.Errorf("ParseMemoryLimit(%q) = %v, want %v", c.limit, got, c.kb)
+		}
+	}
+}
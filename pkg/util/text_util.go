@@ -0,0 +1,151 @@
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+//LIKE模式中的转义字符 各数据库都不会对它做额外处理
+const LikeEscape = "!"
+
+var likeReplacer = strings.NewReplacer(LikeEscape, LikeEscape+LikeEscape, "%", LikeEscape+"%", "_", LikeEscape+"_")
+
+//转义LIKE模式中的通配符 需配合ESCAPE '!'使用
+func EscapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
+
+//按空白切分检索词 转为小写并去重
+func SearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0)
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+//统计text中出现了多少个不同的检索词 不区分大小写
+func CountMatchedTerms(text string, terms []string) int {
+	lower := string(lowerRunes(text))
+	count := 0
+	for _, term := range terms {
+		if strings.Contains(lower, term) {
+			count++
+		}
+	}
+	return count
+}
+
+//去掉HTML标签并还原实体 连续空白合并为一个空格
+func StripTags(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '<' && i+1 < len(runes) && isTagStart(runes[i+1]) {
+			end := i + 1
+			for end < len(runes) && runes[end] != '>' {
+				end++
+			}
+			if end < len(runes) {
+				b.WriteRune(' ')
+				i = end
+				continue
+			}
+		}
+		b.WriteRune(runes[i])
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+func isTagStart(r rune) bool {
+	return r == '/' || r == '!' || r == '?' || unicode.IsLetter(r)
+}
+
+//截取text去掉标签后第一个命中附近约width个字符的片段 命中的检索词用pre与post包裹
+//返回HTML片段 除pre与post外的内容均已转义 没有命中时返回空字符串
+func Snippet(text string, terms []string, width int, pre string, post string) string {
+	text = StripTags(text)
+	runes := []rune(text)
+	lower := lowerRunes(text)
+	type hit struct{ start, end int }
+	hits := make([]hit, 0)
+	for i := 0; i < len(lower); {
+		matched := 0
+		for _, term := range terms {
+			t := []rune(term)
+			if len(t) > matched && hasPrefixRunes(lower[i:], t) {
+				matched = len(t)
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		hits = append(hits, hit{i, i + matched})
+		i += matched
+	}
+	if len(hits) == 0 {
+		return ""
+	}
+	//窗口尽量放满 短文本直接返回全文
+	start := hits[0].start - width/4
+	if start > len(runes)-width {
+		start = len(runes) - width
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	pos := start
+	for _, h := range hits {
+		if h.start < start {
+			continue
+		}
+		if h.end > end {
+			break
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:h.start])))
+		b.WriteString(pre)
+		b.WriteString(html.EscapeString(string(runes[h.start:h.end])))
+		b.WriteString(post)
+		pos = h.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+//逐字符转小写 保证与原文的字符下标一一对应
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func hasPrefixRunes(s []rune, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package util
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	terms := SearchTerms("Sum  SUM 整数")
+	if len(terms) != 2 || terms[0] != "sum" || terms[1] != "整数" {
+		t.Fatalf("unexpected terms: %v", terms)
+	}
+	cases := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"Calculate the sum of two integers.", 100, "Calculate the <em>sum</em> of two integers."},
+		{"输入两个整数 输出它们的和", 6, "...个<em>整数</em> 输出..."},
+		{"a very long prefix before the Sum appears here", 12, "...he <em>Sum</em> appea..."},
+		{"nothing matches", 100, ""},
+		{"<p>The <b>sum</b> of a &lt; b</p>", 100, "The <em>sum</em> of a &lt; b"},
+		{"<img src=x onerror=alert(1)>sum <script>", 100, "<em>sum</em>"},
+		{"a<b && sum", 100, "a&lt;b &amp;&amp; <em>sum</em>"},
+	}
+	for _, c := range cases {
+		if got := Snippet(c.text, terms, c.width, "<em>", "</em>"); got != c.want {
+			t.Fatalf("snippet of %q: expected %q, got %q", c.text, c.want, got)
+		}
+	}
+	if count := CountMatchedTerms("SUM sum Sum", terms); count != 1 {
+		t.Fatalf("expected 1 matched term, got %v", count)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := EscapeLike("100%_a!b"); got != "100!%!_a!!b" {
+		t.Fatalf("unexpected escaped pattern: %v", got)
+	}
+}
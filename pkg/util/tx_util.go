@@ -0,0 +1,66 @@
+package util
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"sync/atomic"
+)
+
+type sqlTx interface {
+	Commit() error
+	Rollback() error
+}
+
+var savepointSeq uint64
+
+func InTransaction(db *gorm.DB) bool {
+	_, ok := db.CommonDB().(sqlTx)
+	return ok
+}
+
+//在事务中执行fn fn返回错误或panic时回滚
+//db已处于事务中时用savepoint实现嵌套 内层失败只回滚到savepoint 由外层决定是否提交
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	if InTransaction(db) {
+		return withSavepoint(db, fn)
+	}
+	_, withCtx := db.CommonDB().(*ctxDB)
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if withCtx {
+		tx = WithContext(tx, Context(db))
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Rollback()
+		}
+	}()
+	err = fn(tx)
+	if err == nil {
+		err = tx.Commit().Error
+	}
+	panicked = false
+	return err
+}
+
+func withSavepoint(db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	name := fmt.Sprintf("vhoj_sp_%v", atomic.AddUint64(&savepointSeq, 1))
+	if err = db.Exec("SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			db.Exec("ROLLBACK TO SAVEPOINT " + name)
+		}
+	}()
+	err = fn(db)
+	if err == nil {
+		err = db.Exec("RELEASE SAVEPOINT " + name).Error
+	}
+	panicked = false
+	return err
+}
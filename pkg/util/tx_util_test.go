@@ -0,0 +1,66 @@
+package util
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type txTestRow struct {
+	ID   uint
+	Name string
+}
+
+func TestTransactionNestedSavepoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vhoj_db_util")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "vhoj.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.AutoMigrate(&txTestRow{})
+
+	err = Transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&txTestRow{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		innerErr := Transaction(tx, func(inner *gorm.DB) error {
+			if err := inner.Create(&txTestRow{Name: "inner"}).Error; err != nil {
+				return err
+			}
+			return fmt.Errorf("inner failed")
+		})
+		if innerErr == nil {
+			t.Fatal("expected inner error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("outer transaction: %v", err)
+	}
+	var names []string
+	db.Model(&txTestRow{}).Pluck("name", &names)
+	if len(names) != 1 || names[0] != "outer" {
+		t.Fatalf("expected only outer row, got %v", names)
+	}
+
+	err = Transaction(db, func(tx *gorm.DB) error {
+		tx.Create(&txTestRow{Name: "rolled back"})
+		return fmt.Errorf("outer failed")
+	})
+	if err == nil {
+		t.Fatal("expected outer error")
+	}
+	var count int
+	db.Model(&txTestRow{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected rollback, got %v rows", count)
+	}
+}